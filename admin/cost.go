@@ -0,0 +1,35 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/seehuhn/trace"
+)
+
+// CostHandler returns an http.Handler which reports the estimated
+// message volume and cost, as a JSON array of trace.VolumeStat values.
+// The argument 'prices' gives the price per gigabyte for each sink, as
+// described for trace.VolumeCounter.Report().
+func CostHandler(v *trace.VolumeCounter, prices map[string]float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v.Report(prices))
+	})
+}
@@ -0,0 +1,41 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestCostHandler(t *testing.T) {
+	v := trace.NewVolumeCounter()
+	v.Add("otlp", &trace.Message{Path: "http", Msg: "hello"})
+	handler := CostHandler(v, map[string]float64{"*": 1})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var report []trace.VolumeStat
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 1 || report[0].Sink != "otlp" || report[0].CostPerDay <= 0 {
+		t.Errorf("wrong report %v", report)
+	}
+}
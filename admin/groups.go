@@ -0,0 +1,44 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/seehuhn/trace"
+)
+
+// GroupsHandler returns an http.Handler which reports the listener
+// groups and the routing table, as a JSON array of trace.GroupInfo
+// values.  A PUT request with a JSON array of trace.RouteRule values
+// as the body replaces the routing table.
+func GroupsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var rules []trace.RouteRule
+			if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			trace.SetRoutes(rules)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(trace.Groups())
+	})
+}
@@ -0,0 +1,52 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestGroupsHandler(t *testing.T) {
+	handle := trace.JoinGroup("alerts", func(m *trace.Message) {})
+	defer handle.Unregister()
+	defer trace.SetRoutes(nil)
+
+	handler := GroupsHandler()
+	body := strings.NewReader(`[{"path": "db", "prio": 1000, "group": "alerts"}]`)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("PUT", "/", body))
+	var groups []trace.GroupInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Name != "alerts" || groups[0].Members != 1 ||
+		len(groups[0].Rules) != 1 || groups[0].Rules[0].Path != "db" {
+		t.Errorf("wrong groups %v", groups)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("PUT", "/", strings.NewReader("[")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
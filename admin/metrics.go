@@ -0,0 +1,84 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/seehuhn/trace"
+)
+
+// MetricsHandler returns an http.Handler which reports the message
+// counts from trace.ExactStats() in the Prometheus text exposition
+// format.  There is one counter per path, priority bucket and outcome,
+// for example
+//
+//	trace_messages_total{path="db/mysql",prio="error",outcome="emitted"} 12
+//
+// Since the counters for a path do not include its sub-paths, label
+// matchers like path=~"db(/.*)?" can be used to select all messages
+// under a given path.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(serveMetrics)
+}
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := trace.ExactStats()
+	paths := make([]string, 0, len(stats))
+	for path := range stats {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP trace_messages_total Number of trace messages by path, priority and outcome.")
+	fmt.Fprintln(w, "# TYPE trace_messages_total counter")
+	for _, path := range paths {
+		prios := make([]trace.Priority, 0, len(stats[path]))
+		for prio := range stats[path] {
+			prios = append(prios, prio)
+		}
+		sort.Slice(prios, func(i, j int) bool { return prios[i] > prios[j] })
+		for _, prio := range prios {
+			counts := stats[path][prio]
+			for _, out := range []struct {
+				name  string
+				value uint64
+			}{
+				{"emitted", counts.Emitted},
+				{"suppressed", counts.Suppressed},
+				{"dropped", counts.Dropped},
+			} {
+				fmt.Fprintf(w, "trace_messages_total{path=\"%s\",prio=\"%s\",outcome=\"%s\"} %d\n",
+					labelValue(path), prio.String(), out.name, out.value)
+			}
+		}
+	}
+}
+
+// labelEscaper escapes label values as required by the Prometheus text
+// format.  Unlike with strconv.Quote(), only backslashes, double quotes
+// and line feeds are escaped, and UTF-8 text is left unchanged.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue returns 's', escaped for use as a label value.
+func labelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
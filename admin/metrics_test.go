@@ -0,0 +1,58 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	trace.ResetStats()
+	handle := trace.Register(func(m *trace.Message) {}, "metrics", trace.PrioInfo)
+	trace.T("metrics/db", trace.PrioError, "failed")
+	trace.T("metrics/db", trace.PrioError, "failed")
+	handle.Unregister()
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	body := rec.Body.String()
+	line := `trace_messages_total{path="metrics/db",prio="error",outcome="emitted"} 2` + "\n"
+	if !strings.Contains(body, line) {
+		t.Errorf("missing %q in output:\n%s", line, body)
+	}
+	if !strings.HasPrefix(body, "# HELP trace_messages_total ") {
+		t.Errorf("missing HELP line:\n%s", body)
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	testData := []struct{ in, out string }{
+		{"db/mysql", "db/mysql"},
+		{"größe", "größe"},
+		{`a"b\c`, `a\"b\\c`},
+		{"a\nb\tc", `a\nb` + "\tc"},
+	}
+	for _, test := range testData {
+		if out := labelValue(test.in); out != test.out {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, out)
+		}
+	}
+}
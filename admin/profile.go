@@ -0,0 +1,60 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/seehuhn/trace"
+)
+
+// ProfileHandler returns an http.Handler which reports the call sites
+// of trace.T() which spent the most time formatting and delivering
+// messages, as a JSON array of trace.SiteProfile values.  Profiling is
+// off by default; a POST request with "enable=1" or "enable=0" turns
+// it on or off, and a POST request with "reset=1" clears the data.
+// The optional query parameter "n" sets the number of call sites to
+// report (default 20, or all if negative).
+func ProfileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := defaultTopCount
+		if s := r.FormValue("n"); s != "" {
+			var err error
+			n, err = strconv.Atoi(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if r.Method == http.MethodPost {
+			switch r.FormValue("enable") {
+			case "1":
+				trace.SetProfiling(true)
+			case "0":
+				trace.SetProfiling(false)
+			}
+			if r.FormValue("reset") == "1" {
+				trace.ResetProfile()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(trace.Profile(n))
+	})
+}
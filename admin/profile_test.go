@@ -0,0 +1,46 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestProfileHandler(t *testing.T) {
+	handler := ProfileHandler()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/?enable=1&reset=1", nil))
+	defer trace.SetProfiling(false)
+
+	handle := trace.Register(func(m *trace.Message) {}, "profile", trace.PrioInfo)
+	defer handle.Unregister()
+	trace.T("profile", trace.PrioInfo, "hello")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/?enable=0", nil))
+	var prof []trace.SiteProfile
+	if err := json.Unmarshal(rec.Body.Bytes(), &prof); err != nil {
+		t.Fatal(err)
+	}
+	if len(prof) != 1 || prof[0].Path != "profile" || prof[0].Calls != 1 {
+		t.Errorf("wrong profile %v", prof)
+	}
+}
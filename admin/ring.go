@@ -0,0 +1,45 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/seehuhn/trace"
+)
+
+// RingHandler returns an http.Handler which writes the messages stored
+// in 'ring' as a stream of JSON objects, one per line, oldest first.
+// The optional parameter "q" selects messages using a query in the
+// syntax described for trace.ParseQuery(), for example
+// "prio>=error since=5m".
+func RingHandler(ring *trace.RingListener) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q, ok := parseQuery(w, r.FormValue("q"))
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, m := range ring.Messages(q) {
+			if err := enc.Encode(m); err != nil {
+				return
+			}
+		}
+	})
+}
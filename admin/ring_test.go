@@ -0,0 +1,63 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestRingHandler(t *testing.T) {
+	ring := trace.NewRingListener(10)
+	now := time.Now()
+	ring.Listen(&trace.Message{Time: now, Path: "net/http", Prio: trace.PrioError, Msg: "read timeout"})
+	ring.Listen(&trace.Message{Time: now, Path: "net/http", Prio: trace.PrioDebug, Msg: "read timeout"})
+	ring.Listen(&trace.Message{Time: now, Path: "db", Prio: trace.PrioError, Msg: "timeout"})
+	ring.Listen(&trace.Message{Time: now, Path: "net/dns", Prio: trace.PrioError, Msg: "no such host"})
+
+	testData := []struct {
+		query string
+		code  int
+		n     int
+	}{
+		{"", http.StatusOK, 4},
+		{`path~"net/*" prio>=error msg~timeout`, http.StatusOK, 1},
+		{"prio>=error", http.StatusOK, 3},
+		{"color=red", http.StatusBadRequest, 0},
+	}
+	for _, test := range testData {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/?q="+url.QueryEscape(test.query), nil)
+		RingHandler(ring).ServeHTTP(rec, req)
+		if rec.Code != test.code {
+			t.Errorf("%q: expected status %d, got %d", test.query, test.code, rec.Code)
+			continue
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		if n := strings.Count(rec.Body.String(), "\n"); n != test.n {
+			t.Errorf("%q: expected %d messages, got %d", test.query, test.n, n)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/seehuhn/trace"
+)
+
+// SelfTestHandler returns an http.Handler which runs trace.SelfTest()
+// for every request and reports the results as a JSON array of
+// trace.SelfTestResult values.  The response status is 200 if all sinks
+// passed the test, and 503 otherwise, so that the handler can be used
+// directly in deployment smoke tests.  Sinks which cannot confirm the
+// delivery of the probe message count as passed; their Verified field
+// is false.  The command "trace selftest" prints the results in human
+// readable form.
+func SelfTestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := trace.SelfTest(r.Context())
+		status := http.StatusOK
+		for i := range results {
+			if !results[i].Passed() {
+				status = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(results)
+	})
+}
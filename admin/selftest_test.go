@@ -0,0 +1,67 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+type testSink struct {
+	health error
+}
+
+func (s *testSink) Open() error             { return nil }
+func (s *testSink) Listen(m *trace.Message) {}
+func (s *testSink) Flush() error            { return nil }
+func (s *testSink) Close() error            { return nil }
+func (s *testSink) HealthCheck() error      { return s.health }
+
+func TestSelfTestHandler(t *testing.T) {
+	sink := &testSink{}
+	handle, err := trace.Install(sink, "selftest", trace.PrioInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Unregister()
+
+	handler := SelfTestHandler()
+	for _, health := range []error{nil, errors.New("server down")} {
+		sink.health = health
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		var results []trace.SelfTestResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Passed() != (health == nil) {
+			t.Errorf("wrong results %v", results)
+		}
+		expected := http.StatusOK
+		if health != nil {
+			expected = http.StatusServiceUnavailable
+		}
+		if rec.Code != expected {
+			t.Errorf("expected status %d, got %d", expected, rec.Code)
+		}
+	}
+}
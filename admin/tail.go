@@ -0,0 +1,149 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package admin provides HTTP handlers which give access to the trace
+// messages of a running program.
+//
+// The handlers can be installed on any http.ServeMux, for example:
+//
+//	http.Handle("/debug/trace/tail", admin.TailHandler())
+//	http.Handle("/debug/trace/ring", admin.RingHandler(ring))
+//	http.Handle("/debug/trace/top", admin.TopHandler(sites))
+//	http.Handle("/debug/trace/groups", admin.GroupsHandler())
+//	http.Handle("/debug/trace/profile", admin.ProfileHandler())
+//	http.Handle("/debug/trace/selftest", admin.SelfTestHandler())
+//	http.Handle("/metrics", admin.MetricsHandler())
+//
+// Here, 'ring' is a trace.RingListener and 'sites' is a
+// trace.SiteCounter, both registered using trace.Register().  The tail
+// and ring handlers select messages using the query syntax of
+// trace.ParseQuery().
+//
+// To estimate the cost of the trace output, register a
+// trace.VolumeCounter listener next to each sink and install
+// CostHandler:
+//
+//	v := trace.NewVolumeCounter()
+//	trace.Register(v.Listener("otlp"), "", trace.PrioInfo)
+//	http.Handle("/debug/trace/cost", admin.CostHandler(v,
+//		map[string]float64{"otlp": 0.50}))
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/seehuhn/trace"
+)
+
+// tailQueueSize is the number of messages buffered for each client of
+// the tail handler.  If a client cannot keep up, the stream is ended.
+const tailQueueSize = 256
+
+// TailHandler returns an http.Handler which streams trace messages to
+// the client, in the format written by trace.JSONWriter, until the
+// client disconnects.  The optional query parameters "path" and
+// "prio" select the messages to stream, with the same meaning as the
+// corresponding arguments of trace.Register(); the priority can be
+// given as a name like "debug" or as a number.  By default, all
+// messages of priority trace.PrioInfo and higher are streamed.  The
+// optional parameter "q" gives a query in the syntax described for
+// trace.ParseQuery(), for example
+//
+//	/debug/trace/tail?q=path~"net/*"+prio>=error+msg~timeout
+//
+// If both "path" and "q" are given, a message must satisfy both to be
+// streamed.  If "q" is given but "prio" is not, the priority bound is
+// taken from the query, so that all priorities are streamed unless the
+// query restricts them.  The messages are taken from a trace.Mirror, so
+// that slow clients cannot delay the program; if a client cannot keep
+// up, the stream ends.
+func TailHandler() http.Handler {
+	return http.HandlerFunc(serveTail)
+}
+
+func serveTail(w http.ResponseWriter, r *http.Request) {
+	text := r.FormValue("q")
+	hasQuery := text != ""
+	if path := r.FormValue("path"); path != "" {
+		text = "path=" + strconv.Quote(path) + " " + text
+	}
+	q, ok := parseQuery(w, text)
+	if !ok {
+		return
+	}
+	path := ""
+	prio := trace.PrioInfo
+	if q != nil {
+		var qPrio trace.Priority
+		path, qPrio = q.Scope()
+		if hasQuery {
+			prio = qPrio
+		}
+	}
+	if s := r.FormValue("prio"); s != "" {
+		var err error
+		prio, err = trace.ParsePriority(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, _ := w.(http.Flusher)
+
+	mirror := trace.NewMirror(r.Context(), path, prio, tailQueueSize)
+	defer mirror.Close()
+	queue := mirror.Messages()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case m := <-queue:
+			if !q.Match(m) {
+				continue
+			}
+			if err := enc.Encode(m); err != nil {
+				return
+			}
+			if flusher != nil && len(queue) == 0 {
+				flusher.Flush()
+			}
+		case <-mirror.Done():
+			return
+		}
+	}
+}
+
+// parseQuery parses a query given in the syntax of trace.ParseQuery().
+// If s is empty, nil is returned.  If the query is invalid, an error
+// response is sent and ok is false.
+func parseQuery(w http.ResponseWriter, s string) (q *trace.Query, ok bool) {
+	if s == "" {
+		return nil, true
+	}
+	q, err := trace.ParseQuery(s)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return q, true
+}
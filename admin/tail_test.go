@@ -0,0 +1,197 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestTailHandler(t *testing.T) {
+	server := httptest.NewServer(TailHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?path=tail&prio=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("wrong content type %q", ct)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			trace.T("other", trace.PrioError, "ignored")
+			trace.T("tail/a", trace.PrioVerbose, "ignored")
+			trace.T("tail/a", trace.PrioDebug, "hello")
+		}
+	}()
+
+	r := trace.NewJSONReader(resp.Body)
+	m, err := r.Read()
+	done <- struct{}{}
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path != "tail/a" || m.Prio != trace.PrioDebug || m.Msg != "hello" {
+		t.Errorf("wrong message %v", m)
+	}
+}
+
+func TestTailBadPriority(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/?prio=loud", nil)
+	TailHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestTailQuery(t *testing.T) {
+	server := httptest.NewServer(TailHandler())
+	defer server.Close()
+
+	q := url.QueryEscape(`path~"tailq/*" msg~"^wanted"`)
+	resp, err := http.Get(server.URL + "?q=" + q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			trace.T("tailq", trace.PrioError, "wanted, but wrong path")
+			trace.T("tailq/a", trace.PrioInfo, "unwanted")
+			trace.T("tailq/a", trace.PrioVerbose, "wanted")
+		}
+	}()
+
+	r := trace.NewJSONReader(resp.Body)
+	m, err := r.Read()
+	done <- struct{}{}
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path != "tailq/a" || m.Prio != trace.PrioVerbose || m.Msg != "wanted" {
+		t.Errorf("wrong message %v", m)
+	}
+}
+
+func TestTailPathAndQuery(t *testing.T) {
+	server := httptest.NewServer(TailHandler())
+	defer server.Close()
+
+	q := url.QueryEscape(`path=tailpq/a/b msg~wanted`)
+	resp, err := http.Get(server.URL + "?path=tailpq/x&q=" + q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status %d", resp.StatusCode)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			trace.T("tailpq/a/b", trace.PrioInfo, "wanted, but outside path")
+			trace.T("tailpq/x", trace.PrioInfo, "wanted, but outside query")
+		}
+	}()
+
+	// No message satisfies both the path and the query, so the stream
+	// must not deliver anything.
+	lines := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 512)
+		n, _ := resp.Body.Read(buf)
+		lines <- string(buf[:n])
+	}()
+	select {
+	case line := <-lines:
+		t.Errorf("unexpected output %q", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+	done <- struct{}{}
+	<-done
+}
+
+func TestTailPathWithQuery(t *testing.T) {
+	server := httptest.NewServer(TailHandler())
+	defer server.Close()
+
+	q := url.QueryEscape(`path=tailpw/a msg~^wanted`)
+	resp, err := http.Get(server.URL + "?path=tailpw&q=" + q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			trace.T("tailpw", trace.PrioInfo, "wanted, but outside query")
+			trace.T("tailpw/a", trace.PrioInfo, "unwanted")
+			trace.T("tailpw/a/b", trace.PrioVerbose, "wanted")
+		}
+	}()
+
+	r := trace.NewJSONReader(resp.Body)
+	m, err := r.Read()
+	done <- struct{}{}
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path != "tailpw/a/b" || m.Prio != trace.PrioVerbose || m.Msg != "wanted" {
+		t.Errorf("wrong message %v", m)
+	}
+}
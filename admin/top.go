@@ -0,0 +1,61 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/seehuhn/trace"
+)
+
+// defaultTopCount is the number of call sites reported by the top
+// handler if no "n" query parameter is given.
+const defaultTopCount = 20
+
+// TopHandler returns an http.Handler which reports the call sites
+// which emitted the most trace messages, as counted by 'counter'.  The
+// response is a JSON array of trace.SiteStat values.  The optional
+// query parameter "n" sets the number of call sites to report (default
+// 20, or all if negative), and a POST request with "reset=1" clears the
+// statistics.
+//
+// The handler only reads 'counter'; the caller registers counter.Listen
+// using trace.Register() and unregisters it when it is no longer
+// needed.  Counting messages causes all messages of the selected
+// priorities to be formatted, so the priority should not be chosen
+// lower than necessary.
+func TopHandler(counter *trace.SiteCounter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := defaultTopCount
+		if s := r.FormValue("n"); s != "" {
+			var err error
+			n, err = strconv.Atoi(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if r.Method == http.MethodPost && r.FormValue("reset") == "1" {
+			counter.Reset()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(counter.Top(n))
+	})
+}
@@ -0,0 +1,60 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestTopHandler(t *testing.T) {
+	counter := trace.NewSiteCounter()
+	handle := trace.Register(counter.Listen, "top", trace.PrioDebug)
+	defer handle.Unregister()
+	handler := TopHandler(counter)
+	for i := 0; i < 3; i++ {
+		trace.T("top/a", trace.PrioDebug, "item %d", i)
+	}
+	trace.T("top/b", trace.PrioInfo, "hello")
+	trace.T("top/b", trace.PrioVerbose, "ignored")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?n=1", nil))
+	var top []trace.SiteStat
+	if err := json.Unmarshal(rec.Body.Bytes(), &top); err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 1 || top[0].Path != "top/a" || top[0].Count != 3 {
+		t.Errorf("wrong statistics %v", top)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/?reset=1", nil))
+	if s := rec.Body.String(); s != "[]\n" {
+		t.Errorf("reset failed: %q", s)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?n=many", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
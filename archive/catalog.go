@@ -0,0 +1,244 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package archive maintains a catalog of archived trace files.
+//
+// A catalog is a manifest, stored as a JSON file, which records for
+// every archived trace file the time range covered, the message paths
+// present and the file size.  Tools can use the catalog to locate the
+// archive segments relevant for a given time range and path, without
+// reading the archived files themselves.  Trace files may be stored
+// uncompressed or compressed with gzip (file names ending in ".gz").
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// Entry describes one archived trace file.
+type Entry struct {
+	// Name is the location of the archived file.  For entries created
+	// by Scan(), this is the file name as given.
+	Name string `json:"name"`
+
+	// Start and End give the times of the earliest and latest message
+	// in the file.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Paths lists the distinct message paths in the file, in sorted
+	// order.
+	Paths []string `json:"paths"`
+
+	// Messages is the number of messages, and Size is the size of the
+	// (possibly compressed) file in bytes.
+	Messages int   `json:"messages"`
+	Size     int64 `json:"size"`
+
+	// SHA256 is the hex encoded SHA-256 hash of the (possibly
+	// compressed) file contents.  This allows to check that archived
+	// files are unchanged, for example after messages have been
+	// erased using "trace erase".
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Scan reads the named trace file and returns its catalog entry.
+func Scan(name string) (*Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	var r io.Reader = io.TeeReader(f, hash)
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	e := &Entry{Name: name, Size: fi.Size()}
+	paths := map[string]bool{}
+	rd := trace.NewJSONReader(r)
+	for {
+		m, err := rd.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if e.Messages == 0 || m.Time.Before(e.Start) {
+			e.Start = m.Time
+		}
+		if e.Messages == 0 || m.Time.After(e.End) {
+			e.End = m.Time
+		}
+		paths[m.Path] = true
+		e.Messages++
+	}
+	e.Paths = make([]string, 0, len(paths))
+	for path := range paths {
+		e.Paths = append(e.Paths, path)
+	}
+	sort.Strings(e.Paths)
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+	e.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return e, nil
+}
+
+// Catalog is a list of archive entries, ordered by start time.
+type Catalog struct {
+	Entries []*Entry `json:"entries"`
+}
+
+// Load reads a catalog from the named manifest file.  If the file does
+// not exist, an empty catalog is returned.
+func Load(name string) (*Catalog, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &Catalog{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the catalog to the named manifest file.  The file is
+// replaced atomically, so that readers never see a partially written
+// manifest.
+func (c *Catalog) Save(name string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".catalog-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Add adds an entry to the catalog, replacing any previous entry with
+// the same name.
+func (c *Catalog) Add(e *Entry) {
+	c.Remove(e.Name)
+	i := sort.Search(len(c.Entries), func(i int) bool {
+		return c.Entries[i].Start.After(e.Start)
+	})
+	c.Entries = append(c.Entries, nil)
+	copy(c.Entries[i+1:], c.Entries[i:])
+	c.Entries[i] = e
+}
+
+// Has reports whether the catalog contains an entry with the given
+// name.
+func (c *Catalog) Has(name string) bool {
+	for _, e := range c.Entries {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes the entry with the given name from the catalog, for
+// example after the file has been deleted because of its age.
+func (c *Catalog) Remove(name string) {
+	for i, e := range c.Entries {
+		if e.Name == name {
+			c.Entries = append(c.Entries[:i], c.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Find returns the entries which may contain messages for the given
+// path (including sub-paths) in the time range from 'start' to 'end'.
+// Zero times leave the corresponding end of the range open, and the
+// empty path matches all messages.
+func (c *Catalog) Find(path string, start, end time.Time) []*Entry {
+	var res []*Entry
+	for _, e := range c.Entries {
+		if !start.IsZero() && e.End.Before(start) ||
+			!end.IsZero() && e.Start.After(end) {
+			continue
+		}
+		if path != "" && !e.hasPath(path) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// hasPath reports whether the entry contains messages for 'path' or
+// one of its sub-paths.
+func (e *Entry) hasPath(path string) bool {
+	i := sort.SearchStrings(e.Paths, path)
+	if i == len(e.Paths) {
+		return false
+	}
+	p := e.Paths[i]
+	if p == path {
+		return true
+	}
+	// Sub-paths of 'path' sort after 'path', but may be preceded by
+	// paths like "path-x" which share the same prefix.
+	for _, p := range e.Paths[i:] {
+		if !strings.HasPrefix(p, path) {
+			return false
+		}
+		if p[len(path)] == '/' {
+			return true
+		}
+	}
+	return false
+}
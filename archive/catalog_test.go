@@ -0,0 +1,130 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+var t0 = time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// writeTrace writes a trace file with one message per path at
+// one-minute intervals, starting at time 'start'.
+func writeTrace(t *testing.T, name string, start time.Time, paths ...string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if filepath.Ext(name) == ".gz" {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	jw := trace.NewJSONWriter(w)
+	for i, path := range paths {
+		jw.Listen(&trace.Message{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			Path: path,
+			Msg:  "hello",
+		})
+	}
+	if zw != nil {
+		zw.Close()
+	}
+	f.Close()
+}
+
+func TestCatalog(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.jsonl")
+	b := filepath.Join(dir, "b.jsonl.gz")
+	writeTrace(t, a, t0, "db/mysql", "net", "db/mysql")
+	writeTrace(t, b, t0.Add(time.Hour), "db-x", "http")
+
+	manifest := filepath.Join(dir, "catalog.json")
+	c, err := Load(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{b, a} {
+		e, err := Scan(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(e)
+	}
+	if err := c.Save(manifest); err != nil {
+		t.Fatal(err)
+	}
+	c, err = Load(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Entries) != 2 || c.Entries[0].Name != a {
+		t.Fatalf("wrong catalog %v", c.Entries)
+	}
+	e := c.Entries[0]
+	if e.Messages != 3 || !e.Start.Equal(t0) || !e.End.Equal(t0.Add(2*time.Minute)) ||
+		len(e.Paths) != 2 {
+		t.Errorf("wrong entry %v", e)
+	}
+	data, err := os.ReadFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(data); e.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("wrong hash %s", e.SHA256)
+	}
+	if !c.Has(a) || c.Has(a+".old") {
+		t.Error("Has failed")
+	}
+
+	testData := []struct {
+		path       string
+		start, end time.Time
+		n          int
+	}{
+		{"", time.Time{}, time.Time{}, 2},
+		{"db", time.Time{}, time.Time{}, 1},
+		{"db-x", time.Time{}, time.Time{}, 1},
+		{"http", time.Time{}, t0.Add(30 * time.Minute), 0},
+		{"", t0.Add(30 * time.Minute), time.Time{}, 1},
+		{"d", time.Time{}, time.Time{}, 0},
+	}
+	for _, test := range testData {
+		if res := c.Find(test.path, test.start, test.end); len(res) != test.n {
+			t.Errorf("%q %s %s: expected %d entries, got %d",
+				test.path, test.start, test.end, test.n, len(res))
+		}
+	}
+
+	c.Remove(a)
+	if len(c.Entries) != 1 || c.Entries[0].Name != b {
+		t.Errorf("Remove failed: %v", c.Entries)
+	}
+}
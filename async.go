@@ -0,0 +1,147 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncListener decouples a listener from the callers of T().  Messages
+// are placed in a queue of fixed capacity and are delivered to the
+// wrapped listener by a separate goroutine.  If the queue is full, new
+// messages are dropped.  Use the Listen method as the listener argument
+// of Register().  See LaneListener for a variant which uses separate
+// queues for the different priority classes.
+//
+// Whenever the queue depth reaches the high watermark, and again when
+// the depth falls back to the low watermark, a message of priority
+// PrioInfo is emitted with path "trace/async".  These messages can be
+// used to tune the queue capacity.
+type AsyncListener struct {
+	next   Listener
+	queue  chan *Message
+	worker *Supervisor
+
+	high    atomic.Int64
+	low     atomic.Int64
+	dropped atomic.Uint64
+	above   bool // only accessed by the worker goroutine
+
+	mutex  sync.RWMutex // protects closed and sending on queue
+	closed bool
+}
+
+// NewAsyncListener returns a new AsyncListener which delivers messages
+// to 'next', using a queue which can hold up to 'capacity' messages.
+// Initially, the high watermark is set to 3/4 of the capacity and the
+// low watermark is set to 1/4 of the capacity.
+func NewAsyncListener(next Listener, capacity int) *AsyncListener {
+	if capacity < 1 {
+		capacity = 1
+	}
+	a := &AsyncListener{
+		next:  next,
+		queue: make(chan *Message, capacity),
+	}
+	a.SetWatermarks((3*capacity+3)/4, capacity/4)
+	a.worker = Supervise("async listener", a.run)
+	return a
+}
+
+// SetWatermarks changes the queue depths at which the watermark
+// messages are emitted.  The value 'low' should be smaller than
+// 'high'.
+func (a *AsyncListener) SetWatermarks(high, low int) {
+	a.high.Store(int64(high))
+	a.low.Store(int64(low))
+}
+
+// Listen places a message into the queue.  If the queue is full, or if
+// Close() has been called, the message is dropped.
+func (a *AsyncListener) Listen(m *Message) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		count(m.Path, m.Prio, outDropped)
+		return
+	}
+	select {
+	case a.queue <- m:
+	default:
+		a.dropped.Add(1)
+		count(m.Path, m.Prio, outDropped)
+	}
+}
+
+// Depth returns the number of messages currently waiting in the
+// queue.
+func (a *AsyncListener) Depth() int {
+	return len(a.queue)
+}
+
+// Capacity returns the maximal number of messages which can be held in
+// the queue.
+func (a *AsyncListener) Capacity() int {
+	return cap(a.queue)
+}
+
+// Dropped returns the number of messages which were discarded because
+// the queue was full.
+func (a *AsyncListener) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// run delivers the queued messages.  If the wrapped listener panics,
+// the worker goroutine is restarted by the supervisor; the message
+// being delivered is lost.
+func (a *AsyncListener) run() error {
+	for m := range a.queue {
+		a.checkWatermarks(len(a.queue) + 1)
+		a.next(m)
+	}
+	return nil
+}
+
+func (a *AsyncListener) checkWatermarks(depth int) {
+	high := int(a.high.Load())
+	low := int(a.low.Load())
+	if !a.above && depth >= high {
+		a.above = true
+		T("trace/async", PrioInfo,
+			"queue depth %d reached high watermark %d (capacity %d)",
+			depth, high, cap(a.queue))
+	} else if a.above && depth <= low {
+		a.above = false
+		T("trace/async", PrioInfo,
+			"queue depth %d fell to low watermark %d (capacity %d)",
+			depth, low, cap(a.queue))
+	}
+}
+
+// Close stops accepting new messages, waits until all queued messages
+// have been delivered and then stops the worker goroutine.
+func (a *AsyncListener) Close() {
+	a.mutex.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mutex.Unlock()
+	a.worker.Wait()
+}
@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncListener(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		seen  []string
+	)
+	next := func(m *Message) {
+		mutex.Lock()
+		seen = append(seen, m.Msg)
+		mutex.Unlock()
+	}
+	a := NewAsyncListener(next, 10)
+	for _, msg := range []string{"a", "b", "c"} {
+		a.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: msg})
+	}
+	a.Close()
+
+	if strings.Join(seen, "") != "abc" {
+		t.Errorf("wrong messages delivered: %q", seen)
+	}
+	a.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "late"})
+	if len(seen) != 3 {
+		t.Error("message delivered after Close()")
+	}
+	if a.Dropped() != 1 {
+		t.Errorf("expected 1 dropped message, got %d", a.Dropped())
+	}
+}
+
+func TestAsyncListenerPanic(t *testing.T) {
+	defer func(d time.Duration) { superviseMinBackoff = d }(superviseMinBackoff)
+	superviseMinBackoff = time.Millisecond
+
+	var seen []string
+	next := func(m *Message) {
+		if m.Msg == "bad" {
+			panic("bad message")
+		}
+		seen = append(seen, m.Msg)
+	}
+	a := NewAsyncListener(next, 10)
+	for _, msg := range []string{"a", "bad", "b"} {
+		a.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: msg})
+	}
+	a.Close()
+
+	if strings.Join(seen, "") != "ab" {
+		t.Errorf("wrong messages delivered: %q", seen)
+	}
+	if a.worker.Restarts() != 1 {
+		t.Errorf("expected 1 restart, got %d", a.worker.Restarts())
+	}
+}
+
+func TestAsyncWatermarks(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	handle := Register(func(m *Message) {
+		mutex.Lock()
+		events = append(events, m.Msg)
+		mutex.Unlock()
+	}, "trace/async", PrioAll)
+	defer handle.Unregister()
+
+	block := make(chan struct{})
+	next := func(m *Message) {
+		<-block
+	}
+	a := NewAsyncListener(next, 4)
+	a.SetWatermarks(3, 1)
+	for i := 0; i < 6; i++ {
+		a.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "hello"})
+	}
+	if a.Capacity() != 4 {
+		t.Errorf("wrong capacity %d", a.Capacity())
+	}
+	if d := a.Depth(); d < 3 || d > 4 {
+		t.Errorf("unexpected queue depth %d", d)
+	}
+	if a.Dropped() == 0 {
+		t.Error("overflowing messages not dropped")
+	}
+	close(block)
+	a.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != 2 ||
+		!strings.Contains(events[0], "high watermark") ||
+		!strings.Contains(events[1], "low watermark") {
+		t.Errorf("wrong watermark events: %q", events)
+	}
+}
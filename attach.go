@@ -0,0 +1,113 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	attachMutex sync.RWMutex // protects attachDir
+	attachDir   string
+)
+
+// SetAttachmentDir sets the directory used by Attach() to store
+// attachment data.  The directory is created if necessary.  If 'dir' is
+// the empty string (the default), attachment data is not stored and
+// only the reference is emitted.
+func SetAttachmentDir(dir string) {
+	attachMutex.Lock()
+	attachDir = dir
+	attachMutex.Unlock()
+}
+
+// AttachmentFile returns the name of the file which holds the
+// attachment data with the given SHA-256 checksum (in hexadecimal
+// notation), or the empty string if no attachment directory is set.
+func AttachmentFile(sum string) string {
+	attachMutex.RLock()
+	dir := attachDir
+	attachMutex.RUnlock()
+	if dir == "" || len(sum) < 3 {
+		return ""
+	}
+	return filepath.Join(dir, sum[:2], sum[2:])
+}
+
+// Attach stores a large block of data, for example a request body,
+// outside the message stream and emits a trace message which refers to
+// the data by its SHA-256 checksum.  The arguments 'path' and 'prio'
+// have the same meaning as for T().  The argument 'name' is a short
+// description of the data, included in the message text.
+//
+// The data is stored in the directory set by SetAttachmentDir(), under
+// a file name derived from the checksum; identical data is stored only
+// once.  Use AttachmentFile() to locate the data for a given checksum.
+// If no listener is registered for the given path and priority, the
+// data is neither hashed nor stored.
+func Attach(path string, prio Priority, name string, data []byte) {
+	if !wanted(path, prio) {
+		return
+	}
+	// Messages are attributed to the caller of Attach.
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+
+	hash := sha256.Sum256(data)
+	sum := hex.EncodeToString(hash[:])
+	fname := AttachmentFile(sum)
+	if fname == "" {
+		TAt(pcs[0], path, prio, "attachment %q (%d bytes, sha256:%s), not stored",
+			name, len(data), sum)
+	} else if err := storeAttachment(fname, data); err != nil {
+		TAt(pcs[0], path, prio, "attachment %q (%d bytes, sha256:%s), not stored: %s",
+			name, len(data), sum, err)
+	} else {
+		TAt(pcs[0], path, prio, "attachment %q (%d bytes, sha256:%s)",
+			name, len(data), sum)
+	}
+}
+
+func storeAttachment(fname string, data []byte) error {
+	if _, err := os.Stat(fname); err == nil {
+		return nil
+	}
+	dir := filepath.Dir(fname)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fname)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
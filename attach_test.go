@@ -0,0 +1,84 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttach(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	SetAttachmentDir(dir)
+	defer SetAttachmentDir("")
+
+	var msgs []string
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m.Msg)
+	}, "attach", PrioDebug)
+
+	data := []byte("hello attachment")
+	sum := "7fa36b95d5c98859ed72b4787f3c28b29eaa103970786755c9711cbb19be631c"
+	Attach("attach/test", PrioDebug, "body", data)
+	Attach("attach/test", PrioDebug, "body again", data)
+	Attach("attach/test", PrioVerbose, "ignored", []byte("ignored"))
+	handle.Unregister()
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %q", msgs)
+	}
+	fname := AttachmentFile(sum)
+	if !strings.Contains(msgs[0], "sha256:"+sum) {
+		t.Errorf("checksum missing in %q", msgs[0])
+	}
+	if strings.Contains(msgs[0], "not stored") {
+		t.Errorf("attachment not stored: %q", msgs[0])
+	}
+	stored, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Errorf("wrong data stored: %q", stored)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(fname))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected exactly one stored file, got %v (%v)", entries, err)
+	}
+}
+
+func TestAttachCaller(t *testing.T) {
+	var msgs []*Message
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "attach", PrioDebug, CaptureCaller())
+	defer handle.Unregister()
+
+	Attach("attach/caller", PrioDebug, "body", []byte("data"))
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	if m := msgs[0]; !strings.HasSuffix(m.File, "attach_test.go") ||
+		m.Func != "github.com/seehuhn/trace.TestAttachCaller" {
+		t.Errorf("wrong caller %s:%d %s", m.File, m.Line, m.Func)
+	}
+}
@@ -0,0 +1,128 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+)
+
+// DefaultMaxBinary is the maximal number of bytes of each binary field
+// written by Console, JSONWriter and ClassicWriter, unless a different
+// limit is set.
+const DefaultMaxBinary = 1024
+
+// Binary is a binary field of a message, like the contents of a
+// network packet.  Binary fields are attached to a message by passing
+// the result of Bytes() as an argument to T().
+type Binary struct {
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+
+	// Truncated is the number of bytes at the end of Data which were
+	// left out by an output with a size limit.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// Bytes returns a special argument for T(), which attaches binary data
+// to the message as a field with the given key.  Like Route arguments,
+// binary fields do not take part in formatting the message.  Instead,
+// they are stored in the Binary field of the message, and outputs
+// render them in a suitable form: Console and ClassicWriter append
+// them to the message text as key=value pairs with the data in
+// hexadecimal, and JSONWriter stores the data in base64 encoding.  For
+// example
+//
+//	trace.T("net/dns", trace.PrioDebug, "reply from %s", addr, trace.Bytes("packet", buf))
+//
+// Each output writes at most a fixed number of bytes per field, see
+// DefaultMaxBinary.  The data is not copied and must not be modified
+// after T() has been called.
+func Bytes(key string, data []byte) Binary {
+	return Binary{Key: key, Data: data}
+}
+
+// splitBinary removes the Binary arguments from 'args' and returns them
+// separately.  The slice 'args' is not modified.
+func splitBinary(args []interface{}) ([]Binary, []interface{}) {
+	n := 0
+	for _, arg := range args {
+		if _, ok := arg.(Binary); ok {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, args
+	}
+	fields := make([]Binary, 0, n)
+	rest := make([]interface{}, 0, len(args)-n)
+	for _, arg := range args {
+		if b, ok := arg.(Binary); ok {
+			fields = append(fields, b)
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return fields, rest
+}
+
+// limitBinary returns 'fields', with the data of each field cut to at
+// most 'max' bytes.  If 'max' is zero or negative, or if no field
+// exceeds the limit, 'fields' is returned unchanged.  Otherwise a new
+// slice is returned.
+func limitBinary(fields []Binary, max int) []Binary {
+	if max <= 0 {
+		return fields
+	}
+	var res []Binary
+	for i, f := range fields {
+		if len(f.Data) <= max {
+			continue
+		}
+		if res == nil {
+			res = make([]Binary, len(fields))
+			copy(res, fields)
+		}
+		res[i].Data = f.Data[:max]
+		res[i].Truncated = f.Truncated + len(f.Data) - max
+	}
+	if res == nil {
+		return fields
+	}
+	return res
+}
+
+// writeBinary appends the binary fields to 'buf' in the form
+// " key=0a1b2c", showing at most 'max' bytes of each field.  Shortened
+// fields are written in the form ` key="0a1b2c... (1500 bytes)"`.
+func writeBinary(buf *bytes.Buffer, fields []Binary, max int) {
+	for _, f := range limitBinary(fields, max) {
+		buf.WriteByte(' ')
+		buf.WriteString(f.Key)
+		buf.WriteByte('=')
+		if f.Truncated == 0 {
+			buf.WriteString(hex.EncodeToString(f.Data))
+			continue
+		}
+		buf.WriteByte('"')
+		buf.WriteString(hex.EncodeToString(f.Data))
+		buf.WriteString("... (")
+		buf.WriteString(strconv.Itoa(len(f.Data) + f.Truncated))
+		buf.WriteString(" bytes)\"")
+	}
+}
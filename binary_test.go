@@ -0,0 +1,116 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytes(t *testing.T) {
+	var msgs []*Message
+	h := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "binary", PrioInfo)
+	defer h.Unregister()
+
+	SetStrict(true)
+	defer SetStrict(false)
+	data := []byte{0xde, 0xad, 0xbe, 0xef}
+	T("binary", PrioInfo, "reply from %s", "dns1", Bytes("packet", data), Bytes("empty", nil))
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.Msg != "reply from dns1" {
+		t.Errorf("wrong message text %q", m.Msg)
+	}
+	if len(m.Binary) != 2 || m.Binary[0].Key != "packet" ||
+		!bytes.Equal(m.Binary[0].Data, data) || m.Binary[1].Key != "empty" {
+		t.Errorf("wrong binary fields %v", m.Binary)
+	}
+}
+
+func TestLimitBinary(t *testing.T) {
+	fields := []Binary{Bytes("a", []byte("0123456789")), Bytes("b", []byte("01"))}
+	if res := limitBinary(fields, 0); &res[0] != &fields[0] {
+		t.Error("fields copied without a limit")
+	}
+	res := limitBinary(fields, 4)
+	if string(res[0].Data) != "0123" || res[0].Truncated != 6 ||
+		string(res[1].Data) != "01" || res[1].Truncated != 0 {
+		t.Errorf("wrong result %v", res)
+	}
+	if string(fields[0].Data) != "0123456789" || fields[0].Truncated != 0 {
+		t.Error("fields modified")
+	}
+}
+
+func TestBinaryWriters(t *testing.T) {
+	m := &Message{
+		Time: time.Date(2013, 1, 2, 15, 4, 5, 0, time.Local),
+		Path: "net",
+		Prio: PrioInfo,
+		Msg:  "packet",
+		Binary: []Binary{
+			Bytes("short", []byte{1, 2}),
+			Bytes("long", bytes.Repeat([]byte{0xff}, 10)),
+		},
+	}
+	const fields = ` short=0102 long="ffffffff... (10 bytes)"`
+
+	buf := &bytes.Buffer{}
+	c := NewConsole(buf)
+	c.SetMaxBinary(4)
+	c.Listen(m)
+	if !strings.HasSuffix(buf.String(), ": packet"+fields+"\n") {
+		t.Errorf("wrong console output %q", buf.String())
+	}
+
+	buf.Reset()
+	cw := NewClassicWriter(buf, "myprog")
+	cw.SetMaxBinary(4)
+	cw.Listen(m)
+	if !strings.HasSuffix(buf.String(), ": packet"+fields+"\n") {
+		t.Errorf("wrong classic output %q", buf.String())
+	}
+
+	buf.Reset()
+	j := NewJSONWriter(buf)
+	j.SetMaxBinary(4)
+	j.Listen(m)
+	j.SetMaxBinary(0)
+	j.Listen(m)
+	r := NewJSONReader(buf)
+	for _, want := range []int{4, 10} {
+		m2, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		long := m2.Binary[1]
+		if len(long.Data) != want || long.Truncated != 10-want ||
+			!bytes.Equal(m2.Binary[0].Data, []byte{1, 2}) {
+			t.Errorf("wrong binary fields %v", m2.Binary)
+		}
+	}
+	if len(m.Binary[1].Data) != 10 {
+		t.Error("message modified")
+	}
+}
@@ -18,10 +18,19 @@ package trace
 
 import (
 	"fmt"
+	"reflect"
 	"runtime"
-	"strings"
 )
 
+// traceFile is the name of the source file which contains the
+// definition of T().
+var traceFile string
+
+func init() {
+	f := runtime.FuncForPC(reflect.ValueOf(T).Pointer())
+	traceFile, _ = f.FileLine(f.Entry())
+}
+
 // Callers is a helper function to get a stack trace from within a
 // trace listener function.  The result is a list of strings, each
 // giving a Go source file name, followed by a colon and a line number
@@ -29,23 +38,38 @@ import (
 // of trace.T(), the last string corresponds to the program's main
 // function.  If Callers() is called from outside a trace listener,
 // a run-time panic is triggered.
+//
+// Listeners which only need the location of the call to trace.T()
+// should use the CaptureCaller() option of Register() instead.
 func Callers() []string {
 	res := []string{}
 
-	callToTSeen := false
-	for i := 2; ; i++ {
-		_, file, line, ok := runtime.Caller(i)
-		if !ok {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
 			break
-		} else if !callToTSeen {
-			if strings.HasSuffix(file, "github.com/seehuhn/trace/trace.go") {
-				callToTSeen = true
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+
+	callToTSeen := false
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if frame.File == traceFile {
+			callToTSeen = true
+		} else if callToTSeen {
+			if frame.Function == "runtime.main" ||
+				frame.Function == "runtime.goexit" {
+				break
 			}
-			continue
-		} else if strings.HasSuffix(file, "src/pkg/runtime/proc.c") {
+			res = append(res, fmt.Sprintf("%s:%d", frame.File, frame.Line))
+		}
+		if !more {
 			break
 		}
-		res = append(res, fmt.Sprintf("%s:%d", file, line))
 	}
 	if !callToTSeen {
 		panic("Callers() must be called from within trace listener")
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"strings"
 	"testing"
-	"time"
 )
 
 var (
@@ -54,7 +53,7 @@ func TestCallers(t *testing.T) {
 	helper2 := func() {
 		helper1()
 	}
-	handler := func(t time.Time, path string, prio Priority, msg string) {
+	handler := func(m *Message) {
 		helper2()
 	}
 
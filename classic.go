@@ -0,0 +1,113 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ClassicWriter writes trace messages to an io.Writer in the line
+// format of traditional syslog files like /var/log/messages:
+//
+//	Jan  2 15:04:05 myhost mytag[1234]: message text
+//
+// The format can be used when replacing a syslog-based logger, so that
+// existing parsers for the log files keep working.  The time stamp is
+// given in local time, the host name is shortened to its first
+// component and control characters in the message, including newlines,
+// are replaced by '#' followed by three octal digits, as rsyslog does.
+// Use the Listen method as the listener argument of Register().
+type ClassicWriter struct {
+	hostname string
+	tag      string
+	pid      int
+
+	mutex     sync.Mutex // protects w and maxBinary
+	w         io.Writer
+	maxBinary int
+}
+
+// NewClassicWriter returns a new ClassicWriter which writes messages to
+// 'w', using 'tag' in front of the process ID.  If 'tag' is the empty
+// string, the program name is used.
+func NewClassicWriter(w io.Writer, tag string) *ClassicWriter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+	hostname, _, _ = strings.Cut(hostname, ".")
+	if tag == "" {
+		tag = filepath.Base(os.Args[0])
+	}
+	return &ClassicWriter{
+		hostname:  hostname,
+		tag:       tag,
+		pid:       os.Getpid(),
+		w:         w,
+		maxBinary: DefaultMaxBinary,
+	}
+}
+
+// SetMaxBinary sets the maximal number of bytes written for each binary
+// field of a message, see Bytes().  If 'max' is zero or negative, the
+// fields are written in full.
+func (c *ClassicWriter) SetMaxBinary(max int) {
+	c.mutex.Lock()
+	c.maxBinary = max
+	c.mutex.Unlock()
+}
+
+// Listen writes a single message.  Write errors are ignored.
+func (c *ClassicWriter) Listen(m *Message) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(m.Time.Local().Format("Jan _2 15:04:05"))
+	buf.WriteByte(' ')
+	buf.WriteString(c.hostname)
+	buf.WriteByte(' ')
+	buf.WriteString(c.tag)
+	buf.WriteByte('[')
+	buf.WriteString(strconv.Itoa(c.pid))
+	buf.WriteString("]: ")
+	text := m.Msg
+	if len(m.Binary) > 0 {
+		c.mutex.Lock()
+		max := c.maxBinary
+		c.mutex.Unlock()
+		fields := &bytes.Buffer{}
+		writeBinary(fields, m.Binary, max)
+		text += fields.String()
+	}
+	for i := 0; i < len(text); i++ {
+		if b := text[i]; b < 32 || b == 127 {
+			fmt.Fprintf(buf, "#%03o", b)
+		} else {
+			buf.WriteByte(b)
+		}
+	}
+	buf.WriteByte('\n')
+
+	c.mutex.Lock()
+	c.w.Write(buf.Bytes())
+	c.mutex.Unlock()
+}
@@ -0,0 +1,55 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestClassicWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := NewClassicWriter(buf, "myprog")
+	c.hostname = "host"
+	c.pid = 1234
+	testData := []struct {
+		when time.Time
+		msg  string
+		out  string
+	}{
+		{time.Date(2013, 1, 2, 15, 4, 5, 0, time.Local), "hello",
+			"Jan  2 15:04:05 host myprog[1234]: hello\n"},
+		{time.Date(2013, 11, 20, 8, 0, 9, 999, time.Local), "two\nlines\ttab",
+			"Nov 20 08:00:09 host myprog[1234]: two#012lines#011tab\n"},
+	}
+	for _, test := range testData {
+		buf.Reset()
+		c.Listen(&Message{Time: test.when, Path: "a", Prio: PrioInfo, Msg: test.msg})
+		if got := buf.String(); got != test.out {
+			t.Errorf("expected %q, got %q", test.out, got)
+		}
+	}
+
+	buf.Reset()
+	NewClassicWriter(buf, "").Listen(&Message{Time: time.Now(), Msg: "x"})
+	pattern := regexp.MustCompile(`^[A-Z][a-z]{2} [ 1-3][0-9] \d\d:\d\d:\d\d [^ .]+ [^ ]+\[\d+\]: x\n$`)
+	if !pattern.MatchString(buf.String()) {
+		t.Errorf("wrong line %q", buf.String())
+	}
+}
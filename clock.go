@@ -0,0 +1,109 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ClockInfo describes the clock used for message timestamps.  Message
+// times are read using time.Now(); they include a monotonic clock
+// reading while the message is in memory, but only the wall clock time
+// is preserved when messages are serialized.  The offset of the wall
+// clock to a reference clock can be measured using
+// MeasureClockOffset().
+type ClockInfo struct {
+	// NTPServer and NTPOffset give the result of the most recent call
+	// to MeasureClockOffset(): the offset is the amount by which the
+	// local clock is behind the server's clock.  If no measurement has
+	// been made, NTPServer is empty.
+	NTPServer string
+	NTPOffset time.Duration
+
+	// Measured is the time of the measurement.
+	Measured time.Time
+}
+
+var (
+	clockMutex sync.Mutex // protects clockInfo
+	clockInfo  ClockInfo
+)
+
+// Clock returns information about the clock used for message
+// timestamps, for example to include in the metadata of trace files or
+// exported records.
+func Clock() ClockInfo {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	return clockInfo
+}
+
+// ntpEpochOffset is the number of seconds between the NTP epoch
+// (1900-01-01) and the Unix epoch (1970-01-01).
+const ntpEpochOffset = 2208988800
+
+// MeasureClockOffset queries the NTP server 'server' (for example
+// "pool.ntp.org:123") using the SNTP protocol, and records the offset of
+// the local clock in the information returned by Clock().  The result
+// is also emitted as a trace message for the path "trace".  A program
+// typically calls MeasureClockOffset once at startup.
+func MeasureClockOffset(server string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x1b // leap indicator 0, version 3, mode 3 (client)
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 || resp[0]&0x07 != 4 {
+		return 0, errors.New("invalid NTP response from " + server)
+	}
+	t2 := ntpTime(resp[32:40])
+	t3 := ntpTime(resp[40:48])
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+
+	clockMutex.Lock()
+	clockInfo.NTPServer = server
+	clockInfo.NTPOffset = offset
+	clockInfo.Measured = t4
+	clockMutex.Unlock()
+	T("trace", PrioInfo, "clock offset to NTP server %s: %s", server, offset)
+	return offset, nil
+}
+
+// ntpTime decodes a 64 bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := uint64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(secs, int64(frac*1e9>>32))
+}
@@ -0,0 +1,64 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// putNTPTime encodes 't' as a 64 bit NTP timestamp.
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32(uint64(t.Nanosecond())<<32/1e9))
+}
+
+func TestMeasureClockOffset(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on UDP:", err)
+	}
+	defer conn.Close()
+	const skew = 3 * time.Second
+	go func() {
+		buf := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := make([]byte, 48)
+		resp[0] = 0x1c // version 3, mode 4 (server)
+		now := time.Now().Add(skew)
+		putNTPTime(resp[32:40], now)
+		putNTPTime(resp[40:48], now)
+		conn.WriteTo(resp, addr)
+	}()
+
+	server := conn.LocalAddr().String()
+	offset, err := MeasureClockOffset(server, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := offset - skew; d < -100*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("expected offset %s, got %s", skew, offset)
+	}
+	if c := Clock(); c.NTPServer != server || c.NTPOffset != offset {
+		t.Errorf("wrong clock info %+v", c)
+	}
+}
@@ -0,0 +1,247 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Trace-tui is an interactive terminal viewer for trace messages.
+//
+// Usage:
+//
+//	trace-tui [-f] source...
+//
+// Each source is either the name of a trace file, as written by
+// trace.JSONWriter, or the URL of a live-tail endpoint as provided by
+// admin.TailHandler(), for example
+// "http://localhost:8080/debug/trace/tail?prio=all".  With the -f
+// flag, trace files are followed as they grow.
+//
+// The screen shows the tree of message paths on the left and the
+// messages on the right.  The following keys are available:
+//
+//	q          quit
+//	/          edit the text filter (enter or escape to finish)
+//	tab        switch between the message list and the path tree
+//	enter      in the path tree: show only messages for the selected
+//	           subtree, or show all messages again
+//	space      pause or resume the display of new messages
+//	c,e,i,d,v  toggle critical, error, info, debug and verbose messages
+//	↑↓ PgUp PgDn  scroll the message list or move in the path tree
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+var follow = flag.Bool("f", false, "follow trace files as they grow")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: trace-tui [-f] file|url...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	msgs := make(chan *trace.Message, 1024)
+	errs := make(chan error, flag.NArg())
+	for _, src := range flag.Args() {
+		go func(src string) {
+			if err := readSource(src, *follow, msgs); err != nil {
+				errs <- fmt.Errorf("%s: %s", src, err)
+			}
+		}(src)
+	}
+
+	if err := run(msgs, errs); err != nil {
+		fmt.Fprintln(os.Stderr, "trace-tui:", err)
+		os.Exit(1)
+	}
+}
+
+// readSource reads messages from a trace file or a live-tail URL.
+func readSource(src string, follow bool, out chan<- *trace.Message) error {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := http.Get(src)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("server returned %s", resp.Status)
+		}
+		return readLines(resp.Body, false, out)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readLines(f, follow, out)
+}
+
+// readLines reads messages, one JSON object per line, from 'r'.  If
+// 'follow' is set, reading continues at the end of the input, waiting
+// for more data to be appended.
+func readLines(r io.Reader, follow bool, out chan<- *trace.Message) error {
+	rd := bufio.NewReader(r)
+	var line []byte
+	for {
+		part, err := rd.ReadBytes('\n')
+		line = append(line, part...)
+		if err == io.EOF && follow {
+			time.Sleep(200 * time.Millisecond)
+			continue
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			m := &trace.Message{}
+			if e := json.Unmarshal(line, m); e != nil {
+				return e
+			}
+			out <- m
+		}
+		line = line[:0]
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// run shows the user interface until the user quits.
+func run(msgs <-chan *trace.Message, errs <-chan error) error {
+	restore, err := makeRaw(0)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	out := bufio.NewWriter(os.Stdout)
+	out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		out.WriteString("\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	v := newModel()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	dirty := true
+	var lastErr string
+	for {
+		if w, h, err := termSize(1); err == nil && w > 0 && h > 0 &&
+			(w != v.width || h != v.height) {
+			v.width, v.height = w, h
+			dirty = true
+		}
+		if dirty {
+			draw(out, v, lastErr)
+			dirty = false
+		}
+		select {
+		case m := <-msgs:
+			v.add(m)
+			if !v.paused {
+				dirty = true
+			}
+		case k, ok := <-keys:
+			if !ok || !v.key(k) {
+				return nil
+			}
+			dirty = true
+		case err := <-errs:
+			lastErr = err.Error()
+			dirty = true
+		case <-resize:
+			dirty = true
+		case <-ticker.C:
+		}
+	}
+}
+
+func draw(out *bufio.Writer, v *model, lastErr string) {
+	lines := v.render()
+	if lastErr != "" && len(lines) > 1 {
+		lines[len(lines)-1] = fit("error: "+lastErr, v.width)
+	}
+	for i, line := range lines {
+		fmt.Fprintf(out, "\x1b[%d;1H", i+1)
+		if i == 0 || i == len(lines)-1 {
+			out.WriteString("\x1b[7m" + pad(line, v.width) + "\x1b[0m")
+		} else {
+			out.WriteString(line)
+			out.WriteString("\x1b[K")
+		}
+	}
+	out.Flush()
+}
+
+// readKeys splits the terminal input into key presses, keeping escape
+// sequences for cursor keys together.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, k := range splitKeys(string(buf[:n])) {
+			keys <- k
+		}
+	}
+}
+
+func splitKeys(s string) []string {
+	var res []string
+	for len(s) > 0 {
+		n := 1
+		if s[0] == '\x1b' && len(s) >= 3 && s[1] == '[' {
+			n = 2
+			for n < len(s) && (s[n] < '@' || s[n] > '~') {
+				n++
+			}
+			if n < len(s) {
+				n++
+			}
+		} else if s[0] >= 0x80 {
+			for n < len(s) && s[n]&0xC0 == 0x80 {
+				n++
+			}
+		}
+		res = append(res, s[:n])
+		s = s[n:]
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerminal = errors.New("terminal control is not supported on this platform")
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errNoTerminal
+}
+
+func termSize(fd int) (int, int, error) {
+	return 0, 0, errNoTerminal
+}
+
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,391 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/seehuhn/trace"
+)
+
+// Priority classes, used for the priority toggle keys.
+const (
+	classCritical = iota
+	classError
+	classInfo
+	classDebug
+	classVerbose
+	numClasses
+)
+
+var classKeys = [numClasses]byte{'c', 'e', 'i', 'd', 'v'}
+var classLetters = [numClasses]string{"C", "E", "I", "D", "V"}
+
+func prioClass(prio trace.Priority) int {
+	switch {
+	case prio >= trace.PrioCritical:
+		return classCritical
+	case prio >= trace.PrioError:
+		return classError
+	case prio >= trace.PrioInfo:
+		return classInfo
+	case prio >= trace.PrioDebug:
+		return classDebug
+	default:
+		return classVerbose
+	}
+}
+
+// Input focus of the viewer.
+const (
+	focusMessages = iota
+	focusTree
+	focusFilter
+)
+
+const treeWidth = 28
+
+// maxMessages is the maximal number of messages kept for scrollback.
+const maxMessages = 100000
+
+// model holds the state of the viewer.
+type model struct {
+	msgs     []*trace.Message
+	counts   map[string]int // number of messages per path prefix
+	paused   bool
+	pausedAt int // number of messages visible while paused
+
+	filter  string
+	pathSel string
+	hidden  [numClasses]bool
+
+	focus   int
+	scroll  int // number of matching messages hidden below the view
+	treeSel int
+
+	width, height int
+}
+
+func newModel() *model {
+	return &model{
+		counts: map[string]int{},
+		width:  80,
+		height: 24,
+	}
+}
+
+// add stores a new message.
+func (v *model) add(m *trace.Message) {
+	if len(v.msgs) >= maxMessages {
+		drop := len(v.msgs) / 10
+		for _, old := range v.msgs[:drop] {
+			v.count(old.Path, -1)
+		}
+		v.msgs = append(v.msgs[:0], v.msgs[drop:]...)
+		if v.pausedAt > drop {
+			v.pausedAt -= drop
+		} else {
+			v.pausedAt = 0
+		}
+	}
+	v.msgs = append(v.msgs, m)
+	v.count(m.Path, 1)
+}
+
+// count adjusts the message counts for 'path' and all its prefixes.
+func (v *model) count(path string, delta int) {
+	for i := 0; i <= len(path); i++ {
+		if i == 0 || i == len(path) || path[i] == '/' {
+			key := path[:i]
+			v.counts[key] += delta
+			if v.counts[key] <= 0 {
+				delete(v.counts, key)
+			}
+		}
+	}
+}
+
+// matches decides whether a message is shown, given the current
+// filter settings.
+func (v *model) matches(m *trace.Message) bool {
+	if v.hidden[prioClass(m.Prio)] {
+		return false
+	}
+	if v.pathSel != "" && m.Path != v.pathSel &&
+		!strings.HasPrefix(m.Path, v.pathSel+"/") {
+		return false
+	}
+	if v.filter != "" && !strings.Contains(m.Msg, v.filter) &&
+		!strings.Contains(m.Path, v.filter) {
+		return false
+	}
+	return true
+}
+
+// visible returns the messages matching the current filter settings.
+func (v *model) visible() []*trace.Message {
+	msgs := v.msgs
+	if v.paused {
+		msgs = msgs[:v.pausedAt]
+	}
+	var res []*trace.Message
+	for _, m := range msgs {
+		if v.matches(m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// treePaths returns the sorted list of all paths and path prefixes
+// seen so far.
+func (v *model) treePaths() []string {
+	paths := make([]string, 0, len(v.counts))
+	for path := range v.counts {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (v *model) togglePause() {
+	v.paused = !v.paused
+	v.pausedAt = len(v.msgs)
+}
+
+// key processes a single key press.  The return value is false if the
+// viewer should exit.
+func (v *model) key(k string) bool {
+	if v.focus == focusFilter {
+		switch k {
+		case "\r", "\n", "\x1b", "\t":
+			v.focus = focusMessages
+		case "\x7f", "\b":
+			if n := len(v.filter); n > 0 {
+				_, size := utf8.DecodeLastRuneInString(v.filter)
+				v.filter = v.filter[:n-size]
+			}
+		default:
+			if len(k) > 0 && k[0] >= ' ' && k != "\x7f" {
+				v.filter += k
+			}
+		}
+		v.scroll = 0
+		return true
+	}
+
+	page := v.height - 3
+	if page < 1 {
+		page = 1
+	}
+	switch k {
+	case "q", "\x03":
+		return false
+	case "/":
+		v.focus = focusFilter
+	case "\t":
+		if v.focus == focusTree {
+			v.focus = focusMessages
+		} else {
+			v.focus = focusTree
+		}
+	case " ":
+		v.togglePause()
+	case "\x1b[A":
+		v.move(1)
+	case "\x1b[B":
+		v.move(-1)
+	case "\x1b[5~":
+		v.move(page)
+	case "\x1b[6~":
+		v.move(-page)
+	case "\r", "\n":
+		if v.focus == focusTree {
+			paths := v.treePaths()
+			if v.treeSel < len(paths) {
+				if v.pathSel == paths[v.treeSel] {
+					v.pathSel = ""
+				} else {
+					v.pathSel = paths[v.treeSel]
+				}
+				v.scroll = 0
+			}
+		}
+	default:
+		for i, c := range classKeys {
+			if k == string(c) {
+				v.hidden[i] = !v.hidden[i]
+				v.scroll = 0
+			}
+		}
+	}
+	return true
+}
+
+// move scrolls the message list, or moves the tree selection, by 'n'
+// lines upwards.
+func (v *model) move(n int) {
+	if v.focus == focusTree {
+		v.treeSel -= n
+		if last := len(v.counts) - 2; v.treeSel > last {
+			v.treeSel = last
+		}
+		if v.treeSel < 0 {
+			v.treeSel = 0
+		}
+		return
+	}
+	v.scroll += n
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+}
+
+// render returns the screen contents, one string per line.
+func (v *model) render() []string {
+	lines := make([]string, v.height)
+	rows := v.height - 2
+	if rows < 1 {
+		return lines
+	}
+
+	var prios []string
+	for i, letter := range classLetters {
+		if v.hidden[i] {
+			letter = "-"
+		}
+		prios = append(prios, letter)
+	}
+	status := "live"
+	if v.paused {
+		status = "PAUSED"
+	}
+	filter := v.filter
+	if v.focus == focusFilter {
+		filter += "_"
+	}
+	header := fmt.Sprintf("trace-tui [%s]  prio: %s  path: %s  filter: %s",
+		status, strings.Join(prios, ""), sanitize(orAll(v.pathSel)), filter)
+	lines[0] = fit(header, v.width)
+
+	tree := v.renderTree(rows)
+	msgWidth := v.width - treeWidth - 1
+	msgs := v.visible()
+	if top := len(msgs) - rows; v.scroll > top {
+		v.scroll = top
+	}
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+	end := len(msgs) - v.scroll
+	start := end - rows
+	if start < 0 {
+		start = 0
+	}
+	for row := 0; row < rows; row++ {
+		var text string
+		if idx := start + row; idx < end {
+			text = formatMessage(msgs[idx])
+		}
+		lines[row+1] = pad(tree[row], treeWidth) + "│" + fit(text, msgWidth)
+	}
+
+	lines[v.height-1] = fit("q quit  / filter  tab tree  enter select path"+
+		"  space pause  c/e/i/d/v priorities  ↑↓ PgUp PgDn scroll", v.width)
+	return lines
+}
+
+func (v *model) renderTree(rows int) []string {
+	tree := make([]string, rows)
+	paths := v.treePaths()
+	first := 0
+	if v.treeSel >= rows {
+		first = v.treeSel - rows + 1
+	}
+	for row := 0; row < rows && first+row < len(paths); row++ {
+		idx := first + row
+		path := paths[idx]
+		depth := strings.Count(path, "/")
+		name := path[strings.LastIndex(path, "/")+1:]
+		mark := " "
+		if path == v.pathSel {
+			mark = "*"
+		}
+		if v.focus == focusTree && idx == v.treeSel {
+			mark = ">"
+		}
+		tree[row] = fmt.Sprintf("%s%s%s %d", mark,
+			strings.Repeat(" ", depth), sanitize(name), v.counts[path])
+	}
+	return tree
+}
+
+func formatMessage(m *trace.Message) string {
+	return fmt.Sprintf("%s %s %s: %s", m.Time.Format("15:04:05.000"),
+		classLetters[prioClass(m.Prio)], sanitize(m.Path), sanitize(m.Msg))
+}
+
+// sanitize replaces the control characters in 's', so that messages
+// from remote senders cannot move the cursor or change the terminal
+// state using escape sequences.  Newlines are shown as "⏎", tabs as
+// spaces, and all other control characters as U+FFFD.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return '⏎'
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return utf8.RuneError
+		}
+		return r
+	}, s)
+}
+
+func orAll(path string) string {
+	if path == "" {
+		return "(all)"
+	}
+	return path
+}
+
+// fit truncates 's' to at most 'width' characters.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// pad truncates or pads 's' to exactly 'width' characters.
+func pad(s string, width int) string {
+	s = fit(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
@@ -0,0 +1,185 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func testModel() *model {
+	v := newModel()
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	v.add(&trace.Message{Time: when, Path: "net/http", Prio: trace.PrioError, Msg: "timeout"})
+	v.add(&trace.Message{Time: when, Path: "net/dns", Prio: trace.PrioDebug, Msg: "lookup"})
+	v.add(&trace.Message{Time: when, Path: "db", Prio: trace.PrioInfo, Msg: "connected"})
+	return v
+}
+
+func TestTree(t *testing.T) {
+	v := testModel()
+	paths := v.treePaths()
+	if !reflect.DeepEqual(paths, []string{"db", "net", "net/dns", "net/http"}) {
+		t.Errorf("wrong tree %q", paths)
+	}
+	if v.counts["net"] != 2 || v.counts[""] != 3 {
+		t.Errorf("wrong counts %v", v.counts)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	v := testModel()
+	if n := len(v.visible()); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+
+	v.key("d")
+	if n := len(v.visible()); n != 2 {
+		t.Errorf("debug messages not hidden: %d visible", n)
+	}
+	v.key("d")
+
+	v.key("\t")
+	v.key("\x1b[B")
+	v.key("\r")
+	if v.pathSel != "net" {
+		t.Errorf("wrong path selected: %q", v.pathSel)
+	}
+	if n := len(v.visible()); n != 2 {
+		t.Errorf("path filter failed: %d visible", n)
+	}
+	v.key("\r")
+	v.key("\t")
+
+	for _, k := range []string{"/", "t", "i", "m", "x", "\x7f", "\r"} {
+		v.key(k)
+	}
+	if v.filter != "tim" {
+		t.Errorf("wrong filter %q", v.filter)
+	}
+	msgs := v.visible()
+	if len(msgs) != 1 || msgs[0].Msg != "timeout" {
+		t.Errorf("text filter failed: %v", msgs)
+	}
+}
+
+func TestPause(t *testing.T) {
+	v := testModel()
+	v.key(" ")
+	v.add(&trace.Message{Path: "late", Msg: "later"})
+	if n := len(v.visible()); n != 3 {
+		t.Errorf("new message shown while paused")
+	}
+	v.key(" ")
+	if n := len(v.visible()); n != 4 {
+		t.Errorf("new message not shown after resume")
+	}
+}
+
+func TestRender(t *testing.T) {
+	v := testModel()
+	v.width, v.height = 100, 6
+	lines := v.render()
+	if len(lines) != 6 {
+		t.Fatalf("wrong number of lines %d", len(lines))
+	}
+	if !strings.Contains(lines[0], "prio: CEIDV") {
+		t.Errorf("wrong header %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[3], "12:30:00.000 I db: connected") {
+		t.Errorf("wrong last message line %q", lines[3])
+	}
+	if !strings.HasPrefix(lines[1], " db 1") {
+		t.Errorf("wrong tree line %q", lines[1])
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{"plain text", "plain text"},
+		{"two\nlines", "two⏎lines"},
+		{"a\tb", "a b"},
+		{"\x1b[2Jcleared", "\ufffd[2Jcleared"},
+		{"bell\a\r", "bell\ufffd\ufffd"},
+		{"c1\u009b1m", "c1\ufffd1m"},
+		{"ünïcode", "ünïcode"},
+	}
+	for _, c := range cases {
+		if out := sanitize(c.in); out != c.out {
+			t.Errorf("sanitize(%q) = %q, want %q", c.in, out, c.out)
+		}
+	}
+
+	m := &trace.Message{
+		Time: time.Date(2024, 1, 2, 12, 30, 0, 0, time.UTC),
+		Path: "db\x1b[31m",
+		Prio: trace.PrioInfo,
+		Msg:  "\x1b]0;title\a",
+	}
+	line := formatMessage(m)
+	if strings.ContainsAny(line, "\x1b\a") {
+		t.Errorf("control characters in %q", line)
+	}
+}
+
+func TestSanitizeTree(t *testing.T) {
+	v := testModel()
+	path := "evil\x1b[2J/x\x1b[31m"
+	v.add(&trace.Message{Path: path, Prio: trace.PrioInfo, Msg: "hello"})
+	v.pathSel = path
+	v.width, v.height = 100, 10
+
+	for _, line := range v.renderTree(8) {
+		if strings.ContainsRune(line, '\x1b') {
+			t.Errorf("control characters in tree line %q", line)
+		}
+	}
+	if header := v.render()[0]; strings.ContainsRune(header, '\x1b') {
+		t.Errorf("control characters in header %q", header)
+	}
+}
+
+func TestSplitKeys(t *testing.T) {
+	keys := splitKeys("a\x1b[A\x1b[5~ü\r")
+	expected := []string{"a", "\x1b[A", "\x1b[5~", "ü", "\r"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("wrong keys %q", keys)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	input := `{"time":"2013-05-01T12:30:00Z","path":"a","prio":0,"msg":"x"}` +
+		"\n\n" + `{"time":"2013-05-01T12:30:01Z","path":"b","prio":1000,"msg":"y"}`
+	out := make(chan *trace.Message, 10)
+	if err := readLines(strings.NewReader(input), false, out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	var paths []string
+	for m := range out {
+		paths = append(paths, m.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"a", "b"}) {
+		t.Errorf("wrong messages %q", paths)
+	}
+}
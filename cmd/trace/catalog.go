@@ -0,0 +1,89 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seehuhn/trace/archive"
+)
+
+func runCatalog(args []string) error {
+	flags := newFlagSet("catalog")
+	manifest := flags.String("m", "catalog.json", "name of the catalog file")
+	remove := flags.Bool("d", false, "remove the files from the catalog")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no trace files given")
+	}
+
+	c, err := archive.Load(*manifest)
+	if err != nil {
+		return err
+	}
+	for _, name := range flags.Args() {
+		if *remove {
+			c.Remove(name)
+			continue
+		}
+		e, err := archive.Scan(name)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		c.Add(e)
+	}
+	return c.Save(*manifest)
+}
+
+// parseTime parses a time given on the command line, in RFC 3339
+// format.  The empty string gives the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func runLocate(args []string) error {
+	flags := newFlagSet("locate")
+	manifest := flags.String("m", "catalog.json", "name of the catalog file")
+	path := flags.String("path", "", "only show files with messages for this path")
+	from := flags.String("from", "", "start of the time range, in RFC 3339 format")
+	to := flags.String("to", "", "end of the time range, in RFC 3339 format")
+	flags.Parse(args)
+
+	start, err := parseTime(*from)
+	if err != nil {
+		return err
+	}
+	end, err := parseTime(*to)
+	if err != nil {
+		return err
+	}
+	c, err := archive.Load(*manifest)
+	if err != nil {
+		return err
+	}
+	for _, e := range c.Find(*path, start, end) {
+		fmt.Printf("%s\t%s\t%s\t%d\n", e.Name,
+			e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339), e.Size)
+	}
+	return nil
+}
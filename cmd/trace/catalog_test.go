@@ -0,0 +1,38 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	when, err := parseTime("2013-05-01T12:30:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !when.Equal(time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("wrong time %s", when)
+	}
+	if when, err := parseTime(""); err != nil || !when.IsZero() {
+		t.Errorf("expected zero time, got %s, %v", when, err)
+	}
+	if _, err := parseTime("yesterday"); err == nil {
+		t.Error("missing error")
+	}
+}
@@ -0,0 +1,96 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// correlated is a message found by the correlate command, together
+// with the name of the trace file it was read from.
+type correlated struct {
+	source string
+	m      *trace.Message
+}
+
+// correlate collects the messages mentioning the operation ID 'id' as
+// a whole word from all the named trace files, and returns them in the
+// order in which they were sent.
+func correlate(files []string, id string) ([]correlated, error) {
+	var res []correlated
+	for _, name := range files {
+		err := readMessages([]string{name}, func(m *trace.Message) error {
+			if mentions(m.Msg, id) {
+				res = append(res, correlated{name, m})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].m.Time.Before(res[j].m.Time)
+	})
+	return res, nil
+}
+
+// writeTimeline prints the messages 'msgs', one line per message,
+// giving the time relative to the first message.
+func writeTimeline(w io.Writer, msgs []correlated) {
+	if len(msgs) == 0 {
+		return
+	}
+	start := msgs[0].m.Time
+	for _, c := range msgs {
+		offset := c.m.Time.Sub(start).Round(time.Microsecond)
+		fmt.Fprintf(w, "%-30s +%-10s %s  %s [%s]: %s\n",
+			c.m.Time.Format(time.RFC3339Nano), offset, c.source,
+			c.m.Path, c.m.Prio, c.m.Msg)
+	}
+}
+
+func runCorrelate(args []string) error {
+	flags := newFlagSet("correlate")
+	asJSON := flags.Bool("json", false, "print the messages in trace file format")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errors.New("need an operation ID and at least one trace file")
+	}
+
+	msgs, err := correlate(flags.Args()[1:], flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		w := trace.NewJSONWriter(os.Stdout)
+		for _, c := range msgs {
+			w.Listen(c.m)
+		}
+		return nil
+	}
+	writeTimeline(os.Stdout, msgs)
+	return nil
+}
@@ -0,0 +1,82 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestCorrelate(t *testing.T) {
+	start := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	var files []string
+	for _, f := range []struct {
+		name string
+		msgs map[int]string
+	}{
+		{"frontend.jsonl", map[int]string{0: "request req-17 received", 5: "request req-17 done", 6: "request req-170 received"}},
+		{"backend.jsonl", map[int]string{2: "query for req-17", 3: "unrelated"}},
+	} {
+		name := filepath.Join(dir, f.name)
+		out, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := trace.NewJSONWriter(out)
+		for ms, msg := range f.msgs {
+			w.Listen(&trace.Message{
+				Time: start.Add(time.Duration(ms) * time.Millisecond),
+				Path: "test",
+				Msg:  msg,
+			})
+		}
+		out.Close()
+		files = append(files, name)
+	}
+
+	msgs, err := correlate(files, "req-17")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"request req-17 received", "query for req-17",
+		"request req-17 done"}
+	if len(msgs) != len(expected) {
+		t.Fatalf("expected %d messages, got %d", len(expected), len(msgs))
+	}
+	for i, msg := range expected {
+		if msgs[i].m.Msg != msg {
+			t.Errorf("%d: expected %q, got %q", i, msg, msgs[i].m.Msg)
+		}
+	}
+	if filepath.Base(msgs[1].source) != "backend.jsonl" {
+		t.Errorf("wrong source %q", msgs[1].source)
+	}
+
+	buf := &bytes.Buffer{}
+	writeTimeline(buf, msgs)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], "+5ms") {
+		t.Errorf("wrong timeline:\n%s", buf.String())
+	}
+}
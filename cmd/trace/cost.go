@@ -0,0 +1,68 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seehuhn/trace"
+)
+
+// writeCost prints the volume statistics 'report', one line per path
+// and sink.
+func writeCost(w io.Writer, report []trace.VolumeStat) {
+	fmt.Fprintf(w, "%-30s %-20s %8s %12s %14s %10s\n",
+		"PATH", "SINK", "MSGS", "BYTES", "BYTES/DAY", "COST/DAY")
+	var total float64
+	for _, stat := range report {
+		fmt.Fprintf(w, "%-30s %-20s %8d %12d %14.0f %10.2f\n", stat.Path,
+			stat.Sink, stat.Messages, stat.Bytes, stat.BytesPerDay, stat.CostPerDay)
+		total += stat.CostPerDay
+	}
+	fmt.Fprintf(w, "%-30s %-20s %8s %12s %14s %10.2f\n", "(total)", "", "", "", "", total)
+}
+
+func runCost(args []string) error {
+	flags := newFlagSet("cost")
+	price := flags.Float64("price", 0.5, "storage price per gigabyte")
+	flags.Parse(args)
+
+	v := trace.NewVolumeCounter()
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+	for _, name := range files {
+		var names []string
+		sink := "stdin"
+		if name != "" {
+			names = []string{name}
+			sink = name
+		}
+		err := readMessages(names, func(m *trace.Message) error {
+			v.Add(sink, m)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writeCost(os.Stdout, v.Report(map[string]float64{"*": *price}))
+	return nil
+}
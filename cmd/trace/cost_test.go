@@ -0,0 +1,44 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestWriteCost(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeCost(buf, []trace.VolumeStat{
+		{Path: "http", Sink: "a.jsonl", Messages: 10, Bytes: 1000, BytesPerDay: 1e9, CostPerDay: 0.5},
+		{Path: "db", Sink: "a.jsonl", Messages: 1, Bytes: 100, BytesPerDay: 1e8, CostPerDay: 0.05},
+	})
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("wrong output:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") !=
+		"http a.jsonl 10 1000 1000000000 0.50" {
+		t.Errorf("wrong line %q", lines[1])
+	}
+	if fields := strings.Fields(lines[3]); strings.Join(fields, " ") != "(total) 0.55" {
+		t.Errorf("wrong total %q", lines[3])
+	}
+}
@@ -0,0 +1,284 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/archive"
+)
+
+// tracePackage is the import path of the trace package.
+const tracePackage = "github.com/seehuhn/trace"
+
+// callSite is a call to trace.T() found in the source code.
+type callSite struct {
+	File   string
+	Line   int
+	Path   string
+	Format string
+
+	rel   string // file name relative to the parent of the source tree
+	re    *regexp.Regexp
+	fired bool
+}
+
+// findCallSites returns the calls to trace.T() in the Go source files
+// in the directory trees 'dirs'.  Test files are skipped, and so are
+// calls where the path or the format are not string literals, since
+// these cannot be matched against the recorded messages.
+func findCallSites(dirs []string) ([]*callSite, error) {
+	var sites []*callSite
+	fset := token.NewFileSet()
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		parent := filepath.Dir(abs)
+		err = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(name, ".go") ||
+				strings.HasSuffix(name, "_test.go") {
+				return err
+			}
+			f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+			if err != nil {
+				return err
+			}
+			absName, err := filepath.Abs(name)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(parent, absName)
+			if err != nil {
+				return err
+			}
+			for _, site := range fileCallSites(fset, f) {
+				site.rel = filepath.ToSlash(rel)
+				sites = append(sites, site)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sites, nil
+}
+
+// fileCallSites returns the calls to trace.T() in a parsed source file.
+func fileCallSites(fset *token.FileSet, f *ast.File) []*callSite {
+	local := ""
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == tracePackage {
+			local = "trace"
+			if imp.Name != nil {
+				local = imp.Name.Name
+			}
+		}
+	}
+	inTrace := f.Name.Name == "trace" && local == ""
+	if local == "" && !inTrace {
+		return nil
+	}
+
+	var sites []*callSite
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 3 {
+			return true
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			x, ok := fun.X.(*ast.Ident)
+			if !ok || x.Name != local || fun.Sel.Name != "T" {
+				return true
+			}
+		case *ast.Ident:
+			if !inTrace || fun.Name != "T" {
+				return true
+			}
+		default:
+			return true
+		}
+		path, ok1 := stringLit(call.Args[0])
+		format, ok2 := stringLit(call.Args[2])
+		if !ok1 || !ok2 {
+			return true
+		}
+		pos := fset.Position(call.Pos())
+		sites = append(sites, &callSite{
+			File:   filepath.ToSlash(pos.Filename),
+			Line:   pos.Line,
+			Path:   path,
+			Format: format,
+			re:     formatRegexp(format),
+		})
+		return true
+	})
+	return sites
+}
+
+// stringLit returns the value of 'e' if it is a string literal.
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// formatRegexp returns a regular expression which matches all message
+// texts the format string 'format' can produce.
+func formatRegexp(format string) *regexp.Regexp {
+	buf := &strings.Builder{}
+	buf.WriteString(`(?s)^`)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			j := strings.IndexByte(format[i:], '%')
+			if j < 0 {
+				j = len(format) - i
+			}
+			buf.WriteString(regexp.QuoteMeta(format[i : i+j]))
+			i += j - 1
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			buf.WriteString("%")
+			continue
+		}
+		for i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 {
+			i++
+		}
+		buf.WriteString(".*")
+	}
+	buf.WriteString("$")
+	return regexp.MustCompile(buf.String())
+}
+
+// matches reports whether the message 'm' may have been produced by the
+// call site.  If caller information is available, the source location
+// is compared, where the file name of the message must end in the name
+// of the source file relative to the parent of the scanned directory;
+// otherwise the message text is matched against the
+// format string.
+func (site *callSite) matches(m *trace.Message) bool {
+	if m.Path != site.Path {
+		return false
+	}
+	if m.File != "" {
+		file := filepath.ToSlash(m.File)
+		return m.Line == site.Line &&
+			(file == site.rel || strings.HasSuffix(file, "/"+site.rel))
+	}
+	return site.re.MatchString(m.Msg)
+}
+
+// markFired records which call sites have produced at least one of the
+// messages in the named trace files, sent in the time range from
+// 'start' to 'end'.  Zero times leave the corresponding end of the
+// range open.
+func markFired(sites []*callSite, files []string, start, end time.Time) error {
+	byPath := map[string][]*callSite{}
+	for _, site := range sites {
+		byPath[site.Path] = append(byPath[site.Path], site)
+	}
+	return readMessages(files, func(m *trace.Message) error {
+		if !start.IsZero() && m.Time.Before(start) ||
+			!end.IsZero() && m.Time.After(end) {
+			return nil
+		}
+		for _, site := range byPath[m.Path] {
+			if !site.fired && site.matches(m) {
+				site.fired = true
+			}
+		}
+		return nil
+	})
+}
+
+// writeDead prints the call sites which have not fired.
+func writeDead(w io.Writer, sites []*callSite) int {
+	n := 0
+	for _, site := range sites {
+		if site.fired {
+			continue
+		}
+		fmt.Fprintf(w, "%s:%d\t%s\t%q\n", site.File, site.Line, site.Path, site.Format)
+		n++
+	}
+	return n
+}
+
+func runDead(args []string) error {
+	flags := newFlagSet("dead")
+	manifest := flags.String("m", "catalog.json", "name of the catalog file")
+	from := flags.String("from", "", "start of the time range, in RFC 3339 format")
+	to := flags.String("to", "", "end of the time range, in RFC 3339 format")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no source directories given")
+	}
+
+	start, err := parseTime(*from)
+	if err != nil {
+		return err
+	}
+	end, err := parseTime(*to)
+	if err != nil {
+		return err
+	}
+	c, err := archive.Load(*manifest)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, e := range c.Find("", start, end) {
+		files = append(files, e.Name)
+	}
+	if len(files) == 0 {
+		return errors.New("no archived trace files in the time range")
+	}
+
+	sites, err := findCallSites(flags.Args())
+	if err != nil {
+		return err
+	}
+	if err := markFired(sites, files, start, end); err != nil {
+		return err
+	}
+	n := writeDead(os.Stdout, sites)
+	fmt.Fprintf(os.Stderr, "%d of %d call sites did not fire in %d trace files\n",
+		n, len(sites), len(files))
+	return nil
+}
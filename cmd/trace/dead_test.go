@@ -0,0 +1,97 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestFormatRegexp(t *testing.T) {
+	for _, test := range []struct {
+		format, msg string
+		match       bool
+	}{
+		{"connected to %s", "connected to db1", true},
+		{"connected to %s", "connected from db1", false},
+		{"%d%% done (%-8.3f s)", "50% done (1.500    s)", true},
+		{"a.b", "axb", false},
+		{"line %d\n%v", "line 1\nmore\ntext", true},
+	} {
+		if m := formatRegexp(test.format).MatchString(test.msg); m != test.match {
+			t.Errorf("%q/%q: expected %t, got %t", test.format, test.msg, test.match, m)
+		}
+	}
+}
+
+const deadSource = `package main
+
+import tr "github.com/seehuhn/trace"
+
+func main() {
+	tr.T("app", tr.PrioInfo, "started %d workers", 4)
+	tr.T("app", tr.PrioError, "cannot open %s", "x")
+	tr.T("app/db", tr.PrioDebug, "query done")
+	tr.T(path, tr.PrioDebug, "dynamic path")
+}
+`
+
+func TestDeadCallSites(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.Mkdir(src, 0755)
+	os.WriteFile(filepath.Join(src, "main.go"), []byte(deadSource), 0644)
+	os.WriteFile(filepath.Join(src, "main_test.go"), []byte(deadSource), 0644)
+
+	sites, err := findCallSites([]string{src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 3 {
+		t.Fatalf("expected 3 call sites, got %d", len(sites))
+	}
+
+	when := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	name := filepath.Join(dir, "run.jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := trace.NewJSONWriter(out)
+	w.Listen(&trace.Message{Time: when, Path: "app", Msg: "started 4 workers"})
+	w.Listen(&trace.Message{Time: when, Path: "app/db", Msg: "other text",
+		File: "/build/src/main.go", Line: 8})
+	w.Listen(&trace.Message{Time: when.Add(-time.Hour), Path: "app", Msg: "cannot open x"})
+	out.Close()
+
+	if err := markFired(sites, []string{name}, when, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if n := writeDead(buf, sites); n != 1 {
+		t.Errorf("expected 1 dead call site, got %d", n)
+	}
+	if !strings.Contains(buf.String(), `main.go:7	app	"cannot open %s"`) {
+		t.Errorf("wrong output %q", buf.String())
+	}
+}
@@ -0,0 +1,215 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// siteKey identifies a call site, see trace.Message.CallSite().
+type siteKey struct {
+	path, site string
+}
+
+// siteStats gives the number of messages emitted by one call site, and
+// their total delivery latency.
+type siteStats struct {
+	count   int64
+	timed   int64         // number of messages with a delivery time
+	latency time.Duration // total latency of the timed messages
+}
+
+func (s *siteStats) add(m *trace.Message) {
+	s.count++
+	if !m.Delivered.IsZero() {
+		s.timed++
+		s.latency += m.Delivered.Sub(m.Time)
+	}
+}
+
+// meanLatency returns the average delivery latency, or -1 if no
+// delivery times are known.
+func (s *siteStats) meanLatency() time.Duration {
+	if s.timed == 0 {
+		return -1
+	}
+	return s.latency / time.Duration(s.timed)
+}
+
+// siteDiff compares the messages emitted by one call site in two
+// recordings.
+type siteDiff struct {
+	path, site string
+	a, b       siteStats
+}
+
+// status returns "+" for call sites which only occur in the second
+// recording, "-" for call sites which only occur in the first
+// recording, and "~" otherwise.
+func (d *siteDiff) status() string {
+	switch {
+	case d.a.count == 0:
+		return "+"
+	case d.b.count == 0:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// latencyDelta returns the change of the mean delivery latency, or
+// zero if the latency is not known for both recordings.
+func (d *siteDiff) latencyDelta() time.Duration {
+	la, lb := d.a.meanLatency(), d.b.meanLatency()
+	if la < 0 || lb < 0 {
+		return 0
+	}
+	return lb - la
+}
+
+// diffSites compares the call site statistics of two recordings.
+// Unless 'all' is set, call sites with identical message counts are
+// omitted.  The result is sorted by decreasing size of the difference.
+// If 'byLatency' is set, the change of the mean delivery latency is
+// used instead of the message counts.
+func diffSites(a, b map[siteKey]*siteStats, all, byLatency bool) []siteDiff {
+	byKey := map[siteKey]*siteDiff{}
+	get := func(k siteKey) *siteDiff {
+		d := byKey[k]
+		if d == nil {
+			d = &siteDiff{path: k.path, site: k.site}
+			byKey[k] = d
+		}
+		return d
+	}
+	for k, stats := range a {
+		get(k).a = *stats
+	}
+	for k, stats := range b {
+		get(k).b = *stats
+	}
+
+	delta := func(d *siteDiff) int64 {
+		var x int64
+		if byLatency {
+			x = int64(d.latencyDelta())
+		} else {
+			x = d.b.count - d.a.count
+		}
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+	var res []siteDiff
+	for _, d := range byKey {
+		if all || delta(d) != 0 {
+			res = append(res, *d)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		di, dj := delta(&res[i]), delta(&res[j])
+		if di != dj {
+			return di > dj
+		}
+		if res[i].path != res[j].path {
+			return res[i].path < res[j].path
+		}
+		return res[i].site < res[j].site
+	})
+	return res
+}
+
+// writeDiff prints the call site differences, one line per call site.
+// Latencies which are not known are shown as "-".
+func writeDiff(w io.Writer, diffs []siteDiff) {
+	fmt.Fprintf(w, "  %8s %8s %8s  %10s %10s %10s  %-30s %s\n",
+		"A", "B", "DELTA", "LAT A", "LAT B", "LAT DELTA", "PATH", "SITE")
+	latency := func(d time.Duration) string {
+		if d < 0 {
+			return "-"
+		}
+		return d.Round(time.Microsecond).String()
+	}
+	for i := range diffs {
+		d := &diffs[i]
+		site := d.site
+		if site == "" {
+			site = "-"
+		}
+		la, lb := d.a.meanLatency(), d.b.meanLatency()
+		deltaStr := "-"
+		if la >= 0 && lb >= 0 {
+			deltaStr = (lb - la).Round(time.Microsecond).String()
+			if lb >= la {
+				deltaStr = "+" + deltaStr
+			}
+		}
+		fmt.Fprintf(w, "%s %8d %8d %+8d  %10s %10s %10s  %-30s %s\n",
+			d.status(), d.a.count, d.b.count, d.b.count-d.a.count,
+			latency(la), latency(lb), deltaStr, d.path, site)
+	}
+}
+
+// readSites reads the named trace file and returns the statistics for
+// every call site.
+func readSites(name string) (map[siteKey]*siteStats, error) {
+	sites := map[siteKey]*siteStats{}
+	err := readMessages([]string{name}, func(m *trace.Message) error {
+		k := siteKey{m.Path, m.CallSite()}
+		stats := sites[k]
+		if stats == nil {
+			stats = &siteStats{}
+			sites[k] = stats
+		}
+		stats.add(m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
+
+func runDiff(args []string) error {
+	flags := newFlagSet("diff")
+	all := flags.Bool("a", false, "also show unchanged call sites")
+	byLatency := flags.Bool("l", false, "compare the delivery latency instead of the message counts")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("need exactly two trace files")
+	}
+
+	a, err := readSites(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := readSites(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	writeDiff(os.Stdout, diffSites(a, b, *all, *byLatency))
+	return nil
+}
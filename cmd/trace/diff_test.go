@@ -0,0 +1,99 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// siteMsgs describes 'n' messages from one call site, with the given
+// delivery latency.
+type siteMsgs struct {
+	path, site string
+	n          int
+	latency    time.Duration
+}
+
+// sites collects the call site statistics for the given messages.
+func sites(msgs ...siteMsgs) map[siteKey]*siteStats {
+	t0 := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	res := map[siteKey]*siteStats{}
+	for _, msg := range msgs {
+		k := siteKey{msg.path, msg.site}
+		if res[k] == nil {
+			res[k] = &siteStats{}
+		}
+		for i := 0; i < msg.n; i++ {
+			m := &trace.Message{Time: t0, Path: msg.path}
+			if msg.latency > 0 {
+				m.Delivered = t0.Add(msg.latency)
+			}
+			res[k].add(m)
+		}
+	}
+	return res
+}
+
+func TestDiffSites(t *testing.T) {
+	a := sites(
+		siteMsgs{"db", "db.go:10", 5, time.Millisecond},
+		siteMsgs{"db", "db.go:20", 2, 0},
+		siteMsgs{"net", "", 1, 0},
+	)
+	b := sites(
+		siteMsgs{"db", "db.go:10", 5, 3 * time.Millisecond},
+		siteMsgs{"db", "db.go:20", 12, 0},
+		siteMsgs{"http", "", 3, 0},
+	)
+
+	buf := &bytes.Buffer{}
+	writeDiff(buf, diffSites(a, b, false, false))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"A B DELTA LAT A LAT B LAT DELTA PATH SITE",
+		"~ 2 12 +10 - - - db db.go:20",
+		"+ 0 3 +3 - - - http -",
+		"- 1 0 -1 - - - net -",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong output:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		if s := strings.Join(strings.Fields(line), " "); s != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], s)
+		}
+	}
+
+	if d := diffSites(a, b, true, false); len(d) != 4 {
+		t.Errorf("expected 4 call sites, got %d", len(d))
+	}
+
+	buf.Reset()
+	writeDiff(buf, diffSites(a, b, false, true))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrong latency output:\n%s", buf.String())
+	}
+	if s := strings.Join(strings.Fields(lines[1]), " "); s != "~ 5 5 +0 1ms 3ms +2ms db db.go:10" {
+		t.Errorf("wrong latency line %q", s)
+	}
+}
@@ -0,0 +1,217 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/archive"
+)
+
+// eraseReport records the result of removing the messages for one
+// subject from a trace file.  To avoid storing the personal data the
+// erasure is meant to remove, the subject identifier is only recorded
+// as a SHA-256 hash.
+type eraseReport struct {
+	Time        time.Time `json:"time"`
+	File        string    `json:"file"`
+	SubjectHash string    `json:"subject_sha256"`
+	Removed     int       `json:"removed"`
+	Kept        int       `json:"kept"`
+	Before      string    `json:"before_sha256"`
+	After       string    `json:"after_sha256"`
+}
+
+// mentions reports whether 'text' contains 'subject' as a whole word,
+// i.e. not directly preceded or followed by a letter or digit.
+func mentions(text, subject string) bool {
+	if subject == "" {
+		return false
+	}
+	for start := 0; ; {
+		i := strings.Index(text[start:], subject)
+		if i < 0 {
+			return false
+		}
+		i += start
+		j := i + len(subject)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[j:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(c rune) bool {
+	return c != utf8.RuneError && (unicode.IsLetter(c) || unicode.IsDigit(c))
+}
+
+// messageMentions reports whether any of the text fields of 'm'
+// mentions 'subject'.
+func messageMentions(m *trace.Message, subject string) bool {
+	for _, text := range []string{m.Msg, m.Path, m.Format, m.Route, m.File, m.Func} {
+		if mentions(text, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// eraseFile rewrites the trace file 'name', removing all messages which
+// mention 'subject' in any of their text fields.  Files with names
+// ending in ".gz" are read and written in gzip format.  The file is
+// replaced atomically.  Since message offsets change, a search index
+// for the file is removed.
+func eraseFile(name, subject string) (*eraseReport, error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	out, err := os.CreateTemp(filepath.Dir(name), ".erase-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	subjectSum := sha256.Sum256([]byte(subject))
+	report := &eraseReport{
+		Time:        time.Now(),
+		File:        name,
+		SubjectHash: hex.EncodeToString(subjectSum[:]),
+	}
+	beforeHash := sha256.New()
+	afterHash := sha256.New()
+	var r io.Reader = io.TeeReader(in, beforeHash)
+	var w io.Writer = io.MultiWriter(out, afterHash)
+	var zw *gzip.Writer
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	enc := json.NewEncoder(w)
+	err = readStream(r, func(m *trace.Message) error {
+		if messageMentions(m, subject) {
+			report.Removed++
+			return nil
+		}
+		report.Kept++
+		return enc.Encode(m)
+	})
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Read the rest of the file, so that the hash covers all of it.
+	if _, err := io.Copy(beforeHash, in); err != nil {
+		return nil, err
+	}
+	report.Before = hex.EncodeToString(beforeHash.Sum(nil))
+	report.After = hex.EncodeToString(afterHash.Sum(nil))
+	if report.Removed == 0 {
+		report.After = report.Before
+		return report, nil
+	}
+
+	if err := out.Chmod(fi.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(out.Name(), name); err != nil {
+		return nil, err
+	}
+	os.Remove(indexName(name))
+	return report, nil
+}
+
+// updateCatalog replaces the catalog entries for the erased files by
+// new entries describing the rewritten files.  Files which are not in
+// the catalog are not added.
+func updateCatalog(manifest string, reports []*eraseReport) error {
+	c, err := archive.Load(manifest)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, report := range reports {
+		if report.Removed == 0 || !c.Has(report.File) {
+			continue
+		}
+		e, err := archive.Scan(report.File)
+		if err != nil {
+			return err
+		}
+		c.Add(e)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return c.Save(manifest)
+}
+
+func runErase(args []string) error {
+	flags := newFlagSet("erase")
+	subject := flags.String("subject", "", "identifier of the data subject")
+	manifest := flags.String("m", "catalog.json", "name of the catalog file to update")
+	flags.Parse(args)
+	if *subject == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("need a subject and at least one trace file")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	var reports []*eraseReport
+	for _, name := range flags.Args() {
+		report, err := eraseFile(name, *subject)
+		if err != nil {
+			return err
+		}
+		enc.Encode(report)
+		reports = append(reports, report)
+	}
+	return updateCatalog(*manifest, reports)
+}
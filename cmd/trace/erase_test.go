@@ -0,0 +1,152 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/archive"
+)
+
+func TestMentions(t *testing.T) {
+	testData := []struct {
+		text, subject string
+		result        bool
+	}{
+		{"login by alice@example.com failed", "alice@example.com", true},
+		{"user 1234", "1234", true},
+		{"user 12345", "1234", false},
+		{"user 01234, then 1234.", "1234", true},
+		{"anything", "", false},
+	}
+	for _, test := range testData {
+		if res := mentions(test.text, test.subject); res != test.result {
+			t.Errorf("%q/%q: expected %t, got %t", test.text, test.subject, test.result, res)
+		}
+	}
+}
+
+func TestEraseFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "run.jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	w := trace.NewJSONWriter(out)
+	w.Listen(&trace.Message{Path: "auth", Msg: "login user=42"})
+	w.Listen(&trace.Message{Path: "auth", Msg: "login user=420", Delivered: delivered})
+	w.Listen(&trace.Message{Path: "db", Msg: "query for 42 done"})
+	out.Close()
+	os.WriteFile(indexName(name), []byte("stale"), 0644)
+
+	report, err := eraseFile(name, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Removed != 2 || report.Kept != 1 || report.Before == report.After {
+		t.Errorf("wrong report %v", report)
+	}
+	var msgs []string
+	err = readMessages([]string{name}, func(m *trace.Message) error {
+		msgs = append(msgs, m.Msg)
+		if !m.Delivered.Equal(delivered) {
+			t.Errorf("delivery time changed from %s to %s", delivered, m.Delivered)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0] != "login user=420" {
+		t.Errorf("wrong remaining messages %q", msgs)
+	}
+	if _, err := os.Stat(indexName(name)); !os.IsNotExist(err) {
+		t.Error("stale index not removed")
+	}
+}
+
+func TestEraseFileFields(t *testing.T) {
+	// The subject is removed from all text fields, and compressed
+	// files are rewritten in compressed form.
+	dir := t.TempDir()
+	name := filepath.Join(dir, "run.jsonl.gz")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(out)
+	w := trace.NewJSONWriter(zw)
+	w.Listen(&trace.Message{Path: "user/42", Msg: "login"})
+	w.Listen(&trace.Message{Path: "auth", Msg: "login", Func: "main.handle42"})
+	w.Listen(&trace.Message{Path: "auth", Msg: "login", Route: "42"})
+	w.Listen(&trace.Message{Path: "auth", Msg: "logout"})
+	zw.Close()
+	out.Close()
+
+	manifest := filepath.Join(dir, "catalog.json")
+	c := &archive.Catalog{}
+	e, err := archive.Scan(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(e)
+	if err := c.Save(manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := eraseFile(name, "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Removed != 2 || report.Kept != 2 {
+		t.Errorf("wrong report %v", report)
+	}
+	var msgs []string
+	err = readMessages([]string{name}, func(m *trace.Message) error {
+		msgs = append(msgs, m.Msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(msgs, ",") != "login,logout" {
+		t.Errorf("wrong remaining messages %q", msgs)
+	}
+
+	if err := updateCatalog(manifest, []*eraseReport{report}); err != nil {
+		t.Fatal(err)
+	}
+	c, err = archive.Load(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Entries) != 1 || c.Entries[0].Messages != 2 ||
+		c.Entries[0].Size != fi.Size() || c.Entries[0].SHA256 != report.After {
+		t.Errorf("catalog not updated: %v", c.Entries)
+	}
+}
@@ -0,0 +1,165 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Trace is a command line tool to analyse trace files, as written by
+// trace.JSONWriter.
+//
+// Usage:
+//
+//	trace command [arguments]
+//
+// The commands are:
+//
+//	catalog    add trace files to an archive catalog
+//	correlate  show the messages for one operation ID across trace files
+//	cost       estimate the daily volume and cost of the messages by path
+//	dead       list the trace statements which never fired in archived files
+//	diff       compare the call sites of two trace files
+//	erase      remove the messages mentioning a data subject from trace files
+//	index      build search indexes for trace files
+//	locate     list the archived trace files for a path and time range
+//	schema     check the message fields against declared schemas
+//	search     find the messages matching all given search terms
+//	selftest   run the self-test of a program's trace sinks
+//	top        show the call sites which produced the most messages
+//	tree       show the path hierarchy with message counts and rates
+//
+// Use "trace command -h" to get help for a command.  Commands which
+// read trace files read from standard input if no file names are given.
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/seehuhn/trace"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"catalog":   {runCatalog, "catalog [-m catalog.json] [-d] file...\n\tadd trace files to (or with -d remove them from) an archive catalog"},
+		"correlate": {runCorrelate, "correlate [-json] id file...\n\tshow all messages mentioning an operation ID, from all given trace files, in time order"},
+		"cost":      {runCost, "cost [-price dollars] [file...]\n\testimate the daily volume and storage cost by path, with one sink per trace file"},
+		"dead":      {runDead, "dead [-m catalog.json] [-from time] [-to time] dir...\n\tlist the calls to trace.T() in the Go sources in dir which produced no messages in the archived trace files"},
+		"diff":      {runDiff, "diff [-a] [-l] old-file new-file\n\tcompare the message counts and delivery latencies by call site of two trace files"},
+		"erase":     {runErase, "erase -subject id [-m catalog] file...\n\tremove all messages mentioning a data subject from trace files, printing a report"},
+		"index":     {runIndex, "index file...\n\tbuild search indexes for trace files, stored as file.idx"},
+		"locate":    {runLocate, "locate [-m catalog.json] [-path path] [-from time] [-to time]\n\tlist the archived trace files for a path and time range"},
+		"schema":    {runSchema, "schema [-f schema.json] [file...]\n\treport the messages whose fields do not match the schema for their path"},
+		"search":    {runSearch, "search file term...\n\tprint the messages matching all search terms, using file.idx if present"},
+		"selftest":  {runSelfTest, "selftest url\n\trun the self-test of the sinks of a running program, via admin.SelfTestHandler;\n\tsinks which cannot confirm the delivery of the probe are shown as unverified"},
+		"top":       {runTop, "top [-n count] [file...]\n\tshow the call sites which produced the most messages"},
+		"tree":      {runTree, "tree [file...]\n\tshow the path hierarchy with message counts and rates"},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: trace command [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "The commands are:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  trace", commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "trace: unknown command %q\n", os.Args[1])
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "trace:", err)
+		os.Exit(1)
+	}
+}
+
+// newFlagSet returns a FlagSet for the named command, which prints the
+// command's usage message on errors.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: trace", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// readMessages calls 'fn' for every message in the named trace files,
+// or in standard input if no file names are given.  Files with names
+// ending in ".gz" are decompressed.
+func readMessages(files []string, fn func(*trace.Message) error) error {
+	if len(files) == 0 {
+		return readStream(os.Stdin, fn)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			r = zr
+		}
+		err = readStream(r, fn)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func readStream(r io.Reader, fn func(*trace.Message) error) error {
+	rd := trace.NewJSONReader(r)
+	for {
+		m, err := rd.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
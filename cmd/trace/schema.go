@@ -0,0 +1,81 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seehuhn/trace"
+)
+
+// readSchemas reads a JSON file mapping path prefixes to field schemas,
+// for example
+//
+//	{"http": {"fields": {"status": "int", "took": "duration"},
+//	          "required": ["status"]}}
+func readSchemas(name string) (map[string]*trace.FieldSchema, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	schemas := map[string]*trace.FieldSchema{}
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return schemas, nil
+}
+
+// checkSchemas checks all messages in the named trace files, or in
+// standard input if no file names are given, and prints one line for
+// every message which does not match its schema.  The number of such
+// messages is returned.
+func checkSchemas(w io.Writer, files []string) (int, error) {
+	bad := 0
+	err := readMessages(files, func(m *trace.Message) error {
+		if err := trace.CheckFields(m.Path, m.Msg); err != nil {
+			fmt.Fprintf(w, "%s %s: %s\n", m.Time.Format("2006-01-02 15:04:05.000"), m.Path, err)
+			bad++
+		}
+		return nil
+	})
+	return bad, err
+}
+
+func runSchema(args []string) error {
+	flags := newFlagSet("schema")
+	schemaFile := flags.String("f", "schema.json", "the file containing the field schemas")
+	flags.Parse(args)
+
+	schemas, err := readSchemas(*schemaFile)
+	if err != nil {
+		return err
+	}
+	for prefix, schema := range schemas {
+		trace.SetSchema(prefix, schema)
+	}
+	bad, err := checkSchemas(os.Stdout, flags.Args())
+	if err != nil {
+		return err
+	}
+	if bad > 0 {
+		return fmt.Errorf("%d messages do not match their schema", bad)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestCheckSchemas(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.json")
+	os.WriteFile(schemaFile, []byte(`{"http": {"fields": {"status": "int"}}}`), 0644)
+	schemas, err := readSchemas(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for prefix, schema := range schemas {
+		trace.SetSchema(prefix, schema)
+		defer trace.SetSchema(prefix, nil)
+	}
+
+	name := filepath.Join(dir, "run.jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := trace.NewJSONWriter(out)
+	w.Listen(&trace.Message{Path: "http", Msg: "done status=200"})
+	w.Listen(&trace.Message{Path: "http", Msg: "done status=ok"})
+	w.Listen(&trace.Message{Path: "db", Msg: "done status=ok"})
+	out.Close()
+
+	buf := &bytes.Buffer{}
+	bad, err := checkSchemas(buf, []string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad != 1 || !strings.Contains(buf.String(), `field "status" has value "ok", expected int`) {
+		t.Errorf("wrong result %d:\n%s", bad, buf.String())
+	}
+}
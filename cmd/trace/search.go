@@ -0,0 +1,113 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/index"
+)
+
+// indexName returns the name of the index file for a trace file.
+func indexName(name string) string {
+	return name + ".idx"
+}
+
+func runIndex(args []string) error {
+	flags := newFlagSet("index")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no trace files given")
+	}
+
+	for _, name := range flags.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		idx, err := index.Build(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		out, err := os.Create(indexName(name))
+		if err != nil {
+			return err
+		}
+		_, err = idx.WriteTo(out)
+		if err2 := out.Close(); err == nil {
+			err = err2
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadIndex reads the index for the trace file 'f', if an index file
+// exists which is not older than the trace file, or builds the index
+// otherwise.
+func loadIndex(f *os.File) (*index.Index, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if ii, err := os.Stat(indexName(f.Name())); err == nil &&
+		!ii.ModTime().Before(fi.ModTime()) {
+		in, err := os.Open(indexName(f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+		return index.Read(in)
+	}
+	return index.Build(f)
+}
+
+func runSearch(args []string) error {
+	flags := newFlagSet("search")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errors.New("need a trace file and at least one search term")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	idx, err := loadIndex(f)
+	if err != nil {
+		return err
+	}
+
+	w := trace.NewJSONWriter(os.Stdout)
+	for _, offset := range idx.Lookup(flags.Args()[1:]...) {
+		m, err := index.ReadMessage(f, offset)
+		if err != nil {
+			return err
+		}
+		w.Listen(m)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestIndexAndSearch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "run.jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := trace.NewJSONWriter(out)
+	w.Listen(&trace.Message{Path: "db", Prio: trace.PrioError, Msg: "disk full"})
+	w.Listen(&trace.Message{Path: "net", Prio: trace.PrioInfo, Msg: "disk ok"})
+	out.Close()
+
+	if err := runIndex([]string{name}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idx, err := loadIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(idx.Lookup("disk")); n != 2 {
+		t.Errorf("expected 2 matches, got %d", n)
+	}
+	if n := len(idx.Lookup("disk", "prio:error")); n != 1 {
+		t.Errorf("expected 1 match, got %d", n)
+	}
+}
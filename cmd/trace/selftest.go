@@ -0,0 +1,75 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/seehuhn/trace"
+)
+
+// writeSelfTest prints the self-test results, one line per sink, and
+// returns the number of failed sinks.  Sinks which passed the test but
+// cannot confirm the delivery of the probe message are shown as
+// "unverified".
+func writeSelfTest(w io.Writer, results []trace.SelfTestResult) int {
+	failed := 0
+	fmt.Fprintf(w, "%-10s %-6s %-20s %-20s %s\n", "RESULT", "HANDLE", "PATH", "SINK", "DETAILS")
+	for _, r := range results {
+		result := "pass"
+		details := "delivery confirmed by the sink"
+		if !r.Verified {
+			result = "unverified"
+			details = "probe written, but the sink cannot confirm the delivery"
+		}
+		if !r.Passed() {
+			result = "FAIL"
+			details = r.Error
+			failed++
+		}
+		fmt.Fprintf(w, "%-10s %6d %-20s %-20s %s\n", result, r.Handle, r.Path, r.Sink, details)
+	}
+	return failed
+}
+
+func runSelfTest(args []string) error {
+	flags := newFlagSet("selftest")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("need the URL of a self-test handler")
+	}
+
+	resp, err := http.Get(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var results []trace.SelfTestResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return fmt.Errorf("%s: %s (%s)", flags.Arg(0), err, resp.Status)
+	}
+	if failed := writeSelfTest(os.Stdout, results); failed > 0 {
+		return fmt.Errorf("%d of %d sinks failed the self-test", failed, len(results))
+	}
+	return nil
+}
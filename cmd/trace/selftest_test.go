@@ -0,0 +1,46 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestWriteSelfTest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	failed := writeSelfTest(buf, []trace.SelfTestResult{
+		{Handle: 1, Path: "", Sink: "*trace.FileSink", Verified: true},
+		{Handle: 2, Path: "http", Sink: "*remote.Sender", Error: "health check failed: connection refused"},
+		{Handle: 3, Path: "db", Sink: "*otlp.Exporter"},
+	})
+	if failed != 1 {
+		t.Errorf("expected 1 failure, got %d", failed)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("wrong output:\n%s", buf.String())
+	}
+	for i, want := range []string{"pass", "FAIL", "unverified"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("line %d: missing %q in %q", i+1, want, lines[i+1])
+		}
+	}
+}
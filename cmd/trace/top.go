@@ -0,0 +1,55 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seehuhn/trace"
+)
+
+// writeTop prints the call site statistics 'top', one line per call
+// site.
+func writeTop(w io.Writer, top []trace.SiteStat) {
+	fmt.Fprintf(w, "%8s %10s  %-30s %s\n", "MSGS", "BYTES", "PATH", "SITE")
+	for _, stat := range top {
+		site := stat.Site
+		if site == "" {
+			site = "-"
+		}
+		fmt.Fprintf(w, "%8d %10d  %-30s %s\n", stat.Count, stat.Bytes, stat.Path, site)
+	}
+}
+
+func runTop(args []string) error {
+	flags := newFlagSet("top")
+	n := flags.Int("n", 20, "number of call sites to show (all if negative)")
+	flags.Parse(args)
+
+	counter := trace.NewSiteCounter()
+	err := readMessages(flags.Args(), func(m *trace.Message) error {
+		counter.Listen(m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	writeTop(os.Stdout, counter.Top(*n))
+	return nil
+}
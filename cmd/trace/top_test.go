@@ -0,0 +1,83 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestWriteTop(t *testing.T) {
+	counter := trace.NewSiteCounter()
+	for _, m := range []*trace.Message{
+		{Path: "db", Msg: "query", File: "db.go", Line: 12},
+		{Path: "db", Msg: "query", File: "db.go", Line: 12},
+		{Path: "net", Msg: "connected"},
+	} {
+		counter.Listen(m)
+	}
+
+	buf := &bytes.Buffer{}
+	writeTop(buf, counter.Top(-1))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"MSGS BYTES PATH SITE",
+		"2 10 db db.go:12",
+		"1 9 net -",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong output:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		if s := strings.Join(strings.Fields(line), " "); s != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], s)
+		}
+	}
+}
+
+func TestTopFromFile(t *testing.T) {
+	// Trace files store the format string, so that messages without
+	// caller information can be grouped by call site.
+	name := filepath.Join(t.TempDir(), "run.jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := trace.NewJSONWriter(out)
+	w.Listen(&trace.Message{Path: "db", Msg: "query 1 done", Format: "query %d done"})
+	w.Listen(&trace.Message{Path: "db", Msg: "query 2 done", Format: "query %d done"})
+	w.Listen(&trace.Message{Path: "db", Msg: "disk full", Format: "disk full"})
+	out.Close()
+
+	counter := trace.NewSiteCounter()
+	err = readMessages([]string{name}, func(m *trace.Message) error {
+		counter.Listen(m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := counter.Top(-1)
+	if len(top) != 2 || top[0].Site != `"query %d done"` || top[0].Count != 2 {
+		t.Errorf("wrong call sites %v", top)
+	}
+}
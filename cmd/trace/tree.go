@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// pathNode collects statistics for one path and its sub-paths.
+type pathNode struct {
+	name     string
+	count    int
+	errors   int
+	children map[string]*pathNode
+}
+
+// pathTree accumulates message statistics by path.
+type pathTree struct {
+	root        pathNode
+	first, last time.Time
+}
+
+func (tree *pathTree) add(m *trace.Message) {
+	if tree.first.IsZero() || m.Time.Before(tree.first) {
+		tree.first = m.Time
+	}
+	if m.Time.After(tree.last) {
+		tree.last = m.Time
+	}
+
+	isError := m.Prio >= trace.PrioError
+	node := &tree.root
+	node.add(isError)
+	if m.Path == "" {
+		return
+	}
+	for _, name := range strings.Split(m.Path, "/") {
+		child := node.children[name]
+		if child == nil {
+			if node.children == nil {
+				node.children = map[string]*pathNode{}
+			}
+			child = &pathNode{name: name}
+			node.children[name] = child
+		}
+		node = child
+		node.add(isError)
+	}
+}
+
+func (node *pathNode) add(isError bool) {
+	node.count++
+	if isError {
+		node.errors++
+	}
+}
+
+// write prints the tree, one line per path.  If the recording spans a
+// positive amount of time, message rates are included.
+func (tree *pathTree) write(w io.Writer) {
+	seconds := tree.last.Sub(tree.first).Seconds()
+	fmt.Fprintf(w, "%-40s %8s %8s %10s\n", "PATH", "MSGS", "ERRORS", "MSGS/S")
+	var walk func(node *pathNode, label string, depth int)
+	walk = func(node *pathNode, label string, depth int) {
+		rate := "-"
+		if seconds > 0 {
+			rate = fmt.Sprintf("%.2f", float64(node.count)/seconds)
+		}
+		fmt.Fprintf(w, "%-40s %8d %8d %10s\n",
+			strings.Repeat("  ", depth)+label, node.count, node.errors, rate)
+
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			walk(node.children[name], name, depth+1)
+		}
+	}
+	walk(&tree.root, "(all)", 0)
+}
+
+func runTree(args []string) error {
+	flags := newFlagSet("tree")
+	flags.Parse(args)
+
+	tree := &pathTree{}
+	err := readMessages(flags.Args(), func(m *trace.Message) error {
+		tree.add(m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tree.write(os.Stdout)
+	return nil
+}
@@ -0,0 +1,70 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestPathTree(t *testing.T) {
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	tree := &pathTree{}
+	for i, m := range []struct {
+		path string
+		prio trace.Priority
+	}{
+		{"net/http", trace.PrioError},
+		{"net/http", trace.PrioInfo},
+		{"net/dns", trace.PrioCritical},
+		{"db", trace.PrioDebug},
+	} {
+		tree.add(&trace.Message{
+			Time: when.Add(time.Duration(i) * time.Second),
+			Path: m.path,
+			Prio: m.prio,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	tree.write(buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := [][]string{
+		{"PATH", "MSGS", "ERRORS", "MSGS/S"},
+		{"(all)", "4", "2", "1.33"},
+		{"db", "1", "0", "0.33"},
+		{"net", "3", "2", "1.00"},
+		{"dns", "1", "1", "0.33"},
+		{"http", "2", "1", "0.67"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong output:\n%s", buf.String())
+	}
+	for i, line := range lines {
+		if fields := strings.Fields(line); strings.Join(fields, " ") !=
+			strings.Join(expected[i], " ") {
+			t.Errorf("line %d: expected %q, got %q", i, expected[i], fields)
+		}
+	}
+	if !strings.HasPrefix(lines[4], "    dns") {
+		t.Errorf("wrong indentation %q", lines[4])
+	}
+}
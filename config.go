@@ -0,0 +1,310 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Rule describes one entry of a tracing configuration, see Configure().
+type Rule struct {
+	// Path selects the messages for the given path and its sub-paths.
+	// The value "*" or the empty string selects all messages.
+	Path string `json:"path"`
+
+	// Level is the minimum priority of messages to show, either as a
+	// name like "debug" or as a number.  The value "none" suppresses
+	// all messages for the path.
+	Level string `json:"level"`
+
+	// Output names the destination for the messages: "stderr" (the
+	// default) or "stdout" for console output, "std" for JSON output
+	// split between stdout and stderr (see StdStreams), "syslog" for
+	// the local syslog daemon, "journal" for the systemd journal,
+	// "group:name" for the members of a listener group (see
+	// JoinGroup), or the name of a file.  Messages are appended to
+	// files in the console format, in the trace file format if the
+	// file name ends in ".jsonl", or in the classic syslog line format
+	// (see ClassicWriter) if the file name ends in ".syslog".
+	Output string `json:"output,omitempty"`
+}
+
+// configFile is the format of configuration files read by
+// ConfigureFromFile().
+type configFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+var (
+	configMutex   sync.Mutex // protects the following variables
+	configHandles []ListenerHandle
+	configClosers []io.Closer
+	configRules   []Rule // used by ReinitAfterFork()
+)
+
+// ParseConfig parses a configuration of the form
+//
+//	path=level[:output],path=level[:output],...
+//
+// for example "http=debug,db/mysql=verbose,*=info:stderr".  See Rule
+// for the meaning of the fields.
+func ParseConfig(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed trace configuration entry %q", entry)
+		}
+		level, output, _ := strings.Cut(level, ":")
+		rules = append(rules, Rule{Path: path, Level: level, Output: output})
+	}
+	return rules, nil
+}
+
+// ConfigureFromEnv configures tracing from the environment variable
+// TRACE, in the format described for ParseConfig().  If the variable is
+// not set, the current configuration is left unchanged.
+func ConfigureFromEnv() error {
+	spec, ok := os.LookupEnv("TRACE")
+	if !ok {
+		return nil
+	}
+	rules, err := ParseConfig(spec)
+	if err != nil {
+		return err
+	}
+	return Configure(rules)
+}
+
+// AutoConfigure installs a default tracing configuration suitable for
+// the environment the program runs in.  If the environment variable
+// TRACE is set, the configuration is read from there, as for
+// ConfigureFromEnv().  Otherwise, messages of priority PrioInfo and
+// higher are shown, using the first matching output from the following
+// list:
+//
+//   - "journal", if the program runs as a systemd service whose output
+//     goes to the journal,
+//   - "std", if the program runs in a Kubernetes pod,
+//   - "stderr", if standard error is a terminal,
+//   - "std" otherwise.
+func AutoConfigure() error {
+	if _, ok := os.LookupEnv("TRACE"); ok {
+		return ConfigureFromEnv()
+	}
+	_, err := os.Stat(journalSocket)
+	output := autoOutput(os.Getenv, isTerminal(os.Stderr), err == nil)
+	err = Configure([]Rule{{Level: "info", Output: output}})
+	if err == nil {
+		T("trace", PrioDebug, "auto-configured output %q", output)
+	}
+	return err
+}
+
+// autoOutput chooses the output for AutoConfigure(), using 'getenv'
+// to read environment variables.
+func autoOutput(getenv func(string) string, terminal, haveJournal bool) string {
+	switch {
+	case getenv("JOURNAL_STREAM") != "" && haveJournal:
+		return "journal"
+	case getenv("KUBERNETES_SERVICE_HOST") != "":
+		return "std"
+	case terminal:
+		return "stderr"
+	default:
+		return "std"
+	}
+}
+
+// isTerminal reports whether 'f' is a character device, like a
+// terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ConfigureFromFile configures tracing from a JSON file of the form
+//
+//	{"rules": [{"path": "http", "level": "debug"},
+//	           {"path": "*", "level": "info", "output": "stderr"}]}
+//
+// See Rule for the meaning of the fields.
+func ConfigureFromFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	cfg := &configFile{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return Configure(cfg.Rules)
+}
+
+// Configure installs listeners according to the given rules, replacing
+// the listeners installed by previous calls to Configure(),
+// ConfigureFromEnv() or ConfigureFromFile().  For each output, a
+// message is shown if its priority is at least the level of the most
+// specific rule matching the message path.
+func Configure(rules []Rule) error {
+	levels := map[string]map[string]ruleLevel{} // output -> path -> level
+	var order []string
+	for _, rule := range rules {
+		output := rule.Output
+		if output == "" {
+			output = "stderr"
+		}
+		path := rule.Path
+		if path == "*" {
+			path = ""
+		}
+		level := ruleLevel{none: rule.Level == "none"}
+		if !level.none {
+			var err error
+			level.prio, err = ParsePriority(rule.Level)
+			if err != nil {
+				return err
+			}
+		}
+		table := levels[output]
+		if table == nil {
+			table = map[string]ruleLevel{}
+			levels[output] = table
+			order = append(order, output)
+		}
+		table[path] = level
+	}
+
+	listeners := map[string]Listener{}
+	var closers []io.Closer
+	for _, output := range order {
+		listener, closer, err := openOutput(output)
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return err
+		}
+		listeners[output] = listener
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	for _, handle := range configHandles {
+		handle.Unregister()
+	}
+	for _, c := range configClosers {
+		c.Close()
+	}
+	configHandles = nil
+	configClosers = closers
+	configRules = append([]Rule{}, rules...)
+	for _, output := range order {
+		table := levels[output]
+		for path, level := range table {
+			if level.none {
+				continue
+			}
+			l := &ruleListener{path: path, table: table, next: listeners[output]}
+			configHandles = append(configHandles, Register(l.Listen, path, level.prio))
+		}
+	}
+	return nil
+}
+
+// ruleLevel is the level of a rule in the level tables of Configure().
+// The field none is set for rules with level "none", which suppress
+// the messages for the path.
+type ruleLevel struct {
+	prio Priority
+	none bool
+}
+
+// ruleListener delivers messages for the rule with the given path,
+// unless a more specific rule for the same output exists.
+type ruleListener struct {
+	path  string
+	table map[string]ruleLevel
+	next  Listener
+}
+
+func (l *ruleListener) Listen(m *Message) {
+	path := m.Path
+	for i := len(path); i >= 0; i-- {
+		if i > 0 && i < len(path) && path[i] != '/' {
+			continue
+		}
+		if _, ok := l.table[path[:i]]; ok {
+			if path[:i] == l.path {
+				l.next(m)
+			}
+			return
+		}
+	}
+}
+
+// openOutput returns a listener which writes to the named output.
+func openOutput(name string) (Listener, io.Closer, error) {
+	switch name {
+	case "stderr":
+		return NewConsole(os.Stderr).Listen, nil, nil
+	case "stdout":
+		return NewConsole(os.Stdout).Listen, nil, nil
+	case "std":
+		return StdStreams(), nil, nil
+	case "syslog":
+		s, err := NewSyslogListener("", "", FacilityUser)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s.Listen, s, nil
+	case "journal":
+		j, err := NewJournalListener()
+		if err != nil {
+			return nil, nil, err
+		}
+		return j.Listen, j, nil
+	}
+
+	if group, ok := strings.CutPrefix(name, "group:"); ok {
+		return func(m *Message) { deliverToGroup(group, m) }, nil, nil
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasSuffix(name, ".jsonl") {
+		return NewJSONWriter(f).Listen, f, nil
+	}
+	if strings.HasSuffix(name, ".syslog") {
+		return NewClassicWriter(f, "").Listen, f, nil
+	}
+	return NewConsole(f).Listen, f, nil
+}
@@ -0,0 +1,149 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	rules, err := ParseConfig("http=debug, db/mysql=verbose,*=info:stderr,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Rule{
+		{Path: "http", Level: "debug"},
+		{Path: "db/mysql", Level: "verbose"},
+		{Path: "*", Level: "info", Output: "stderr"},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("wrong rules %v", rules)
+	}
+
+	if _, err := ParseConfig("http"); err == nil {
+		t.Error("missing error for malformed entry")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.jsonl")
+	cfg := filepath.Join(dir, "trace.json")
+	err := os.WriteFile(cfg, []byte(`{"rules": [
+		{"path": "*", "level": "error", "output": "`+out+`"},
+		{"path": "db", "level": "debug", "output": "`+out+`"},
+		{"path": "db/noisy", "level": "none", "output": "`+out+`"}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ConfigureFromFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	T("http", PrioInfo, "1")
+	T("http", PrioError, "2")
+	T("db/pg", PrioDebug, "3")
+	T("db/noisy", PrioCritical, "4")
+	T("dbx", PrioDebug, "5")
+	T("db", PrioError, "6")
+
+	err = Configure(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	T("http", PrioCritical, "7")
+
+	fd, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	r := NewJSONReader(fd)
+	var msgs []string
+	for {
+		m, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m.Msg)
+	}
+	if !reflect.DeepEqual(msgs, []string{"2", "3", "6"}) {
+		t.Errorf("wrong messages %q", msgs)
+	}
+}
+
+func TestConfigureMaxLevel(t *testing.T) {
+	// A rule with level PrioMax is not the same as a rule with level
+	// "none".
+	var msgs []string
+	handle := JoinGroup("cfgmax", func(m *Message) { msgs = append(msgs, m.Msg) })
+	defer handle.Unregister()
+	err := Configure([]Rule{
+		{Path: "cfgmax", Level: strconv.Itoa(int(PrioMax)), Output: "group:cfgmax"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(nil)
+
+	T("cfgmax", PrioCritical, "below the level")
+	T("cfgmax", PrioMax, "at the level")
+	if !reflect.DeepEqual(msgs, []string{"at the level"}) {
+		t.Errorf("wrong messages %q", msgs)
+	}
+}
+
+func TestAutoOutput(t *testing.T) {
+	for _, test := range []struct {
+		env         map[string]string
+		terminal    bool
+		haveJournal bool
+		expected    string
+	}{
+		{nil, true, true, "stderr"},
+		{nil, false, true, "std"},
+		{map[string]string{"JOURNAL_STREAM": "8:1234"}, false, true, "journal"},
+		{map[string]string{"JOURNAL_STREAM": "8:1234"}, true, false, "stderr"},
+		{map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, true, false, "std"},
+	} {
+		getenv := func(key string) string { return test.env[key] }
+		output := autoOutput(getenv, test.terminal, test.haveJournal)
+		if output != test.expected {
+			t.Errorf("%v: expected %q, got %q", test, test.expected, output)
+		}
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	err := Configure([]Rule{{Path: "http", Level: "loud"}})
+	if err == nil {
+		t.Error("missing error for invalid level")
+	}
+	err = ConfigureFromFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil {
+		t.Error("missing error for missing file")
+	}
+}
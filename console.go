@@ -0,0 +1,237 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ansiColors maps the color names understood by ParseTheme() to ANSI
+// terminal escape sequences.
+var ansiColors = map[string]string{
+	"black":   "\x1b[30m",
+	"red":     "\x1b[31m",
+	"green":   "\x1b[32m",
+	"yellow":  "\x1b[33m",
+	"blue":    "\x1b[34m",
+	"magenta": "\x1b[35m",
+	"cyan":    "\x1b[36m",
+	"white":   "\x1b[37m",
+	"gray":    "\x1b[90m",
+	"bold":    "\x1b[1m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// Style describes how messages from a given path are shown on the
+// console.
+type Style struct {
+	// Color is one of the color names "black", "red", "green",
+	// "yellow", "blue", "magenta", "cyan", "white", "gray" or "bold".
+	// The empty string leaves the terminal color unchanged.
+	Color string
+
+	// Prefix is printed in front of the message path, for example an
+	// emoji identifying the subsystem.
+	Prefix string
+}
+
+// Theme maps path prefixes to console styles.  A style applies to the
+// given path and all its sub-paths; if more than one path prefix
+// matches, the longest one is used.  The empty path can be used to set
+// a default style.
+type Theme map[string]Style
+
+// ParseTheme parses a theme specification of the form
+//
+//	path=color[:prefix],path=color[:prefix],...
+//
+// for example "net=blue:🌐,db=green,db/mysql=green:🐬,=gray".
+func ParseTheme(spec string) (Theme, error) {
+	theme := Theme{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		path, style, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed theme entry %q", entry)
+		}
+		color, prefix, _ := strings.Cut(style, ":")
+		if _, known := ansiColors[color]; color != "" && !known {
+			return nil, fmt.Errorf("unknown color %q", color)
+		}
+		theme[path] = Style{Color: color, Prefix: prefix}
+	}
+	return theme, nil
+}
+
+// lookup returns the style for the given message path.
+func (theme Theme) lookup(path string) (Style, bool) {
+	for i := len(path); i >= 0; i-- {
+		if i > 0 && i < len(path) && path[i] != '/' {
+			continue
+		}
+		if style, ok := theme[path[:i]]; ok {
+			return style, true
+		}
+	}
+	return Style{}, false
+}
+
+// String returns the theme in the format understood by ParseTheme().
+func (theme Theme) String() string {
+	paths := make([]string, 0, len(theme))
+	for path := range theme {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		style := theme[path]
+		parts[i] = path + "=" + style.Color
+		if style.Prefix != "" {
+			parts[i] += ":" + style.Prefix
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Console writes trace messages in human-readable form, one line per
+// message, to a terminal or another io.Writer.  Use the Listen method
+// as the listener argument of Register().
+type Console struct {
+	mutex     sync.Mutex // protects all following fields
+	w         io.Writer
+	theme     Theme
+	tabular   bool
+	pathWidth int
+	abbrev    bool
+	maxBinary int
+	buf       bytes.Buffer
+}
+
+// NewConsole returns a new Console which writes messages to 'w'.
+func NewConsole(w io.Writer) *Console {
+	return &Console{w: w, maxBinary: DefaultMaxBinary}
+}
+
+// SetTheme sets the colors and prefixes used for messages from
+// different paths.  Passing nil disables theming.
+func (c *Console) SetTheme(theme Theme) {
+	c.mutex.Lock()
+	c.theme = theme
+	c.mutex.Unlock()
+}
+
+// SetTabular switches the console to a column-aligned format, where
+// each line shows the time, the priority, the path and the message
+// text in fixed-width columns.  Paths longer than 'pathWidth'
+// characters are truncated on the left.  If 'abbreviate' is true, all
+// but the last component of long paths are first shortened to their
+// initial letter, so that "net/http/server" becomes "n/h/server".  A
+// 'pathWidth' of 0 switches back to the default format.
+func (c *Console) SetTabular(pathWidth int, abbreviate bool) {
+	c.mutex.Lock()
+	c.tabular = pathWidth > 0
+	c.pathWidth = pathWidth
+	c.abbrev = abbreviate
+	c.mutex.Unlock()
+}
+
+// SetMaxBinary sets the maximal number of bytes shown for each binary
+// field of a message, see Bytes().  If 'max' is zero or negative, the
+// fields are shown in full.
+func (c *Console) SetMaxBinary(max int) {
+	c.mutex.Lock()
+	c.maxBinary = max
+	c.mutex.Unlock()
+}
+
+// Listen writes a single message to the console.
+func (c *Console) Listen(m *Message) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	style, _ := c.theme.lookup(m.Path)
+	buf := &c.buf
+	buf.Reset()
+	if style.Color != "" {
+		buf.WriteString(ansiColors[style.Color])
+	}
+	if c.tabular {
+		buf.WriteString(m.Time.Format("15:04:05.000"))
+		fmt.Fprintf(buf, " %-8s ", m.Prio)
+		if style.Prefix != "" {
+			buf.WriteString(style.Prefix)
+			buf.WriteByte(' ')
+		}
+		path := fitPath(m.Path, c.pathWidth, c.abbrev)
+		buf.WriteString(path)
+		for i := utf8.RuneCountInString(path); i < c.pathWidth; i++ {
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte(' ')
+	} else {
+		buf.WriteString(m.Time.Format("15:04:05.000"))
+		buf.WriteByte(':')
+		if style.Prefix != "" {
+			buf.WriteString(style.Prefix)
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(m.Path)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(m.Msg)
+	writeBinary(buf, m.Binary, c.maxBinary)
+	if style.Color != "" {
+		buf.WriteString(ansiReset)
+	}
+	buf.WriteByte('\n')
+	c.w.Write(buf.Bytes())
+}
+
+// fitPath shortens 'path' to at most 'width' characters.
+func fitPath(path string, width int, abbreviate bool) string {
+	if utf8.RuneCountInString(path) <= width {
+		return path
+	}
+	if abbreviate {
+		parts := strings.Split(path, "/")
+		for i := 0; i < len(parts)-1; i++ {
+			if _, size := utf8.DecodeRuneInString(parts[i]); size > 0 {
+				parts[i] = parts[i][:size]
+			}
+			if short := strings.Join(parts, "/"); utf8.RuneCountInString(short) <= width {
+				return short
+			}
+		}
+		path = strings.Join(parts, "/")
+	}
+	runes := []rune(path)
+	if width < 2 {
+		return string(runes[len(runes)-width:])
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
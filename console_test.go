@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseTheme(t *testing.T) {
+	theme, err := ParseTheme("net=blue:N, db=green ,db/mysql=cyan:M,=gray")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := theme.String(); s != "=gray,db=green,db/mysql=cyan:M,net=blue:N" {
+		t.Errorf("wrong theme %q", s)
+	}
+
+	for _, spec := range []string{"net", "net=purple"} {
+		if _, err := ParseTheme(spec); err == nil {
+			t.Errorf("%q: missing error", spec)
+		}
+	}
+}
+
+func TestThemeLookup(t *testing.T) {
+	theme := Theme{
+		"db":       {Color: "green"},
+		"db/mysql": {Color: "cyan"},
+		"":         {Color: "gray"},
+	}
+	testData := []struct{ path, color string }{
+		{"db", "green"},
+		{"db/pg", "green"},
+		{"db/mysql/conn", "cyan"},
+		{"dbx", "gray"},
+		{"net", "gray"},
+	}
+	for _, test := range testData {
+		style, _ := theme.lookup(test.path)
+		if style.Color != test.color {
+			t.Errorf("%s: expected %q, got %q", test.path, test.color, style.Color)
+		}
+	}
+	if _, ok := Theme(nil).lookup("db"); ok {
+		t.Error("nil theme matched")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := NewConsole(buf)
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	c.Listen(&Message{Time: when, Path: "net/http", Prio: PrioInfo, Msg: "hello"})
+	c.SetTheme(Theme{"net": {Color: "blue", Prefix: "N"}})
+	c.Listen(&Message{Time: when, Path: "net/http", Prio: PrioInfo, Msg: "hello"})
+	c.Listen(&Message{Time: when, Path: "db", Prio: PrioInfo, Msg: "hello"})
+
+	expected := "12:30:00.000:net/http: hello\n" +
+		"\x1b[34m12:30:00.000:N net/http: hello\x1b[0m\n" +
+		"12:30:00.000:db: hello\n"
+	if buf.String() != expected {
+		t.Errorf("wrong output %q", buf.String())
+	}
+}
+
+func TestFitPath(t *testing.T) {
+	testData := []struct {
+		path   string
+		width  int
+		abbrev bool
+		out    string
+	}{
+		{"net/http", 10, false, "net/http"},
+		{"net/http/server", 10, false, "…tp/server"},
+		{"net/http/server", 10, true, "n/h/server"},
+		{"net/http/server", 13, true, "n/http/server"},
+		{"network/http/server", 14, true, "n/http/server"},
+		{"net/http/server", 5, true, "…rver"},
+		{"abcdef", 1, false, "f"},
+	}
+	for _, test := range testData {
+		out := fitPath(test.path, test.width, test.abbrev)
+		if out != test.out {
+			t.Errorf("%q/%d/%t: expected %q, got %q",
+				test.path, test.width, test.abbrev, test.out, out)
+		}
+	}
+}
+
+func TestConsoleTabular(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := NewConsole(buf)
+	c.SetTabular(10, true)
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	c.Listen(&Message{Time: when, Path: "db", Prio: PrioError, Msg: "a"})
+	c.Listen(&Message{Time: when, Path: "net/http/server", Prio: -5, Msg: "b"})
+
+	expected := "12:30:00.000 error    db         a\n" +
+		"12:30:00.000 -5       n/h/server b\n"
+	if buf.String() != expected {
+		t.Errorf("wrong output %q", buf.String())
+	}
+}
@@ -39,8 +39,8 @@
 // all paths, using the Register() method.  A minimum priority for
 // messages to be delivered can be used.  Example:
 //
-//     func printTrace(t time.Time, path string, prio trace.Priority, msg string) {
-//             fmt.Printf("%s:%s: %s\n", t.Format("15:04:05.000"), path, msg)
+//     func printTrace(m *trace.Message) {
+//             fmt.Printf("%s:%s: %s\n", m.Time.Format("15:04:05.000"), m.Path, m.Msg)
 //     }
 //
 //     func main() {
@@ -51,4 +51,9 @@
 //
 // This code installs printTrace as a handler which receives all
 // messages sent for the path "client" and its sub-paths.
+//
+// Listeners receive a trace.Message, which holds the time, path,
+// priority and text of the message.  If a listener is registered with
+// the trace.CaptureCaller() option, the message also records the
+// source location and goroutine of the call to trace.T().
 package trace
@@ -0,0 +1,75 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Cli is an example of a command line program instrumented with the
+// trace package.
+//
+// The trace package registers the -trace command line flag, so that
+// trace messages can be enabled when the program is run:
+//
+//	go run ./examples/cli -trace=debug@example/cli file...
+//	go run ./examples/cli -trace file...
+//
+// The second form shows the messages of priority trace.PrioInfo and
+// higher for all paths.  The program counts the lines in the given
+// files.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/seehuhn/trace"
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "show the line count for every file")
+	flag.Parse()
+
+	total := 0
+	for _, name := range flag.Args() {
+		n, err := countLines(name)
+		if err != nil {
+			trace.T("example/cli", trace.PrioError, "%s", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *verbose {
+			fmt.Printf("%8d %s\n", n, name)
+		}
+		total += n
+	}
+	fmt.Printf("%8d total\n", total)
+}
+
+func countLines(name string) (int, error) {
+	trace.T("example/cli/open", trace.PrioDebug, "opening %s", name)
+	fd, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		n++
+	}
+	trace.T("example/cli", trace.PrioInfo, "file=%s lines=%d", name, n)
+	return n, scanner.Err()
+}
@@ -0,0 +1,113 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Httpserver is an example of an HTTP server instrumented with the
+// trace package.
+//
+// Every request passes through a middleware which collects the trace
+// messages of the request into a wide event (see package wide), and the
+// admin handlers are installed below /debug/trace/.  Run the server
+// using
+//
+//	go run ./examples/httpserver -addr localhost:8080
+//
+// and then try for example
+//
+//	curl localhost:8080/hello?name=world
+//	curl localhost:8080/debug/trace/tail?prio=debug
+//	curl localhost:8080/debug/trace/top
+//
+// The output is chosen by trace.AutoConfigure(); use the TRACE
+// environment variable to override it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/admin"
+	"github.com/seehuhn/trace/wide"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	flag.Parse()
+
+	if err := trace.AutoConfigure(); err != nil {
+		log.Fatal(err)
+	}
+	defer trace.Shutdown()
+
+	sites := trace.NewSiteCounter()
+	trace.Register(sites.Listen, "", trace.PrioDebug)
+
+	mux := http.NewServeMux()
+	mux.Handle("/hello", withTrace(http.HandlerFunc(hello)))
+	mux.Handle("/debug/trace/tail", admin.TailHandler())
+	mux.Handle("/debug/trace/top", admin.TopHandler(sites))
+	mux.Handle("/debug/trace/profile", admin.ProfileHandler())
+	mux.Handle("/debug/trace/selftest", admin.SelfTestHandler())
+	mux.Handle("/metrics", admin.MetricsHandler())
+
+	trace.T("example/http", trace.PrioInfo, "listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// withTrace wraps 'next' so that each request is recorded as a wide
+// event with path "example/http/request".
+func withTrace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ev := wide.Start(r.Context(), "example/http/request")
+		defer ev.Finish()
+		ev.Set("method", r.Method)
+		ev.Set("url", r.URL.Path)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		ev.Set("status", sw.status)
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.FormValue("name")
+	if name == "" {
+		wide.T(ctx, "example/http/hello", trace.PrioError, "missing name")
+		http.Error(w, "missing name", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	greeting := fmt.Sprintf("hello, %s\n", name)
+	wide.T(ctx, "example/http/hello", trace.PrioDebug,
+		"name=%q took=%s", name, time.Since(start))
+	fmt.Fprint(w, greeting)
+}
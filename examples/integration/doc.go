@@ -0,0 +1,25 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package integration contains tests which exercise the components of
+// the trace module together, the way a deployed program uses them: a
+// program installs sinks and admin handlers, forwards its messages to
+// a collector using the remote package, and the collector writes them
+// to a trace file.  The package has no exported API; run the tests
+// using
+//
+//	go test ./examples/integration
+package integration
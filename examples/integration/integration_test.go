@@ -0,0 +1,164 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package integration
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/admin"
+	"github.com/seehuhn/trace/remote"
+)
+
+// collector starts a remote.Receiver which appends all received
+// messages to the trace file 'name'.  The address of the receiver is
+// returned.
+func collector(t *testing.T, name string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := trace.NewFileSink(name)
+	if err := out.Open(); err != nil {
+		t.Fatal(err)
+	}
+	r := remote.NewReceiver(func(source string, m *trace.Message) {
+		out.Listen(m)
+		out.Flush()
+	})
+	go r.Serve(l)
+	t.Cleanup(func() {
+		r.Close()
+		out.Close()
+	})
+	return l.Addr().String()
+}
+
+// waitForFile waits until the trace file 'name' contains a message
+// with text 'msg'.
+func waitForFile(t *testing.T, name, msg string) *trace.Message {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(name)
+		for _, line := range strings.Split(string(data), "\n") {
+			m := &trace.Message{}
+			if json.Unmarshal([]byte(line), m) == nil && m.Msg == msg {
+				return m
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("message %q not found in %s", msg, name)
+	return nil
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestIntegration(t *testing.T) {
+	trace.ResetStats()
+	dir := t.TempDir()
+	collected := filepath.Join(dir, "collected.json")
+	local := filepath.Join(dir, "local.json")
+
+	// the program side: a remote sender and a local trace file
+	sender := remote.NewSender("tcp", collector(t, collected))
+	h1, err := trace.Install(sender, "app", trace.PrioDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h1.Unregister()
+	h2, err := trace.Install(trace.NewFileSink(local), "app", trace.PrioInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Unregister()
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/trace/tail", admin.TailHandler())
+	mux.Handle("/debug/trace/selftest", admin.SelfTestHandler())
+	mux.Handle("/metrics", admin.MetricsHandler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The tail handler has attached its mirror once the response
+	// header has been received.
+	resp, err := http.Get(server.URL + "/debug/trace/tail?path=app&prio=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	trace.T("app/db", trace.PrioInfo, "query=%q rows=%d", "select 1", 1)
+	trace.T("app/db", trace.PrioDebug, "connection reused")
+	const want = `query="select 1" rows=1`
+
+	tail := bufio.NewScanner(resp.Body)
+	if !tail.Scan() {
+		t.Fatal("no message from the tail handler")
+	}
+	m := &trace.Message{}
+	if err := json.Unmarshal(tail.Bytes(), m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Path != "app/db" || m.Msg != want {
+		t.Errorf("wrong message from tail handler: %s %q", m.Path, m.Msg)
+	}
+
+	// delivery to the sinks
+	if err := sender.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	m = waitForFile(t, collected, want)
+	if m.Delivered.IsZero() {
+		t.Error("delivery time not recorded by the sender")
+	}
+	waitForFile(t, collected, "connection reused")
+	waitForFile(t, local, want)
+
+	// admin endpoints
+	status, body := get(t, server.URL+"/debug/trace/selftest")
+	if status != http.StatusOK {
+		t.Errorf("self-test failed: %d %s", status, body)
+	}
+
+	_, body = get(t, server.URL+"/metrics")
+	line := `trace_messages_total{path="app/db",prio="info",outcome="emitted"} 1`
+	if !strings.Contains(body, line) {
+		t.Errorf("metrics do not contain %q:\n%s", line, body)
+	}
+}
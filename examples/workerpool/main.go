@@ -0,0 +1,88 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Workerpool is an example of a pool of worker goroutines instrumented
+// with the trace package.
+//
+// Every job is recorded as a wide event (see package wide), which plays
+// the role of a span: the event carries the job ID, the worker and the
+// duration, together with the messages emitted while the job was
+// running.  The messages go through an AsyncListener, so that slow
+// output does not hold up the workers.  Run the example using
+//
+//	go run ./examples/workerpool -workers 4 -jobs 20
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/wide"
+)
+
+func main() {
+	workers := flag.Int("workers", 4, "number of worker goroutines")
+	jobs := flag.Int("jobs", 20, "number of jobs to run")
+	flag.Parse()
+
+	out := trace.NewAsyncListener(trace.NewConsole(os.Stderr).Listen, 1024)
+	handle := trace.Register(out.Listen, "example/pool", trace.PrioDebug)
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for id := range queue {
+				runJob(context.Background(), worker, id)
+			}
+		}(i)
+	}
+	for id := 1; id <= *jobs; id++ {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+	trace.T("example/pool", trace.PrioInfo, "%d jobs done", *jobs)
+
+	handle.Unregister()
+	out.Close()
+}
+
+// runJob simulates a job taking a random amount of time.  One in ten
+// jobs fails.
+func runJob(ctx context.Context, worker, id int) {
+	ctx, ev := wide.Start(ctx, "example/pool/job")
+	defer ev.Finish()
+	ev.Set("job", id)
+	ev.Set("worker", worker)
+
+	d := time.Duration(rand.IntN(50)) * time.Millisecond
+	wide.T(ctx, "example/pool/job", trace.PrioDebug, "job=%d sleep=%s", id, d)
+	time.Sleep(d)
+	if rand.IntN(10) == 0 {
+		wide.T(ctx, "example/pool/job", trace.PrioError, "job=%d failed", id)
+		ev.Set("ok", false)
+		return
+	}
+	ev.Set("ok", true)
+}
@@ -0,0 +1,166 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSink is a Sink which appends messages to a trace file, in the
+// format written by JSONWriter.
+type FileSink struct {
+	name string
+
+	mutex sync.Mutex // protects the following fields
+	file  *os.File
+	enc   *json.Encoder
+	err   error
+}
+
+// NewFileSink returns a new FileSink which writes to the named file.
+// The file is opened when the sink is installed, see Install().
+func NewFileSink(name string) *FileSink {
+	return &FileSink{name: name}
+}
+
+// Open opens the file for appending, creating it if necessary.
+func (s *FileSink) Open() error {
+	f, err := os.OpenFile(s.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.file = f
+	s.enc = json.NewEncoder(f)
+	s.err = nil
+	s.mutex.Unlock()
+	return nil
+}
+
+// Listen writes a single message, with the Delivered field set to the
+// current time unless it is already set.  Write errors are reported by
+// HealthCheck.
+func (s *FileSink) Listen(m *Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.enc == nil {
+		return
+	}
+	out := *m
+	if out.Delivered.IsZero() {
+		out.Delivered = time.Now()
+	}
+	if err := s.enc.Encode(&out); err != nil {
+		s.err = err
+	}
+}
+
+// Flush commits the file contents to stable storage.
+func (s *FileSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Sync()
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	s.enc = nil
+	return err
+}
+
+// Reopen closes the file and opens it again, creating a new file if
+// the old one has been renamed or removed.  If the file cannot be
+// opened, messages are dropped and the error is reported by
+// HealthCheck until the file is opened successfully.
+func (s *FileSink) Reopen() error {
+	closeErr := s.Close()
+	if err := s.Open(); err != nil {
+		s.mutex.Lock()
+		s.err = err
+		s.mutex.Unlock()
+		return err
+	}
+	return closeErr
+}
+
+// HealthCheck returns the most recent write error, if any.
+func (s *FileSink) HealthCheck() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// verifyWindow is the number of bytes at the end of a trace file which
+// FileSink.Verify searches for the probe message.
+const verifyWindow = 1 << 20
+
+// Verify reads the file back and checks that the message 'probe' has
+// been written to it.  Only the last megabyte of the file is searched.
+func (s *FileSink) Verify(ctx context.Context, probe *Message) error {
+	f, err := os.Open(s.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	start := max(fi.Size()-verifyWindow, 0)
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, verifyWindow)
+	if start > 0 {
+		scanner.Scan() // skip the partial first line
+	}
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m := &Message{}
+		if json.Unmarshal(scanner.Bytes(), m) != nil {
+			continue
+		}
+		if m.Path == probe.Path && m.Msg == probe.Msg {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("probe message not found in %s", s.name)
+}
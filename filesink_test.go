@@ -0,0 +1,92 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "run.jsonl")
+	s := NewFileSink(name)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	probe := &Message{Path: "test", Msg: "hello"}
+	s.Listen(probe)
+	if err := s.Flush(); err != nil {
+		t.Error(err)
+	}
+	if err := s.Verify(context.Background(), probe); err != nil {
+		t.Error(err)
+	}
+	if err := s.Verify(context.Background(), &Message{Path: "test", Msg: "other"}); err == nil {
+		t.Error("missing error for unknown message")
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := NewJSONReader(f).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Msg != "hello" || m.Delivered.IsZero() {
+		t.Errorf("wrong message %v", m)
+	}
+}
+
+func TestFileSinkReopenError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileSink(filepath.Join(dir, "run.jsonl"))
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Simulate log rotation into a directory which has gone away.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reopen(); err == nil {
+		t.Fatal("missing error from Reopen")
+	}
+	if s.HealthCheck() == nil {
+		t.Error("failed Reopen not reported by HealthCheck")
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.HealthCheck(); err != nil {
+		t.Errorf("unexpected error %v after successful Reopen", err)
+	}
+}
@@ -2,15 +2,14 @@ package trace
 
 import (
 	"flag"
-	"fmt"
-	"strconv"
+	"os"
 	"strings"
-	"time"
 )
 
-func flagListener(t time.Time, path string, prio Priority, msg string) {
-	fmt.Printf("%s:%s: %s\n", t.Format("15:04:05.000"), path, msg)
-}
+// flagConsole receives the messages enabled by the -trace command line
+// flag.  The environment variable TRACE_THEME can be used to set a
+// theme, in the format understood by ParseTheme().
+var flagConsole = NewConsole(os.Stdout)
 
 type traceInfo struct {
 	handle ListenerHandle
@@ -29,24 +28,14 @@ func (t *traceInfo) Set(value string) error {
 	switch parts[0] {
 	case "none":
 		return nil
-	case "critical":
-		prio = PrioCritical
-	case "error":
-		prio = PrioError
-	case "true", "info":
+	case "true":
 		prio = PrioInfo
-	case "debug":
-		prio = PrioDebug
-	case "verbose":
-		prio = PrioVerbose
-	case "all":
-		prio = PrioAll
 	default:
-		x, err := strconv.Atoi(parts[0])
+		var err error
+		prio, err = ParsePriority(parts[0])
 		if err != nil {
-			return fmt.Errorf("cannot parse priority %q", parts[0])
+			return err
 		}
-		prio = Priority(x)
 	}
 
 	var path string
@@ -55,7 +44,7 @@ func (t *traceInfo) Set(value string) error {
 	}
 
 	t = &traceInfo{
-		handle: Register(flagListener, path, prio),
+		handle: Register(flagConsole.Listen, path, prio),
 		prio:   prio,
 		path:   path,
 	}
@@ -68,23 +57,7 @@ func (t *traceInfo) String() string {
 	if t == nil {
 		return "none"
 	}
-	var s string
-	switch t.prio {
-	case PrioCritical:
-		s = "critical"
-	case PrioError:
-		s = "error"
-	case PrioInfo:
-		s = "info"
-	case PrioDebug:
-		s = "debug"
-	case PrioVerbose:
-		s = "verbose"
-	case PrioAll:
-		s = "all"
-	default:
-		s = strconv.Itoa(int(t.prio))
-	}
+	s := t.prio.String()
 	if t.path != "" {
 		s = s + "@" + t.path
 	}
@@ -99,4 +72,12 @@ var traceFlag *traceInfo
 
 func init() {
 	flag.Var(traceFlag, "trace", "enable tracing for priority@path")
+	if os.Getenv("TRACE_STRICT") != "" {
+		SetStrict(true)
+	}
+	if spec := os.Getenv("TRACE_THEME"); spec != "" {
+		if theme, err := ParseTheme(spec); err == nil {
+			flagConsole.SetTheme(theme)
+		}
+	}
 }
@@ -0,0 +1,94 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Reopener is implemented by sinks which hold operating system
+// resources, like open files or network connections, which can be
+// re-established.  Reopen closes the resources and opens them again.
+// Messages arriving while Reopen runs may be lost.
+type Reopener interface {
+	Reopen() error
+}
+
+// ReinitAfterFork re-establishes the operating system resources used
+// for tracing, after the process has changed its identity or after the
+// resources have been invalidated externally.  All installed sinks
+// which implement Reopener are reopened, in the order in which they
+// were installed, and the outputs set up by Configure() are opened
+// again.  For example, FileSink re-opens its file (so that a file
+// renamed by a log rotation tool is replaced by a new one), and
+// remote.Sender reconnects using a new stream ID.  The combined errors
+// are returned.
+//
+// The following describes how the package behaves across fork, exec
+// and daemonization:
+//
+//   - The Go runtime does not support fork() without a subsequent
+//     exec(); after such a fork, only the calling thread survives and
+//     the background goroutines of the package, like the workers of
+//     AsyncListener and remote.Sender, are gone.  Such a child process
+//     must call exec() before doing anything else.
+//   - Files and sockets opened by the package use the close-on-exec
+//     flag, so that programs started using os/exec or syscall.Exec do
+//     not inherit them.  A new program image, including a daemonized
+//     copy of the program which re-executes itself, starts without any
+//     listeners and must configure tracing again, for example using
+//     ConfigureFromEnv(); the environment variable TRACE is inherited.
+//   - Before handing over to a new process, for example for a
+//     graceful restart, the old process should call Shutdown(), so
+//     that buffered messages are written out.
+//   - The package holds no locks on files, so that there are no
+//     stale locks after a process exits or hands over its files.
+//
+// ReinitAfterFork is meant for programs which hand over their process
+// identity in other ways, for example after checkpoint/restore, and for
+// re-opening files after log rotation.
+func ReinitAfterFork() error {
+	sinks := installedSinks()
+	handles := make([]ListenerHandle, 0, len(sinks))
+	for handle := range sinks {
+		handles = append(handles, handle)
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+
+	var errs []error
+	for _, handle := range handles {
+		r, ok := sinks[handle].sink.(Reopener)
+		if !ok {
+			continue
+		}
+		if err := r.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %d: %w", handle, err))
+		}
+	}
+
+	configMutex.Lock()
+	rules := configRules
+	configMutex.Unlock()
+	if rules != nil {
+		if err := Configure(rules); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReinitAfterFork(t *testing.T) {
+	dir := t.TempDir()
+	sinkFile := filepath.Join(dir, "sink.jsonl")
+	configFile := filepath.Join(dir, "config.log")
+
+	handle, err := Install(NewFileSink(sinkFile), "fork", PrioInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Unregister()
+	err = Configure([]Rule{{Path: "fork", Level: "info", Output: configFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(nil)
+
+	T("fork", PrioInfo, "before")
+	for _, name := range []string{sinkFile, configFile} {
+		if err := os.Rename(name, name+".old"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ReinitAfterFork(); err != nil {
+		t.Fatal(err)
+	}
+	T("fork", PrioInfo, "after")
+
+	for _, name := range []string{sinkFile, configFile} {
+		old, err := os.ReadFile(name + ".old")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cur, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(old), "before") || strings.Contains(string(old), "after") {
+			t.Errorf("%s: wrong old contents %q", filepath.Base(name), old)
+		}
+		if !strings.Contains(string(cur), "after") || strings.Contains(string(cur), "before") {
+			t.Errorf("%s: wrong new contents %q", filepath.Base(name), cur)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RouteRule is an entry of the routing table, see SetRoutes().  A
+// message matches the rule if its path is Path or a sub-path of Path,
+// its priority is at least Prio, and its text contains all the key=value
+// fields given in Tags (see ParseFields()).
+type RouteRule struct {
+	Path  string            `json:"path"`
+	Prio  Priority          `json:"prio"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Group string            `json:"group"`
+}
+
+// matches reports whether 'm' matches the path, priority and tags of
+// the rule.
+func (rule *RouteRule) matches(m *Message) bool {
+	if m.Prio < rule.Prio {
+		return false
+	}
+	if rule.Path != "" && m.Path != rule.Path &&
+		!strings.HasPrefix(m.Path, rule.Path+"/") {
+		return false
+	}
+	if len(rule.Tags) == 0 {
+		return true
+	}
+	fields := ParseFields(m.Msg)
+	for key, value := range rule.Tags {
+		if fields[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// GroupInfo describes a listener group, see Groups().
+type GroupInfo struct {
+	Name    string      `json:"name"`
+	Members int         `json:"members"`
+	Rules   []RouteRule `json:"rules"`
+}
+
+var (
+	routeMutex   sync.Mutex // protects the following variables
+	routeRules   []RouteRule
+	routeHandles []ListenerHandle
+)
+
+// JoinGroup registers 'listener' as a member of the named group, for
+// example "console", "archive" or "alerts".  Group members receive
+// the messages assigned to the group by the routing table (see
+// SetRoutes()), by Route arguments to T() and by the output
+// "group:name" of Configure(), but no messages based on a path of
+// their own.  This way, the selection of the messages to capture is
+// separate from the choice of their destination.  Caller information
+// is not available for messages delivered to groups.
+func JoinGroup(group string, listener Listener, opts ...Option) ListenerHandle {
+	opts = append(opts, InGroup(group), func(c *listenerInfo) {
+		c.groupOnly = true
+	})
+	// Since the listener receives no messages by path, the priority
+	// is not used.
+	return Register(listener, "", PrioMax, opts...)
+}
+
+// SetRoutes replaces the routing table.  Every message is delivered to
+// each group with at least one matching rule, where it is passed to
+// all members of the group.
+func SetRoutes(rules []RouteRule) {
+	routeMutex.Lock()
+	defer routeMutex.Unlock()
+	for _, handle := range routeHandles {
+		handle.Unregister()
+	}
+	routeRules = append([]RouteRule{}, rules...)
+	routeHandles = nil
+	for i := range routeRules {
+		l := &routeListener{rules: routeRules, idx: i}
+		rule := &routeRules[i]
+		routeHandles = append(routeHandles, Register(l.Listen, rule.Path, rule.Prio))
+	}
+}
+
+// Groups returns the known groups, with the number of members and the
+// routing rules for each group, ordered by name.
+func Groups() []GroupInfo {
+	byName := map[string]*GroupInfo{}
+	get := func(name string) *GroupInfo {
+		g := byName[name]
+		if g == nil {
+			g = &GroupInfo{Name: name}
+			byName[name] = g
+		}
+		return g
+	}
+	routeMutex.Lock()
+	for _, rule := range routeRules {
+		g := get(rule.Group)
+		g.Rules = append(g.Rules, rule)
+	}
+	routeMutex.Unlock()
+	listenerMutex.Lock()
+	for _, c := range listeners {
+		if c.group != "" {
+			get(c.group).Members++
+		}
+	}
+	listenerMutex.Unlock()
+
+	res := make([]GroupInfo, 0, len(byName))
+	for _, g := range byName {
+		res = append(res, *g)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// routeListener delivers messages for the rule rules[idx] to its
+// group, unless an earlier rule for the same group matches, so that
+// every group receives a message at most once.
+type routeListener struct {
+	rules []RouteRule
+	idx   int
+}
+
+func (l *routeListener) Listen(m *Message) {
+	rule := &l.rules[l.idx]
+	if !rule.matches(m) {
+		return
+	}
+	for i := 0; i < l.idx; i++ {
+		if l.rules[i].Group == rule.Group && l.rules[i].matches(m) {
+			return
+		}
+	}
+	deliverToGroup(rule.Group, m)
+}
+
+// deliverToGroup passes 'm' to all members of the named group.
+// Messages which have already been delivered to the group, because of
+// a Route argument or because the member's own path and priority
+// match, are not delivered a second time.
+func deliverToGroup(group string, m *Message) {
+	s := current.Load()
+	if s == nil || m.Route == group {
+		return
+	}
+	for _, c := range s.byGroup[group] {
+		if !c.paused.Load() && !c.matchesPath(m) {
+			c.deliver(m)
+		}
+	}
+}
+
+// matchesPath reports whether 'm' is delivered to 'c' based on the
+// path and priority the listener has been registered for.
+func (c *listenerInfo) matchesPath(m *Message) bool {
+	if c.groupOnly || m.Prio < c.prio {
+		return false
+	}
+	return c.path == "" || m.Path == c.path ||
+		strings.HasPrefix(m.Path, c.path+"/")
+}
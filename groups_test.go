@@ -0,0 +1,103 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	var console, alerts []string
+	h1 := JoinGroup("console", func(m *Message) { console = append(console, m.Msg) })
+	defer h1.Unregister()
+	h2 := JoinGroup("alerts", func(m *Message) { alerts = append(alerts, m.Msg) })
+	defer h2.Unregister()
+	SetRoutes([]RouteRule{
+		{Path: "groups", Prio: PrioInfo, Group: "console"},
+		{Path: "groups/db", Prio: PrioDebug, Group: "console"},
+		{Path: "groups", Prio: PrioError, Tags: map[string]string{"team": "db"}, Group: "alerts"},
+	})
+	defer SetRoutes(nil)
+
+	T("groups/http", PrioInfo, "request done")
+	T("groups/http", PrioDebug, "details")
+	T("groups/db", PrioDebug, "query done")
+	T("groups/db", PrioError, "deadlock team=db")
+	T("groups/db", PrioError, "deadlock team=web")
+	T("groups/db", PrioVerbose, "page me", Route("alerts"))
+
+	expected := "request done,query done,deadlock team=db,deadlock team=web"
+	if got := strings.Join(console, ","); got != expected {
+		t.Errorf("wrong console messages %q", got)
+	}
+	if got := strings.Join(alerts, ","); got != "deadlock team=db,page me" {
+		t.Errorf("wrong alerts %q", got)
+	}
+
+	groups := Groups()
+	if len(groups) != 2 || groups[0].Name != "alerts" || groups[0].Members != 1 ||
+		len(groups[0].Rules) != 1 || groups[1].Name != "console" ||
+		len(groups[1].Rules) != 2 {
+		t.Errorf("wrong groups %v", groups)
+	}
+}
+
+func TestGroupsNoDuplicates(t *testing.T) {
+	var alerts, pathMember []string
+	h1 := JoinGroup("alerts", func(m *Message) { alerts = append(alerts, m.Msg) })
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) { pathMember = append(pathMember, m.Msg) },
+		"groupsdup", PrioInfo, InGroup("console"))
+	defer h2.Unregister()
+	SetRoutes([]RouteRule{
+		{Path: "groupsdup", Prio: PrioError, Group: "alerts"},
+		{Path: "groupsdup", Prio: PrioDebug, Group: "console"},
+	})
+	defer SetRoutes(nil)
+
+	// Routed to "alerts" both by the Route argument and by the rule.
+	T("groupsdup", PrioError, "routed", Route("alerts"))
+	// Matches the member's own path and the rule for "console".
+	T("groupsdup", PrioInfo, "by path")
+	// Only matches the rule for "console".
+	T("groupsdup", PrioDebug, "by rule")
+
+	if got := strings.Join(alerts, ","); got != "routed" {
+		t.Errorf("wrong alerts %q", got)
+	}
+	if got := strings.Join(pathMember, ","); got != "routed,by path,by rule" {
+		t.Errorf("wrong messages for path member %q", got)
+	}
+}
+
+func TestConfigureGroup(t *testing.T) {
+	var msgs []string
+	handle := JoinGroup("archive", func(m *Message) { msgs = append(msgs, m.Msg) })
+	defer handle.Unregister()
+	err := Configure([]Rule{{Path: "cfggroup", Level: "info", Output: "group:archive"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(nil)
+
+	T("cfggroup", PrioInfo, "archived")
+	T("cfggroup", PrioDebug, "not archived")
+	if strings.Join(msgs, ",") != "archived" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+}
@@ -0,0 +1,249 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package index implements an inverted index over trace files, as
+// written by trace.JSONWriter, to find messages in large recordings
+// without reading the whole file.
+//
+// The index maps search terms to the byte offsets of the matching
+// messages in the trace file.  Search terms are the words of the
+// message text, converted to lower case, as well as terms of the form
+// "path:p" for every prefix p of the message path and "prio:name" for
+// messages with one of the pre-defined priorities.  Example:
+//
+//	idx, err := index.Build(f)
+//	// ... error handling
+//	for _, offset := range idx.Lookup("path:db", "timeout") {
+//		m, err := index.ReadMessage(f, offset)
+//		// ...
+//	}
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/seehuhn/trace"
+)
+
+// Index is an inverted index over a single trace file.
+type Index struct {
+	// postings maps every search term to the offsets of the matching
+	// messages, in increasing order.
+	postings map[string][]int64
+}
+
+// Build reads a trace file from 'r' and returns the index for the
+// file.
+func Build(r io.Reader) (*Index, error) {
+	idx := &Index{postings: map[string][]int64{}}
+	rd := bufio.NewReader(r)
+	var offset int64
+	for {
+		line, err := rd.ReadBytes('\n')
+		if len(line) > 0 && strings.TrimSpace(string(line)) != "" {
+			m := &trace.Message{}
+			if err := json.Unmarshal(line, m); err != nil {
+				return nil, err
+			}
+			idx.add(m, offset)
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return idx, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (idx *Index) add(m *trace.Message, offset int64) {
+	for _, term := range Terms(m) {
+		list := idx.postings[term]
+		if len(list) > 0 && list[len(list)-1] == offset {
+			continue
+		}
+		idx.postings[term] = append(list, offset)
+	}
+}
+
+// Terms returns the search terms under which a message is indexed.
+func Terms(m *trace.Message) []string {
+	terms := []string{"path:"}
+	for i := 1; i <= len(m.Path); i++ {
+		if i == len(m.Path) || m.Path[i] == '/' {
+			terms = append(terms, "path:"+m.Path[:i])
+		}
+	}
+	switch m.Prio {
+	case trace.PrioCritical, trace.PrioError, trace.PrioInfo,
+		trace.PrioDebug, trace.PrioVerbose:
+		terms = append(terms, "prio:"+m.Prio.String())
+	}
+	return append(terms, words(m.Msg)...)
+}
+
+// words splits 's' into lower case words.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
+
+// Lookup returns the offsets of all messages which match every one of
+// the given terms, in increasing order.  Terms which are not of the
+// form "path:..." or "prio:..." are converted to lower case.  If no
+// terms are given, nil is returned.
+func (idx *Index) Lookup(terms ...string) []int64 {
+	var res []int64
+	for i, term := range terms {
+		if !strings.HasPrefix(term, "path:") && !strings.HasPrefix(term, "prio:") {
+			term = strings.ToLower(term)
+		}
+		list := idx.postings[term]
+		if i == 0 {
+			res = append([]int64{}, list...)
+		} else {
+			res = intersect(res, list)
+		}
+		if len(res) == 0 {
+			return nil
+		}
+	}
+	return res
+}
+
+// intersect returns the elements contained in both of the sorted
+// lists 'a' and 'b'.  The result overwrites 'a'.
+func intersect(a, b []int64) []int64 {
+	res := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// ReadMessage reads the message starting at byte offset 'offset' of a
+// trace file.
+func ReadMessage(r io.ReaderAt, offset int64) (*trace.Message, error) {
+	rd := bufio.NewReader(io.NewSectionReader(r, offset, 1<<62))
+	line, err := rd.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	m := &trace.Message{}
+	if err := json.Unmarshal(line, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// indexMagic identifies the index file format.
+const indexMagic = "trace-index-1"
+
+var errFormat = errors.New("not a trace index file")
+
+// WriteTo writes the index to 'w', in a compact binary format which can
+// be read using Read().
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	enc := gob.NewEncoder(cw)
+	encoded := make(map[string][]byte, len(idx.postings))
+	for term, list := range idx.postings {
+		encoded[term] = encodePostings(list)
+	}
+	if err := enc.Encode(indexMagic); err != nil {
+		return cw.n, err
+	}
+	err := enc.Encode(encoded)
+	return cw.n, err
+}
+
+// Read reads an index in the format written by WriteTo().
+func Read(r io.Reader) (*Index, error) {
+	dec := gob.NewDecoder(r)
+	var magic string
+	if err := dec.Decode(&magic); err != nil || magic != indexMagic {
+		return nil, errFormat
+	}
+	var encoded map[string][]byte
+	if err := dec.Decode(&encoded); err != nil {
+		return nil, err
+	}
+	idx := &Index{postings: make(map[string][]int64, len(encoded))}
+	for term, data := range encoded {
+		list, err := decodePostings(data)
+		if err != nil {
+			return nil, err
+		}
+		idx.postings[term] = list
+	}
+	return idx, nil
+}
+
+// encodePostings stores a sorted list of offsets as a sequence of
+// varint-encoded differences.
+func encodePostings(list []int64) []byte {
+	buf := make([]byte, 0, len(list)*2)
+	var prev int64
+	for _, x := range list {
+		buf = binary.AppendUvarint(buf, uint64(x-prev))
+		prev = x
+	}
+	return buf
+}
+
+func decodePostings(data []byte) ([]int64, error) {
+	var list []int64
+	var prev int64
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errFormat
+		}
+		prev += int64(delta)
+		list = append(list, prev)
+		data = data[n:]
+	}
+	return list, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,81 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestIndex(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := trace.NewJSONWriter(buf)
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	for _, m := range []*trace.Message{
+		{Time: when, Path: "db/mysql", Prio: trace.PrioError, Msg: "Query timeout after 5s"},
+		{Time: when, Path: "db/pg", Prio: trace.PrioInfo, Msg: "connected"},
+		{Time: when, Path: "net", Prio: trace.PrioError, Msg: "dial timeout, timeout"},
+	} {
+		w.Listen(m)
+	}
+	data := buf.Bytes()
+
+	idx, err := Build(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &bytes.Buffer{}
+	if _, err := idx.WriteTo(saved); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = Read(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		terms []string
+		msgs  []string
+	}{
+		{[]string{"TIMEOUT"}, []string{"Query timeout after 5s", "dial timeout, timeout"}},
+		{[]string{"path:db", "timeout"}, []string{"Query timeout after 5s"}},
+		{[]string{"prio:info"}, []string{"connected"}},
+		{[]string{"path:d"}, nil},
+		{[]string{"timeout", "missing"}, nil},
+	}
+	for _, test := range testData {
+		var msgs []string
+		for _, offset := range idx.Lookup(test.terms...) {
+			m, err := ReadMessage(bytes.NewReader(data), offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, m.Msg)
+		}
+		if !reflect.DeepEqual(msgs, test.msgs) {
+			t.Errorf("%q: expected %q, got %q", test.terms, test.msgs, msgs)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("trace file accepted as index")
+	}
+}
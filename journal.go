@@ -0,0 +1,94 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalSocket is the address of the systemd journal's native
+// protocol socket.
+var journalSocket = "/run/systemd/journal/socket"
+
+// JournalListener forwards trace messages to the systemd journal,
+// using the journal's native protocol.  Use the Listen method as the
+// listener argument of Register().
+type JournalListener struct {
+	conn *net.UnixConn
+}
+
+// NewJournalListener connects to the local systemd journal.  For every
+// message, the message path is stored in the SYSLOG_IDENTIFIER field
+// and the message priority is mapped to the corresponding syslog
+// severity in the PRIORITY field.  The original priority value is
+// stored in the TRACE_PRIORITY field.  If caller information is
+// available, it is stored in the CODE_FILE, CODE_LINE and CODE_FUNC
+// fields.
+func NewJournalListener() (*JournalListener, error) {
+	addr := &net.UnixAddr{Name: journalSocket, Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &JournalListener{conn: conn}, nil
+}
+
+// Listen sends a single trace message to the journal.  Messages which
+// cannot be delivered are discarded.
+func (j *JournalListener) Listen(m *Message) {
+	buf := &bytes.Buffer{}
+	journalField(buf, "PRIORITY", strconv.Itoa(syslogSeverity(m.Prio)))
+	journalField(buf, "SYSLOG_IDENTIFIER", m.Path)
+	journalField(buf, "TRACE_PRIORITY", strconv.Itoa(int(m.Prio)))
+	journalField(buf, "SYSLOG_TIMESTAMP", m.Time.Format(time.RFC3339Nano))
+	if m.File != "" {
+		journalField(buf, "CODE_FILE", m.File)
+		journalField(buf, "CODE_LINE", strconv.Itoa(m.Line))
+		journalField(buf, "CODE_FUNC", m.Func)
+	}
+	journalField(buf, "MESSAGE", m.Msg)
+	j.conn.Write(buf.Bytes())
+}
+
+// journalField appends one field in the journal's native protocol
+// format to 'buf'.  Values containing newlines use the binary,
+// length-prefixed encoding.
+func journalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// Close closes the connection to the journal.
+func (j *JournalListener) Close() error {
+	return j.conn.Close()
+}
@@ -0,0 +1,79 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalField(t *testing.T) {
+	buf := &bytes.Buffer{}
+	journalField(buf, "MESSAGE", "hello")
+	if s := buf.String(); s != "MESSAGE=hello\n" {
+		t.Errorf("wrong encoding %q", s)
+	}
+
+	buf.Reset()
+	journalField(buf, "MESSAGE", "a\nb")
+	expected := "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"
+	if s := buf.String(); s != expected {
+		t.Errorf("wrong binary encoding %q", s)
+	}
+}
+
+func TestJournalListener(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "journal.sock")
+	server, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Skip("cannot create unix socket:", err)
+	}
+	defer server.Close()
+
+	saved := journalSocket
+	journalSocket = name
+	defer func() { journalSocket = saved }()
+
+	j, err := NewJournalListener()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	j.Listen(&Message{Time: time.Now(), Path: "db/mysql", Prio: PrioError, Msg: "connection lost"})
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(buf[:n])
+	for _, field := range []string{
+		"PRIORITY=3\n",
+		"SYSLOG_IDENTIFIER=db/mysql\n",
+		"TRACE_PRIORITY=1000\n",
+		"MESSAGE=connection lost\n",
+	} {
+		if !bytes.Contains(buf[:n], []byte(field)) {
+			t.Errorf("field %q missing in %q", field, data)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONWriter writes trace messages to an io.Writer, encoding each
+// message as a JSON object on a line of its own.  Files written by a
+// JSONWriter are referred to as trace files and can be read using a
+// JSONReader.  Use the Listen method as the listener argument of
+// Register().
+type JSONWriter struct {
+	mutex     sync.Mutex // protects enc and maxBinary
+	enc       *json.Encoder
+	maxBinary int
+}
+
+// NewJSONWriter returns a new JSONWriter which writes messages to 'w'.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{enc: json.NewEncoder(w), maxBinary: DefaultMaxBinary}
+}
+
+// SetMaxBinary sets the maximal number of bytes written for each binary
+// field of a message, see Bytes().  The number of bytes left out is
+// stored in the Truncated field of the Binary value.  If 'max' is zero
+// or negative, the fields are written in full.
+func (j *JSONWriter) SetMaxBinary(max int) {
+	j.mutex.Lock()
+	j.maxBinary = max
+	j.mutex.Unlock()
+}
+
+// Listen writes a single message, with the Delivered field set to the
+// current time unless it is already set, for example in messages read
+// back from a file.  Write errors are ignored.
+func (j *JSONWriter) Listen(m *Message) {
+	j.mutex.Lock()
+	out := *m
+	if out.Delivered.IsZero() {
+		out.Delivered = time.Now()
+	}
+	out.Binary = limitBinary(out.Binary, j.maxBinary)
+	j.enc.Encode(&out)
+	j.mutex.Unlock()
+}
+
+// JSONReader reads trace messages in the format written by JSONWriter.
+type JSONReader struct {
+	dec *json.Decoder
+}
+
+// NewJSONReader returns a new JSONReader which reads messages from
+// 'r'.
+func NewJSONReader(r io.Reader) *JSONReader {
+	return &JSONReader{dec: json.NewDecoder(r)}
+}
+
+// Read returns the next message.  At the end of the input, Read returns
+// io.EOF.
+func (r *JSONReader) Read() (*Message, error) {
+	m := &Message{}
+	err := r.dec.Decode(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewJSONWriter(buf)
+	handle := Register(w.Listen, "json", PrioAll, CaptureCaller())
+	T("json/a", PrioError, "hello %q", "world")
+	T("json/b", PrioVerbose, "line 1\nline 2")
+	handle.Unregister()
+
+	r := NewJSONReader(buf)
+	m1, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.Path != "json/a" || m1.Prio != PrioError || m1.Msg != `hello "world"` {
+		t.Errorf("wrong message %v", m1)
+	}
+	if m1.Line == 0 || m1.Func != "github.com/seehuhn/trace.TestJSONRoundTrip" {
+		t.Errorf("caller information lost: %s:%d %s", m1.File, m1.Line, m1.Func)
+	}
+	if time.Since(m1.Time) > time.Minute {
+		t.Errorf("wrong time %s", m1.Time)
+	}
+	if m1.Delivered.Before(m1.Time) || m1.Delivered.Sub(m1.Time) > time.Minute {
+		t.Errorf("wrong delivery time %s for message time %s", m1.Delivered, m1.Time)
+	}
+	m2, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.Msg != "line 1\nline 2" {
+		t.Errorf("wrong message text %q", m2.Msg)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestJSONNoDeliveryTime(t *testing.T) {
+	m := &Message{Path: "json", Msg: "x"}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("delivered")) {
+		t.Errorf("zero delivery time not omitted: %s", data)
+	}
+
+	m.Delivered = time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	data, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 Message
+	if err := json.Unmarshal(data, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if !m2.Delivered.Equal(m.Delivered) || m2.Msg != "x" {
+		t.Errorf("wrong message %s", data)
+	}
+}
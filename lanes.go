@@ -0,0 +1,75 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+// LaneListener is a variant of AsyncListener which uses a separate
+// queue and worker goroutine for every priority class, so that a flood
+// of low-priority messages cannot delay the delivery of important
+// messages, for example to an alerting sink.  The priority classes are
+// the buckets used by Stats(): one lane each for the messages of
+// priority PrioCritical and higher, PrioError, PrioInfo, PrioDebug and
+// PrioVerbose (including the lower priorities), where each lane holds
+// the priorities up to the next-higher class.  Each lane is an
+// AsyncListener, and messages are dropped if the queue of their lane
+// is full.  Since the lanes are independent, messages of different
+// classes may be delivered out of order, and the wrapped listener may
+// be called concurrently.  Use the Listen method as the listener
+// argument of Register().
+type LaneListener struct {
+	lanes [len(statsBuckets)]*AsyncListener
+}
+
+// NewLaneListener returns a new LaneListener which delivers messages
+// to 'next', using a queue which can hold up to 'capacity' messages
+// for each lane.
+func NewLaneListener(next Listener, capacity int) *LaneListener {
+	l := &LaneListener{}
+	for i := range l.lanes {
+		l.lanes[i] = NewAsyncListener(next, capacity)
+	}
+	return l
+}
+
+// Listen places a message into the queue of its lane.
+func (l *LaneListener) Listen(m *Message) {
+	l.lanes[bucket(m.Prio)].Listen(m)
+}
+
+// Lane returns the AsyncListener used for messages of priority
+// 'prio'.  This can be used to inspect the queue, or to change the
+// watermarks of the lane.
+func (l *LaneListener) Lane(prio Priority) *AsyncListener {
+	return l.lanes[bucket(prio)]
+}
+
+// Dropped returns the total number of messages which were discarded
+// because the queue of their lane was full.
+func (l *LaneListener) Dropped() uint64 {
+	var n uint64
+	for _, lane := range l.lanes {
+		n += lane.Dropped()
+	}
+	return n
+}
+
+// Close stops accepting new messages, waits until all queued messages
+// have been delivered and then stops the worker goroutines.
+func (l *LaneListener) Close() {
+	for _, lane := range l.lanes {
+		lane.Close()
+	}
+}
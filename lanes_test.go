@@ -0,0 +1,57 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLaneListener(t *testing.T) {
+	block := make(chan struct{})
+	critical := make(chan string, 1)
+	l := NewLaneListener(func(m *Message) {
+		if m.Prio >= PrioCritical {
+			critical <- m.Msg
+			return
+		}
+		<-block
+	}, 4)
+
+	// fill the verbose lane, while its worker is blocked
+	for i := 0; i < 10; i++ {
+		l.Listen(&Message{Time: time.Now(), Path: "lanes", Prio: PrioVerbose, Msg: "noise"})
+	}
+	l.Listen(&Message{Time: time.Now(), Path: "lanes", Prio: PrioCritical, Msg: "alert"})
+	select {
+	case msg := <-critical:
+		if msg != "alert" {
+			t.Errorf("wrong message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("critical message delayed by verbose messages")
+	}
+
+	if lane := l.Lane(PrioVerbose - 1); lane != l.Lane(PrioVerbose) || lane == l.Lane(PrioDebug) {
+		t.Error("wrong lane assignment")
+	}
+	if n := l.Dropped(); n < 5 {
+		t.Errorf("expected at least 5 dropped messages, got %d", n)
+	}
+	close(block)
+	l.Close()
+}
@@ -0,0 +1,166 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// DefaultSummaryInterval is the default time between two summary
+// messages reporting the number of messages suppressed by
+// MaxPerSecond().
+const DefaultSummaryInterval = 10 * time.Second
+
+// limiter restricts the rate of messages delivered to a single
+// listener.
+type limiter struct {
+	listener *listenerInfo
+
+	mutex    sync.Mutex // protects all following fields
+	rate     float64    // messages per second, 0 for unlimited
+	burst    float64
+	tokens   float64
+	last     time.Time
+	oneIn    int
+	interval time.Duration
+
+	suppressed int
+	maxPrio    Priority
+	timer      *time.Timer
+	stopped    bool
+}
+
+func (c *listenerInfo) getLimit() *limiter {
+	if c.limit == nil {
+		c.limit = &limiter{interval: DefaultSummaryInterval}
+	}
+	c.limit.listener = c
+	return c.limit
+}
+
+// MaxPerSecond limits the number of messages delivered to a listener to
+// 'rate' messages per second on average, allowing bursts of up to
+// 'burst' messages.  Messages in excess of this limit are dropped.
+// Periodically, a summary message giving the number of suppressed
+// messages is sent to the listener instead; this message uses the
+// highest priority of all suppressed messages.
+func MaxPerSecond(rate float64, burst int) Option {
+	return func(c *listenerInfo) {
+		l := c.getLimit()
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate
+		l.burst = float64(burst)
+		l.tokens = float64(burst)
+	}
+}
+
+// SampleOneIn randomly selects, on average, one in 'n' messages for
+// delivery to a listener and drops the others.  Sampled-out messages
+// are not reported in the summary messages.
+func SampleOneIn(n int) Option {
+	return func(c *listenerInfo) {
+		c.getLimit().oneIn = n
+	}
+}
+
+// SummaryInterval sets the time between two summary messages for a
+// listener registered with MaxPerSecond().  The default is
+// DefaultSummaryInterval.
+func SummaryInterval(d time.Duration) Option {
+	return func(c *listenerInfo) {
+		c.getLimit().interval = d
+	}
+}
+
+// allow decides whether a message of priority 'prio' may be delivered.
+func (l *limiter) allow(prio Priority) bool {
+	if l.oneIn > 1 && rand.IntN(l.oneIn) != 0 {
+		return false
+	}
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+
+	if l.suppressed == 0 || prio > l.maxPrio {
+		l.maxPrio = prio
+	}
+	l.suppressed++
+	if l.timer == nil && !l.stopped {
+		l.timer = time.AfterFunc(l.interval, l.summary)
+	}
+	return false
+}
+
+// summary reports the number of messages suppressed since the last
+// summary to the listener.  Like all other messages, the summary is
+// passed to the listener via deliver(), so that panics are recovered
+// and state listeners are synchronized.
+func (l *limiter) summary() {
+	l.mutex.Lock()
+	n := l.suppressed
+	prio := l.maxPrio
+	l.suppressed = 0
+	l.timer = nil
+	stopped := l.stopped
+	l.mutex.Unlock()
+	if n == 0 || stopped {
+		return
+	}
+
+	path := l.listener.path
+	if path == "" {
+		path = "trace"
+	}
+	l.listener.deliver(&Message{
+		Time: time.Now(),
+		Path: path,
+		Prio: prio,
+		Msg:  fmt.Sprintf("%d messages suppressed by rate limit", n),
+	})
+}
+
+// stop cancels pending summary messages.  This is called when the
+// listener is unregistered.
+func (l *limiter) stop() {
+	l.mutex.Lock()
+	l.stopped = true
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.mutex.Unlock()
+}
@@ -0,0 +1,126 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxPerSecond(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		count   int
+		summary string
+		sumPrio Priority
+	)
+	done := make(chan struct{})
+	listener := func(m *Message) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if m.Path == "limit" {
+			summary = m.Msg
+			sumPrio = m.Prio
+			close(done)
+			return
+		}
+		count++
+	}
+	handle := Register(listener, "limit", PrioAll,
+		MaxPerSecond(0.001, 5), SummaryInterval(10*time.Millisecond))
+	defer handle.Unregister()
+
+	for i := 0; i < 100; i++ {
+		prio := PrioDebug
+		if i == 50 {
+			prio = PrioError
+		}
+		T("limit/flood", prio, "message %d", i)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no summary message received")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if count != 5 {
+		t.Errorf("expected 5 messages within burst, got %d", count)
+	}
+	if summary != "95 messages suppressed by rate limit" {
+		t.Errorf("wrong summary %q", summary)
+	}
+	if sumPrio != PrioError {
+		t.Errorf("wrong summary priority %d", sumPrio)
+	}
+}
+
+func TestSampleOneIn(t *testing.T) {
+	count := 0
+	handle := Register(func(m *Message) {
+		count++
+	}, "sample", PrioAll, SampleOneIn(10))
+	for i := 0; i < 10000; i++ {
+		T("sample/a", PrioInfo, "hello")
+	}
+	handle.Unregister()
+	if count < 800 || count > 1200 {
+		t.Errorf("expected about 1000 sampled messages, got %d", count)
+	}
+}
+
+func TestLimiterStop(t *testing.T) {
+	called := false
+	handle := Register(func(m *Message) {
+		called = true
+	}, "stop", PrioAll, MaxPerSecond(0.001, 1), SummaryInterval(time.Millisecond))
+	T("stop", PrioInfo, "first")
+	called = false
+	T("stop", PrioInfo, "suppressed")
+	handle.Unregister()
+	time.Sleep(20 * time.Millisecond)
+	if called {
+		t.Error("summary delivered after Unregister()")
+	}
+}
+
+func TestLimiterSummaryPanic(t *testing.T) {
+	reports := make(chan string, 10)
+	h1 := Register(func(m *Message) {
+		reports <- m.Msg
+	}, "trace", PrioError)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {
+		if m.Path == "limitpanic" {
+			panic("summary")
+		}
+	}, "limitpanic", PrioAll, MaxPerSecond(0.001, 1), SummaryInterval(time.Millisecond))
+	defer h2.Unregister()
+
+	T("limitpanic/a", PrioInfo, "first")
+	T("limitpanic/a", PrioInfo, "suppressed")
+	select {
+	case msg := <-reports:
+		if msg != `listener for path "limitpanic" panicked: summary` {
+			t.Errorf("wrong report %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic in summary not recovered")
+	}
+}
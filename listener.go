@@ -17,29 +17,83 @@
 package trace
 
 import (
+	"sort"
 	"sync"
-	"time"
+	"sync/atomic"
 )
 
 // Listener is the type of functions which can be registered using the
-// Register() function.
-type Listener func(t time.Time, path string, prio Priority, msg string)
+// Register() function.  Listeners may be called concurrently from
+// different goroutines, and may themselves call T(), Register() and
+// Unregister().  The Message passed to a listener must not be
+// modified.
+type Listener func(m *Message)
 
 // ListenerHandle is the type returned by Register().  The returned
 // values can be used in Unregister() to remove previously installed
-// handlers.
+// handlers, and in Pause() and Resume() to temporarily stop the
+// delivery of messages.
 type ListenerHandle uint
 
 type listenerInfo struct {
-	path     string
-	prio     Priority
-	listener Listener
+	path      string
+	prio      Priority
+	listener  Listener
+	limit     *limiter
+	quotas    []*quota
+	caller    bool
+	private   bool // receives a copy of every message
+	group     string
+	groupOnly bool     // receives messages only via the group
+	stops     []func() // called by Unregister()
+
+	paused    atomic.Bool
+	panicking atomic.Bool
+	modifying atomic.Bool
+
+	// state is the state object of listeners registered using
+	// RegisterWith(), see state.go.
+	state    interface{}
+	inflight atomic.Int64
+	removed  atomic.Bool
+	detached atomic.Bool
+}
+
+// Option is the type of optional arguments for Register().
+type Option func(*listenerInfo)
+
+// snapshot is an immutable view of the registered listeners, used by
+// T() without any locking.  A new snapshot is installed every time a
+// listener is added or removed.
+type snapshot struct {
+	// minPrio is the lowest priority any listener is interested in.
+	minPrio Priority
+
+	// hasRoot is set if a listener is registered for the empty path.
+	// Otherwise, first has bit c set if a listener path starts with
+	// the byte c.
+	hasRoot bool
+	first   [4]uint64
+
+	// byPath maps listener paths to the listeners registered for this
+	// path, in order of registration.
+	byPath map[string][]*listenerInfo
+
+	// byGroup maps group names to the listeners in the group, see
+	// InGroup().  The map is nil if no listener belongs to a group.
+	byGroup map[string][]*listenerInfo
+
+	// caller is set if at least one listener needs caller information,
+	// see CaptureCaller().
+	caller bool
 }
 
 var (
-	listenerMutex sync.RWMutex   // protects listeners and listenerIdx
+	listenerMutex sync.Mutex     // protects listeners and listenerIdx
 	listeners                    = map[ListenerHandle]*listenerInfo{}
 	listenerIdx   ListenerHandle = 1
+
+	current atomic.Pointer[snapshot]
 )
 
 // Register adds the function 'listener' to the list of functions
@@ -56,15 +110,29 @@ var (
 // messages.  The value PrioInfo can be used to receive all messages
 // for the given path which do not require familiarity with the
 // program source code.
-func Register(listener Listener, path string, prio Priority) ListenerHandle {
-	listenerMutex.Lock()
-	handle := listenerIdx
-	listenerIdx += 1
-	listeners[handle] = &listenerInfo{
+//
+// Additional options, for example MaxPerSecond(), SampleOneIn() and
+// MaxBytesPerMinute(), can be given to restrict the rate of messages
+// delivered to the listener.
+//
+// If the listener panics, the panic is recovered and a message of
+// priority PrioError, with path "trace", is sent to the remaining
+// listeners.
+func Register(listener Listener, path string, prio Priority, opts ...Option) ListenerHandle {
+	c := &listenerInfo{
 		prio:     prio,
 		path:     path,
 		listener: listener,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	listenerMutex.Lock()
+	handle := listenerIdx
+	listenerIdx += 1
+	listeners[handle] = c
+	updateSnapshot()
 	listenerMutex.Unlock()
 	return handle
 }
@@ -74,6 +142,126 @@ func Register(listener Listener, path string, prio Priority) ListenerHandle {
 // Register()
 func (handle ListenerHandle) Unregister() {
 	listenerMutex.Lock()
+	c := listeners[handle]
+	if c != nil && c.limit != nil {
+		c.limit.stop()
+	}
+	if c != nil {
+		for _, q := range c.quotas {
+			q.stop()
+		}
+		for _, stop := range c.stops {
+			stop()
+		}
+	}
 	delete(listeners, handle)
+	updateSnapshot()
+	listenerMutex.Unlock()
+
+	if c != nil && c.state != nil {
+		c.remove()
+	}
+}
+
+// Pause temporarily stops the delivery of messages to a listener.
+// Messages sent while the listener is paused are discarded.
+func (handle ListenerHandle) Pause() {
+	handle.setPaused(true)
+}
+
+// Resume restarts the delivery of messages to a listener after a call
+// to Pause().
+func (handle ListenerHandle) Resume() {
+	handle.setPaused(false)
+}
+
+func (handle ListenerHandle) setPaused(paused bool) {
+	listenerMutex.Lock()
+	if c := listeners[handle]; c != nil {
+		c.paused.Store(paused)
+	}
 	listenerMutex.Unlock()
 }
+
+// updateSnapshot publishes a new snapshot of the registered listeners.
+// This must be called with listenerMutex held.
+func updateSnapshot() {
+	if len(listeners) == 0 {
+		current.Store(nil)
+		return
+	}
+
+	handles := make([]ListenerHandle, 0, len(listeners))
+	for handle := range listeners {
+		handles = append(handles, handle)
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+
+	s := &snapshot{
+		minPrio: PrioCritical,
+		byPath:  make(map[string][]*listenerInfo),
+	}
+	for _, handle := range handles {
+		c := listeners[handle]
+		if c.group != "" {
+			if s.byGroup == nil {
+				s.byGroup = make(map[string][]*listenerInfo)
+			}
+			s.byGroup[c.group] = append(s.byGroup[c.group], c)
+		}
+		if c.caller {
+			s.caller = true
+		}
+		if c.groupOnly {
+			continue
+		}
+		if c.prio < s.minPrio {
+			s.minPrio = c.prio
+		}
+		if c.path == "" {
+			s.hasRoot = true
+		} else {
+			b := c.path[0]
+			s.first[b/64] |= 1 << (b % 64)
+		}
+		s.byPath[c.path] = append(s.byPath[c.path], c)
+	}
+	current.Store(s)
+}
+
+// mayMatch quickly rules out most messages for which no listener is
+// registered.  If mayMatch returns true, the listeners in byPath still
+// need to be checked.
+func (s *snapshot) mayMatch(path string, prio Priority) bool {
+	if prio < s.minPrio {
+		return false
+	}
+	if s.hasRoot {
+		return true
+	}
+	if path == "" {
+		return false
+	}
+	b := path[0]
+	return s.first[b/64]&(1<<(b%64)) != 0
+}
+
+// wanted reports whether a message with the given path and priority
+// would be delivered to at least one listener.
+func wanted(path string, prio Priority) bool {
+	s := current.Load()
+	if s == nil || !s.mayMatch(path, prio) {
+		return false
+	}
+	for i := 0; i <= len(path); i++ {
+		if i > 0 && i < len(path) && path[i] != '/' {
+			continue
+		}
+		for _, c := range s.byPath[path[:i]] {
+			if prio >= c.prio {
+				return true
+			}
+		}
+	}
+	return false
+}
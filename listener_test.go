@@ -18,10 +18,9 @@ package trace
 
 import (
 	"testing"
-	"time"
 )
 
-func listener(t time.Time, path string, prio Priority, msg string) {
+func listener(m *Message) {
 	// do nothing
 }
 
@@ -35,3 +34,43 @@ func TestRegister(t *testing.T) {
 		t.Error("failed to unregister listener")
 	}
 }
+
+func TestPause(t *testing.T) {
+	count := 0
+	handle := Register(func(m *Message) {
+		count++
+	}, "pause", PrioAll)
+	T("pause", PrioInfo, "1")
+	handle.Pause()
+	T("pause", PrioInfo, "2")
+	handle.Resume()
+	T("pause", PrioInfo, "3")
+	handle.Unregister()
+	handle.Pause()
+
+	if count != 2 {
+		t.Errorf("expected 2 messages, got %d", count)
+	}
+}
+
+func TestListenerPanic(t *testing.T) {
+	var reports []string
+	faulty := Register(func(m *Message) {
+		panic("oops")
+	}, "", PrioAll)
+	good := Register(func(m *Message) {
+		if m.Path == "trace" && m.Prio == PrioError {
+			reports = append(reports, m.Msg)
+		}
+	}, "", PrioAll)
+
+	T("panic", PrioInfo, "hello")
+	T("panic", PrioInfo, "hello again")
+	faulty.Unregister()
+	good.Unregister()
+
+	if len(reports) != 2 ||
+		reports[0] != `listener for path "" panicked: oops` {
+		t.Errorf("wrong panic reports %q", reports)
+	}
+}
@@ -0,0 +1,147 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"runtime"
+	"time"
+)
+
+// Message describes a single trace message, as delivered to listeners.
+// The same Message value is passed to all listeners which receive the
+// message; listeners must not modify the message, but can make a copy
+// instead.  Listeners registered with the PrivateCopy() option receive
+// a copy which they may modify.  In strict mode (see SetStrict()),
+// modifications by other listeners are detected and do not affect
+// other listeners.
+type Message struct {
+	// Time is the time at which T() was called.
+	Time time.Time `json:"time"`
+
+	// Delivered is the time at which a listener wrote the message to
+	// its destination.  The field is zero in messages passed to
+	// listeners by T(); listeners which serialize messages, like
+	// JSONWriter or the senders in the remote package, fill it in on a
+	// copy of the message if it is not set already.  The difference
+	// to Time gives the delivery latency, including time spent in
+	// queues like the one of AsyncListener.
+	Delivered time.Time `json:"delivered"`
+
+	// Path and Prio are the message path and priority, as passed to
+	// T().
+	Path string   `json:"path"`
+	Prio Priority `json:"prio"`
+
+	// Msg is the message text, composed from the format string and
+	// arguments passed to T().
+	Msg string `json:"msg"`
+
+	// Format is the format string passed to T().  It is stored in
+	// trace files, so that tools like "trace top" and "trace diff" can
+	// identify the call site of messages without caller information.
+	Format string `json:"format,omitempty"`
+
+	// Route is the name of the listener group the message was routed
+	// to using a Route argument to T(), or the empty string.
+	Route string `json:"route,omitempty"`
+
+	// Binary holds the binary fields attached to the message using
+	// Bytes() arguments to T(), in the order of the arguments.
+	Binary []Binary `json:"binary,omitempty"`
+
+	// Replicas is the number of sources which sent the message, for
+	// identical messages from several processes which a collector has
+	// merged into one (see remote.Dedup).  The field is zero for
+	// messages which have not been merged.
+	Replicas int `json:"replicas,omitempty"`
+
+	// The following fields describe the origin of the message.  They
+	// are only filled in if at least one of the listeners receiving
+	// the message was registered with the CaptureCaller() option.
+	// PC is the program counter of the call to T(), File, Line and
+	// Func give the corresponding source location and function name,
+	// and Goroutine is the ID of the calling goroutine.
+	PC        uintptr `json:"-"`
+	File      string  `json:"file,omitempty"`
+	Line      int     `json:"line,omitempty"`
+	Func      string  `json:"func,omitempty"`
+	Goroutine uint64  `json:"goroutine,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.  The Delivered
+// field is omitted if it is zero.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	out := struct {
+		plain
+		Delivered *time.Time `json:"delivered,omitempty"`
+	}{plain: plain(m)}
+	if !m.Delivered.IsZero() {
+		out.Delivered = &m.Delivered
+	}
+	return json.Marshal(out)
+}
+
+// CaptureCaller is an option for Register() which causes the caller
+// information (PC, File, Line, Func and Goroutine) to be filled in for
+// all messages delivered to the listener.
+func CaptureCaller() Option {
+	return func(c *listenerInfo) {
+		c.caller = true
+	}
+}
+
+// PrivateCopy is an option for Register() which causes the listener to
+// receive its own copy of every message.  The listener may modify the
+// copy, for example to annotate or rewrite messages before passing
+// them on, without affecting other listeners.
+func PrivateCopy() Option {
+	return func(c *listenerInfo) {
+		c.private = true
+	}
+}
+
+// setCaller fills in the caller information for 'm', using the
+// program counter 'pc' as returned by runtime.Callers().
+func (m *Message) setCaller(pc uintptr) {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	m.PC = frame.PC
+	m.File = frame.File
+	m.Line = frame.Line
+	m.Func = frame.Function
+	m.Goroutine = goroutineID()
+}
+
+// goroutineID returns the ID of the current goroutine, as shown in
+// stack traces.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	const prefix = "goroutine "
+	if n <= len(prefix) || string(buf[:len(prefix)]) != prefix {
+		return 0
+	}
+	var id uint64
+	for _, c := range buf[len(prefix):n] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = 10*id + uint64(c-'0')
+	}
+	return id
+}
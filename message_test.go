@@ -0,0 +1,118 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCaptureCaller(t *testing.T) {
+	var plain, withCaller *Message
+	handle1 := Register(func(m *Message) {
+		plain = m
+	}, "caller", PrioAll)
+	handle2 := Register(func(m *Message) {
+		withCaller = m
+	}, "caller", PrioAll, CaptureCaller())
+	_, _, line, _ := runtime.Caller(0)
+	T("caller", PrioInfo, "hello")
+	handle1.Unregister()
+	handle2.Unregister()
+
+	if withCaller == nil || plain != withCaller {
+		t.Fatal("listeners did not receive the same message")
+	}
+	if !strings.HasSuffix(withCaller.File, "message_test.go") {
+		t.Errorf("wrong file %q", withCaller.File)
+	}
+	if withCaller.Line != line+1 {
+		t.Errorf("expected line %d, got %d", line+1, withCaller.Line)
+	}
+	if withCaller.Func != "github.com/seehuhn/trace.TestCaptureCaller" {
+		t.Errorf("wrong function %q", withCaller.Func)
+	}
+	if withCaller.Goroutine == 0 || withCaller.Goroutine != goroutineID() {
+		t.Errorf("wrong goroutine ID %d", withCaller.Goroutine)
+	}
+}
+
+func TestCaptureCallerAsync(t *testing.T) {
+	// An asynchronous listener registered before the listener which
+	// needs caller information must see the complete message, without
+	// racing with the caller capture.
+	seen := make(chan Message, 1)
+	async := NewAsyncListener(func(m *Message) {
+		seen <- *m
+	}, 1)
+	handle1 := Register(async.Listen, "caller", PrioAll)
+	handle2 := Register(func(m *Message) {}, "caller", PrioAll, CaptureCaller())
+	T("caller", PrioInfo, "hello")
+	m := <-seen
+	handle1.Unregister()
+	handle2.Unregister()
+	async.Close()
+
+	if !strings.HasSuffix(m.File, "message_test.go") || m.Line == 0 {
+		t.Errorf("missing caller information %s:%d", m.File, m.Line)
+	}
+}
+
+func TestNoCaller(t *testing.T) {
+	var seen *Message
+	handle := Register(func(m *Message) {
+		seen = m
+	}, "caller", PrioAll)
+	T("caller", PrioInfo, "hello")
+	handle.Unregister()
+
+	if seen == nil {
+		t.Fatal("failed to call listener")
+	}
+	if seen.PC != 0 || seen.File != "" || seen.Goroutine != 0 {
+		t.Errorf("unexpected caller information %s:%d", seen.File, seen.Line)
+	}
+}
+
+func TestPrivateCopy(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	var reports, seen []string
+	h1 := Register(func(m *Message) {
+		reports = append(reports, m.Msg)
+	}, "trace", PrioError)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {
+		m.Msg = strings.ToUpper(m.Msg)
+		seen = append(seen, m.Msg)
+	}, "copy", PrioInfo, PrivateCopy())
+	defer h2.Unregister()
+	h3 := Register(func(m *Message) {
+		seen = append(seen, m.Msg)
+	}, "copy", PrioInfo)
+	defer h3.Unregister()
+
+	T("copy", PrioInfo, "hello")
+	if len(seen) != 2 || seen[0] != "HELLO" || seen[1] != "hello" {
+		t.Errorf("wrong messages %q", seen)
+	}
+	if len(reports) != 0 {
+		t.Errorf("unexpected reports %q", reports)
+	}
+}
@@ -0,0 +1,132 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrMirrorOverflow is the reason reported by Mirror.Err() for mirrors
+// which have been detached because their queue was full.
+var ErrMirrorOverflow = errors.New("mirror queue overflow")
+
+// Mirror is an ephemeral, read-only copy of the trace messages for a
+// path, meant for debugging tools like live tails.  A Mirror cannot
+// affect the delivery of messages to other listeners: messages are
+// placed into a bounded queue without blocking, and if the queue is
+// full, the mirror is detached instead of slowing down the program.
+// A mirror is also detached when its context is cancelled, for example
+// when the client of an HTTP handler disconnects.
+//
+// Every mirror receives its own copy of each message (see
+// PrivateCopy()), so that tools which annotate the messages they
+// receive do not change the messages seen by other listeners.
+type Mirror struct {
+	queue chan *Message
+	done  chan struct{}
+
+	// registered is closed once handle has been set.
+	registered chan struct{}
+	handle     ListenerHandle
+
+	overflow atomic.Bool
+	once     sync.Once
+	err      error
+}
+
+// NewMirror attaches a new mirror for the messages of priority 'prio'
+// and higher for 'path' and its sub-paths, see Register().  Up to
+// 'capacity' messages are queued.  The mirror is detached when 'ctx'
+// is done, when the queue overflows, or when Close is called.
+func NewMirror(ctx context.Context, path string, prio Priority, capacity int) *Mirror {
+	if capacity < 1 {
+		capacity = 1
+	}
+	m := &Mirror{
+		queue:      make(chan *Message, capacity),
+		done:       make(chan struct{}),
+		registered: make(chan struct{}),
+	}
+	m.handle = Register(m.listen, path, prio, PrivateCopy())
+	close(m.registered)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.detach(ctx.Err())
+		case <-m.done:
+		}
+	}()
+	return m
+}
+
+func (m *Mirror) listen(msg *Message) {
+	select {
+	case m.queue <- msg:
+	default:
+		// Detaching unregisters the listener, which must not delay the
+		// delivery to other listeners.  Only the first overflow starts
+		// a goroutine for this.
+		if m.overflow.CompareAndSwap(false, true) {
+			go m.detach(ErrMirrorOverflow)
+		}
+	}
+}
+
+// detach unregisters the mirror and records the reason.  Since the
+// listener may overflow before Register() has returned, detach waits
+// until the handle is known.
+func (m *Mirror) detach(err error) {
+	<-m.registered
+	m.once.Do(func() {
+		m.err = err
+		m.handle.Unregister()
+		close(m.done)
+	})
+}
+
+// Messages returns the channel from which the mirrored messages can be
+// read.  The channel is not closed when the mirror is detached; use
+// Done() to detect this.
+func (m *Mirror) Messages() <-chan *Message {
+	return m.queue
+}
+
+// Done returns a channel which is closed when the mirror has been
+// detached.  Messages queued before this time can still be read.
+func (m *Mirror) Done() <-chan struct{} {
+	return m.done
+}
+
+// Err returns the reason why the mirror has been detached: the error
+// of the context, ErrMirrorOverflow, or nil if Close has been called.
+// Before the mirror is detached, Err returns nil.
+func (m *Mirror) Err() error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
+
+// Close detaches the mirror.
+func (m *Mirror) Close() {
+	m.detach(nil)
+}
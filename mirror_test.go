@@ -0,0 +1,122 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	m := NewMirror(context.Background(), "mirror", PrioInfo, 10)
+	T("mirror/a", PrioInfo, "hello")
+	T("mirror/a", PrioDebug, "ignored")
+	select {
+	case msg := <-m.Messages():
+		if msg.Msg != "hello" {
+			t.Errorf("wrong message %q", msg.Msg)
+		}
+	default:
+		t.Fatal("no message received")
+	}
+	m.Close()
+	<-m.Done()
+	if m.Err() != nil {
+		t.Errorf("unexpected error %v", m.Err())
+	}
+	T("mirror/a", PrioInfo, "after close")
+	if len(m.Messages()) != 0 {
+		t.Error("message received after Close")
+	}
+}
+
+func TestMirrorCopy(t *testing.T) {
+	var seen *Message
+	h := Register(func(msg *Message) { seen = msg }, "mirror", PrioInfo)
+	defer h.Unregister()
+	m := NewMirror(context.Background(), "mirror", PrioInfo, 10)
+	defer m.Close()
+
+	T("mirror/copy", PrioInfo, "original")
+	msg := <-m.Messages()
+	msg.Msg = "annotated"
+	if seen == nil || seen.Msg != "original" {
+		t.Errorf("mirror changed the message of another listener: %v", seen)
+	}
+}
+
+func TestMirrorDetach(t *testing.T) {
+	m := NewMirror(context.Background(), "mirror", PrioInfo, 2)
+	for i := 0; i < 3; i++ {
+		T("mirror", PrioInfo, "message %d", i)
+	}
+	select {
+	case <-m.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror not detached after overflow")
+	}
+	if m.Err() != ErrMirrorOverflow {
+		t.Errorf("wrong error %v", m.Err())
+	}
+	if len(m.Messages()) != 2 {
+		t.Errorf("expected 2 queued messages, got %d", len(m.Messages()))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m = NewMirror(ctx, "mirror", PrioInfo, 2)
+	cancel()
+	select {
+	case <-m.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirror not detached after cancel")
+	}
+	if m.Err() != context.Canceled {
+		t.Errorf("wrong error %v", m.Err())
+	}
+}
+
+func TestMirrorOverflowWhileRegistering(t *testing.T) {
+	// Messages sent concurrently with NewMirror can overflow the queue
+	// before the handle is known; the mirror must still be detached.
+	stop := make(chan struct{})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				T("mirror/race", PrioInfo, "flood")
+			}
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		m := NewMirror(context.Background(), "mirror/race", PrioInfo, 1)
+		select {
+		case <-m.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("mirror not detached after overflow")
+		}
+		if m.Err() != ErrMirrorOverflow {
+			t.Errorf("wrong error %v", m.Err())
+		}
+	}
+	close(stop)
+	<-sent
+}
@@ -0,0 +1,278 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package otlp exports trace messages to an OpenTelemetry collector.
+//
+// Messages are converted into OTLP log records and sent to the
+// collector in batches, using the JSON encoding of the OTLP/HTTP
+// protocol.  The message path, the priority and, if the listener is
+// registered with the trace.CaptureCaller() option, the source location
+// of the call to trace.T() are attached to each record as attributes.
+// Fields of the form key=value in the message text are attached as
+// attributes, too; common field names like "method" or "statement"
+// are renamed according to the OpenTelemetry semantic conventions,
+// for example to "http.method" and "db.statement".  Example:
+//
+//	exp := otlp.NewExporter("http://localhost:4318/v1/logs", "myserver")
+//	handle := trace.Register(exp.Listen, "", trace.PrioInfo,
+//		trace.CaptureCaller())
+//	// ... code which calls trace.T()
+//	handle.Unregister()
+//	exp.Close()
+//
+// The trace package has no notion of spans, so only log records are
+// exported.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// Default values for the batching parameters of an Exporter.
+const (
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// Exporter collects trace messages and sends them to an OpenTelemetry
+// collector.  Use the Listen method as the listener argument of
+// trace.Register().
+type Exporter struct {
+	endpoint  string
+	client    *http.Client
+	resource  resource
+	batchSize int
+
+	fieldNames map[string]string
+
+	mutex   sync.Mutex // protects pending, closed and lastErr
+	pending []logRecord
+	closed  bool
+	lastErr error
+
+	kick   chan struct{}
+	stop   chan struct{}
+	worker *trace.Supervisor
+}
+
+// NewExporter returns a new Exporter which sends log records to the
+// OTLP/HTTP endpoint 'endpoint', for example
+// "http://localhost:4318/v1/logs".  The value 'serviceName' is used
+// as the "service.name" resource attribute.  Records are sent whenever
+// DefaultBatchSize records have accumulated, and at least every
+// DefaultFlushInterval.
+func NewExporter(endpoint, serviceName string) *Exporter {
+	e := &Exporter{
+		endpoint:   endpoint,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		batchSize:  DefaultBatchSize,
+		fieldNames: DefaultFieldNames,
+		resource: resource{
+			Attributes: []keyValue{stringAttr("service.name", serviceName)},
+		},
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+	e.worker = trace.Supervise("OTLP exporter", func() error {
+		return e.run(DefaultFlushInterval)
+	})
+	return e
+}
+
+// Listen converts a trace message into an OTLP log record and queues
+// the record for sending.  The record's time is the time of the call
+// to trace.T(), and its observed time is the time Listen was called.
+// If caller information is available, the source location of the call
+// to trace.T() is recorded in the "code.filepath", "code.lineno" and
+// "code.function" attributes.
+func (e *Exporter) Listen(m *trace.Message) {
+	sevNum, sevText := severity(m.Prio)
+	msg := m.Msg
+	rec := logRecord{
+		TimeUnixNano:         strconv.FormatInt(m.Time.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       sevNum,
+		SeverityText:         sevText,
+		Body:                 anyValue{StringValue: &msg},
+		Attributes: []keyValue{
+			stringAttr("trace.path", m.Path),
+			intAttr("trace.priority", int64(m.Prio)),
+		},
+	}
+	if m.File != "" {
+		rec.Attributes = append(rec.Attributes,
+			stringAttr("code.filepath", m.File),
+			intAttr("code.lineno", int64(m.Line)),
+			stringAttr("code.function", m.Func),
+			intAttr("trace.goroutine", int64(m.Goroutine)))
+	}
+	rec.Attributes = append(rec.Attributes, e.fieldAttrs(m.Msg)...)
+
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return
+	}
+	e.pending = append(e.pending, rec)
+	full := len(e.pending) >= e.batchSize
+	e.mutex.Unlock()
+
+	if full {
+		select {
+		case e.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run periodically sends the queued records, until Close is called.
+func (e *Exporter) run(interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.kick:
+		case <-e.stop:
+			return nil
+		}
+		if err := e.Flush(); err != nil {
+			trace.T("trace/otlp", trace.PrioError,
+				"cannot export log records: %s", err)
+		}
+	}
+}
+
+// Flush sends all queued records to the collector.  If the records
+// cannot be delivered, they are discarded and an error is returned.
+func (e *Exporter) Flush() error {
+	e.mutex.Lock()
+	records := e.pending
+	e.pending = nil
+	e.mutex.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := e.export(records)
+	e.mutex.Lock()
+	e.lastErr = err
+	e.mutex.Unlock()
+	return err
+}
+
+func (e *Exporter) export(records []logRecord) error {
+	req := exportLogsRequest{
+		ResourceLogs: []resourceLogs{
+			{
+				Resource: e.currentResource(),
+				ScopeLogs: []scopeLogs{
+					{
+						Scope:      scope{Name: "github.com/seehuhn/trace"},
+						LogRecords: records,
+					},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%d records rejected by collector: %s",
+			len(records), resp.Status)
+	}
+	return nil
+}
+
+// Open is a no-op, needed for the Exporter to implement trace.Sink.
+func (e *Exporter) Open() error {
+	return nil
+}
+
+// HealthCheck returns the error of the most recent export attempt, or
+// nil if the attempt was successful.
+func (e *Exporter) HealthCheck() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.lastErr
+}
+
+// currentResource returns the resource description, including the
+// clock information from trace.Clock() as the "trace.clock.ntp_server"
+// and "trace.clock.ntp_offset_ns" attributes, if a measurement has been
+// made.
+func (e *Exporter) currentResource() resource {
+	clock := trace.Clock()
+	res := resource{
+		Attributes: append([]keyValue{}, e.resource.Attributes...),
+	}
+	if clock.NTPServer != "" {
+		res.Attributes = append(res.Attributes,
+			stringAttr("trace.clock.ntp_server", clock.NTPServer),
+			intAttr("trace.clock.ntp_offset_ns", int64(clock.NTPOffset)))
+	}
+	return res
+}
+
+// Close stops the background goroutine and sends all remaining records
+// to the collector.  Messages received after Close has been called are
+// discarded.
+func (e *Exporter) Close() error {
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.mutex.Unlock()
+
+	close(e.stop)
+	e.worker.Wait()
+	return e.Flush()
+}
+
+// severity maps a trace priority to the corresponding OTLP severity
+// number and text.
+func severity(prio trace.Priority) (int, string) {
+	switch {
+	case prio >= trace.PrioCritical:
+		return 21, "FATAL"
+	case prio >= trace.PrioError:
+		return 17, "ERROR"
+	case prio >= trace.PrioInfo:
+		return 9, "INFO"
+	case prio >= trace.PrioDebug:
+		return 5, "DEBUG"
+	default:
+		return 1, "TRACE"
+	}
+}
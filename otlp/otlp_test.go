@@ -0,0 +1,141 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestExporter(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		requests []exportLogsRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req := exportLogsRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			mutex.Lock()
+			requests = append(requests, req)
+			mutex.Unlock()
+		}))
+	defer server.Close()
+
+	exp := NewExporter(server.URL+"/v1/logs", "test-service")
+	handle := trace.Register(exp.Listen, "otlp", trace.PrioAll,
+		trace.CaptureCaller())
+	trace.T("otlp/test", trace.PrioError, "hello %s", "collector")
+	handle.Unregister()
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	rl := requests[0].ResourceLogs
+	if len(rl) != 1 || len(rl[0].ScopeLogs) != 1 ||
+		len(rl[0].ScopeLogs[0].LogRecords) != 1 {
+		t.Fatalf("malformed request %v", requests[0])
+	}
+	attr := rl[0].Resource.Attributes
+	if len(attr) < 1 || *attr[0].Value.StringValue != "test-service" {
+		t.Errorf("wrong resource attributes %v", attr)
+	}
+
+	rec := rl[0].ScopeLogs[0].LogRecords[0]
+	if *rec.Body.StringValue != "hello collector" {
+		t.Errorf("wrong body %q", *rec.Body.StringValue)
+	}
+	if rec.SeverityNumber != 17 || rec.SeverityText != "ERROR" {
+		t.Errorf("wrong severity %d/%s", rec.SeverityNumber, rec.SeverityText)
+	}
+	values := map[string]string{}
+	for _, kv := range rec.Attributes {
+		if kv.Value.StringValue != nil {
+			values[kv.Key] = *kv.Value.StringValue
+		} else if kv.Value.IntValue != nil {
+			values[kv.Key] = *kv.Value.IntValue
+		}
+	}
+	if values["trace.path"] != "otlp/test" {
+		t.Errorf("wrong path attribute %q", values["trace.path"])
+	}
+	if values["trace.priority"] != "1000" {
+		t.Errorf("wrong priority attribute %q", values["trace.priority"])
+	}
+	if !strings.HasSuffix(values["code.filepath"], "otlp_test.go") {
+		t.Errorf("wrong file attribute %q", values["code.filepath"])
+	}
+	if values["code.function"] != "github.com/seehuhn/trace/otlp.TestExporter" {
+		t.Errorf("wrong function attribute %q", values["code.function"])
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	testData := []struct {
+		prio trace.Priority
+		num  int
+	}{
+		{trace.PrioCritical, 21},
+		{trace.PrioError, 17},
+		{trace.PrioInfo, 9},
+		{trace.PrioDebug, 5},
+		{trace.PrioVerbose, 1},
+		{trace.PrioAll, 1},
+	}
+	for _, test := range testData {
+		if num, _ := severity(test.prio); num != test.num {
+			t.Errorf("priority %d: expected severity %d, got %d",
+				test.prio, test.num, num)
+		}
+	}
+}
+
+var _ trace.Sink = (*Exporter)(nil)
+
+func TestExporterHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	exp := NewExporter(server.URL, "test-service")
+	if err := exp.HealthCheck(); err != nil {
+		t.Errorf("unexpected health problem %v", err)
+	}
+	exp.Listen(&trace.Message{Msg: "hello"})
+	if err := exp.Flush(); err == nil {
+		t.Error("missing export error")
+	}
+	if err := exp.HealthCheck(); err == nil {
+		t.Error("missing health problem")
+	}
+	exp.Close()
+}
@@ -0,0 +1,102 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package otlp
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/seehuhn/trace"
+)
+
+// DefaultFieldNames maps common message field names to the attribute
+// names of the OpenTelemetry semantic conventions.  Exporters use this
+// mapping unless SetFieldNames is called.
+var DefaultFieldNames = map[string]string{
+	"method":      "http.method",
+	"http_method": "http.method",
+	"status":      "http.status_code",
+	"status_code": "http.status_code",
+	"url":         "http.url",
+	"route":       "http.route",
+	"user_agent":  "http.user_agent",
+	"statement":   "db.statement",
+	"query":       "db.statement",
+	"sql":         "db.statement",
+	"db":          "db.name",
+	"database":    "db.name",
+	"peer":        "net.peer.name",
+	"host":        "net.peer.name",
+	"port":        "net.peer.port",
+	"error":       "exception.message",
+}
+
+// SetFieldNames changes the mapping from message fields to attribute
+// names.  The fields of each message, as returned by
+// trace.ParseFields(), are attached to the log record as attributes,
+// using the name given in 'names' or the field name itself for fields
+// not listed in the map.  If 'names' is nil, fields are not exported.
+// SetFieldNames must be called before the Exporter receives messages.
+func (e *Exporter) SetFieldNames(names map[string]string) {
+	e.fieldNames = names
+}
+
+// fieldAttrs returns the attributes for the fields in the message text
+// 'msg', in order of attribute name.  Integer values are exported as
+// integer attributes.  If several fields map to the same attribute
+// name, for example "method" and "http_method", the field which occurs
+// first in the text is used.  For keys which occur more than once, the
+// last value is used, as for trace.ParseFields().
+func (e *Exporter) fieldAttrs(msg string) []keyValue {
+	if e.fieldNames == nil {
+		return nil
+	}
+	list := trace.ParseFieldList(msg)
+	if len(list) == 0 {
+		return nil
+	}
+	fields := map[string]string{}
+	for _, f := range list {
+		fields[f.Key] = f.Value
+	}
+	values := map[string]string{}
+	for _, f := range list {
+		name, ok := e.fieldNames[f.Key]
+		if !ok {
+			name = f.Key
+		}
+		if _, seen := values[name]; !seen {
+			values[name] = fields[f.Key]
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]keyValue, 0, len(names))
+	for _, name := range names {
+		value := values[name]
+		if x, err := strconv.ParseInt(value, 10, 64); err == nil {
+			res = append(res, intAttr(name, x))
+		} else {
+			res = append(res, stringAttr(name, value))
+		}
+	}
+	return res
+}
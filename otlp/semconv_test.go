@@ -0,0 +1,72 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package otlp
+
+import (
+	"testing"
+)
+
+func TestFieldAttrs(t *testing.T) {
+	e := &Exporter{fieldNames: DefaultFieldNames}
+	attrs := e.fieldAttrs(`request done method=GET status=200 peer=db1 custom="a b"`)
+	expected := []struct {
+		key, str, num string
+	}{
+		{"custom", "a b", ""},
+		{"http.method", "GET", ""},
+		{"http.status_code", "", "200"},
+		{"net.peer.name", "db1", ""},
+	}
+	if len(attrs) != len(expected) {
+		t.Fatalf("wrong attributes %v", attrs)
+	}
+	for i, want := range expected {
+		kv := attrs[i]
+		if kv.Key != want.key {
+			t.Errorf("%d: expected key %q, got %q", i, want.key, kv.Key)
+		}
+		if want.num != "" {
+			if kv.Value.IntValue == nil || *kv.Value.IntValue != want.num {
+				t.Errorf("%s: wrong value %v", kv.Key, kv.Value)
+			}
+		} else if kv.Value.StringValue == nil || *kv.Value.StringValue != want.str {
+			t.Errorf("%s: wrong value %v", kv.Key, kv.Value)
+		}
+	}
+
+	e.SetFieldNames(nil)
+	if attrs := e.fieldAttrs("method=GET"); attrs != nil {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestFieldAttrsCollision(t *testing.T) {
+	e := &Exporter{fieldNames: DefaultFieldNames}
+	for _, test := range []struct{ msg, method string }{
+		{"http_method=POST method=GET", "POST"},
+		{"method=GET http_method=POST", "GET"},
+		{"method=GET http_method=POST method=PUT", "PUT"},
+	} {
+		for i := 0; i < 10; i++ {
+			attrs := e.fieldAttrs(test.msg)
+			if len(attrs) != 1 || *attrs[0].Value.StringValue != test.method {
+				t.Errorf("%q: wrong attributes %v", test.msg, attrs)
+				break
+			}
+		}
+	}
+}
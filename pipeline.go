@@ -0,0 +1,214 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// Format selects the output format of a pipeline, see
+// PipelineBuilder.Format().
+type Format int
+
+// These are the output formats supported by PipelineBuilder.
+const (
+	// Text is the human readable format written by Console.
+	Text Format = iota
+
+	// JSON is the trace file format written by JSONWriter.
+	JSON
+
+	// ClassicSyslog is the traditional syslog line format written by
+	// ClassicWriter, with the program name as the tag.
+	ClassicSyslog
+)
+
+// PipelineBuilder composes a listener from filters, rate limits and
+// outputs.  A PipelineBuilder is obtained from Pipeline(), configured
+// using the chainable methods, and finally installed using Install().
+// Filters, sampling and rate limits are applied in the order in which
+// they are added to the pipeline.  For example, in
+//
+//	trace.Pipeline().
+//		Filter(func(m *trace.Message) bool { return m.Prio >= trace.PrioError }).
+//		Sample(10).
+//		Format(trace.JSON).
+//		To(file, os.Stderr).
+//		Install("mypkg", trace.PrioDebug)
+//
+// one in ten of the error messages is written, while with the first
+// two stages swapped, the sample would be taken from all messages
+// before the filter is applied.
+type PipelineBuilder struct {
+	stages    []stage
+	opts      []Option
+	format    Format
+	writers   []io.Writer
+	listeners []Listener
+}
+
+// stage is one step of a pipeline.  A stage returns a listener which
+// passes the selected messages on to 'next', and optionally a function
+// which is called when the pipeline is unregistered.  Messages
+// generated by the stage itself use the path 'path'.
+type stage func(next Listener, path string) (Listener, func())
+
+// Pipeline starts the construction of a new pipeline.  By default, a
+// pipeline writes messages to os.Stderr in the Text format.
+func Pipeline() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// Filter adds a filter to the pipeline.  Only messages for which
+// 'keep' returns true are passed on.  If Filter is called several
+// times, messages must pass all filters.
+func (p *PipelineBuilder) Filter(keep func(m *Message) bool) *PipelineBuilder {
+	p.stages = append(p.stages, func(next Listener, _ string) (Listener, func()) {
+		return func(m *Message) {
+			if keep(m) {
+				next(m)
+			}
+		}, nil
+	})
+	return p
+}
+
+// Match adds a filter which only passes messages whose text contains
+// 'substr'.
+func (p *PipelineBuilder) Match(substr string) *PipelineBuilder {
+	return p.Filter(func(m *Message) bool {
+		return strings.Contains(m.Msg, substr)
+	})
+}
+
+// Sample randomly selects one in 'n' messages, see SampleOneIn().
+func (p *PipelineBuilder) Sample(n int) *PipelineBuilder {
+	p.stages = append(p.stages, func(next Listener, _ string) (Listener, func()) {
+		l := &limiter{oneIn: n}
+		return func(m *Message) {
+			if !l.allow(m.Prio) {
+				count(m.Path, m.Prio, outSuppressed)
+				return
+			}
+			next(m)
+		}, nil
+	})
+	return p
+}
+
+// RateLimit limits the rate of messages, see MaxPerSecond().  The
+// summary messages are passed on to the following stages.
+func (p *PipelineBuilder) RateLimit(rate float64, burst int) *PipelineBuilder {
+	p.stages = append(p.stages, func(next Listener, path string) (Listener, func()) {
+		c := &listenerInfo{path: path, listener: next}
+		MaxPerSecond(rate, burst)(c)
+		l := c.limit
+		return func(m *Message) {
+			if !l.allow(m.Prio) {
+				count(m.Path, m.Prio, outSuppressed)
+				return
+			}
+			next(m)
+		}, l.stop
+	})
+	return p
+}
+
+// WithCaller fills in the caller information of the messages, see
+// CaptureCaller().
+func (p *PipelineBuilder) WithCaller() *PipelineBuilder {
+	p.opts = append(p.opts, CaptureCaller())
+	return p
+}
+
+// Format sets the format used for the writers given to To().
+func (p *PipelineBuilder) Format(format Format) *PipelineBuilder {
+	p.format = format
+	return p
+}
+
+// To adds writers as outputs of the pipeline.  Messages are written to
+// every writer, in the format chosen by Format().
+func (p *PipelineBuilder) To(writers ...io.Writer) *PipelineBuilder {
+	p.writers = append(p.writers, writers...)
+	return p
+}
+
+// ToListener adds listeners as outputs of the pipeline.
+func (p *PipelineBuilder) ToListener(listeners ...Listener) *PipelineBuilder {
+	p.listeners = append(p.listeners, listeners...)
+	return p
+}
+
+// Listener returns the listener implementing the stages and outputs
+// of the pipeline.  Summary messages of rate limits use the path
+// "trace"; use Install() to register the pipeline with the summaries
+// using the path of the pipeline.
+func (p *PipelineBuilder) Listener() Listener {
+	listener, _ := p.compose("")
+	return listener
+}
+
+// compose builds the listener for the pipeline, and returns it
+// together with the functions to call when the listener is
+// unregistered.
+func (p *PipelineBuilder) compose(path string) (Listener, []func()) {
+	outputs := append([]Listener{}, p.listeners...)
+	for _, w := range p.writers {
+		switch p.format {
+		case JSON:
+			outputs = append(outputs, NewJSONWriter(w).Listen)
+		case ClassicSyslog:
+			outputs = append(outputs, NewClassicWriter(w, "").Listen)
+		default:
+			outputs = append(outputs, NewConsole(w).Listen)
+		}
+	}
+	if len(outputs) == 0 {
+		outputs = append(outputs, NewConsole(os.Stderr).Listen)
+	}
+
+	listener := func(m *Message) {
+		for _, out := range outputs {
+			out(m)
+		}
+	}
+	var stops []func()
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		var stop func()
+		listener, stop = p.stages[i](listener, path)
+		if stop != nil {
+			stops = append(stops, stop)
+		}
+	}
+	return listener, stops
+}
+
+// Install registers the pipeline as a listener for the given path and
+// priority, see Register().
+func (p *PipelineBuilder) Install(path string, prio Priority) ListenerHandle {
+	listener, stops := p.compose(path)
+	opts := append([]Option{}, p.opts...)
+	if stops != nil {
+		opts = append(opts, func(c *listenerInfo) {
+			c.stops = append(c.stops, stops...)
+		})
+	}
+	return Register(listener, path, prio, opts...)
+}
@@ -0,0 +1,96 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	jsonBuf := &bytes.Buffer{}
+	textBuf := &bytes.Buffer{}
+	var seen []string
+	handle := Pipeline().
+		Filter(func(m *Message) bool { return m.Path != "pipeline/skip" }).
+		Match("keep").
+		Format(JSON).
+		To(jsonBuf).
+		ToListener(func(m *Message) { seen = append(seen, m.Msg) }).
+		Install("pipeline", PrioInfo)
+	text := Pipeline().Format(Text).To(textBuf).Install("pipeline", PrioError)
+
+	T("pipeline/a", PrioInfo, "keep this")
+	T("pipeline/a", PrioInfo, "drop this")
+	T("pipeline/skip", PrioError, "keep, but skip")
+	T("pipeline/a", PrioDebug, "keep, but debug")
+	handle.Unregister()
+	text.Unregister()
+
+	if strings.Join(seen, ",") != "keep this" {
+		t.Errorf("wrong messages %q", seen)
+	}
+	m, err := NewJSONReader(jsonBuf).Read()
+	if err != nil || m.Msg != "keep this" {
+		t.Errorf("wrong JSON output %q", jsonBuf.String())
+	}
+	if !strings.Contains(textBuf.String(), "keep, but skip") ||
+		strings.Contains(textBuf.String(), "keep this") {
+		t.Errorf("wrong text output %q", textBuf.String())
+	}
+}
+
+func TestPipelineSample(t *testing.T) {
+	count := 0
+	handle := Pipeline().Sample(10).
+		ToListener(func(m *Message) { count++ }).
+		Install("pipeline", PrioInfo)
+	for i := 0; i < 10000; i++ {
+		T("pipeline", PrioInfo, "hello")
+	}
+	handle.Unregister()
+	if count < 800 || count > 1200 {
+		t.Errorf("expected about 1000 sampled messages, got %d", count)
+	}
+}
+
+func TestPipelineOrder(t *testing.T) {
+	var filterFirst, limitFirst []string
+	h1 := Pipeline().
+		Match("keep").
+		RateLimit(0.001, 2).
+		ToListener(func(m *Message) { filterFirst = append(filterFirst, m.Msg) }).
+		Install("pipeorder", PrioInfo)
+	h2 := Pipeline().
+		RateLimit(0.001, 2).
+		Match("keep").
+		ToListener(func(m *Message) { limitFirst = append(limitFirst, m.Msg) }).
+		Install("pipeorder", PrioInfo)
+	for _, msg := range []string{"drop 1", "drop 2", "keep 1", "keep 2", "keep 3"} {
+		T("pipeorder", PrioInfo, msg)
+	}
+	h1.Unregister()
+	h2.Unregister()
+
+	if got := strings.Join(filterFirst, ","); got != "keep 1,keep 2" {
+		t.Errorf("filter before rate limit: wrong messages %q", got)
+	}
+	if len(limitFirst) != 0 {
+		t.Errorf("rate limit before filter: wrong messages %q", limitFirst)
+	}
+}
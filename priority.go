@@ -0,0 +1,51 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import "math"
+
+// PrioMax is the highest value a Priority can take.  The lowest value
+// is PrioAll.
+const PrioMax Priority = math.MaxInt32
+
+// Add returns the priority 'prio' raised by 'delta', or lowered if
+// 'delta' is negative.  In contrast to plain arithmetic on Priority
+// values, which wraps around at the bounds of the type, the result is
+// clamped to the range from PrioAll to PrioMax.  For example,
+// PrioAll.Add(-1) is PrioAll, where PrioAll-1 would be PrioMax.
+func (prio Priority) Add(delta int) Priority {
+	return priorityOf(int64(prio) + max(min(int64(delta), 1<<32), -1<<32))
+}
+
+// Clamp returns 'prio', limited to the range from 'lo' to 'hi'.  The
+// value 'lo' must not be larger than 'hi'.
+func (prio Priority) Clamp(lo, hi Priority) Priority {
+	return max(lo, min(prio, hi))
+}
+
+// priorityOf converts 'x' into a Priority, clamping values outside the
+// range of the type.
+func priorityOf(x int64) Priority {
+	return Priority(max(min(x, math.MaxInt32), math.MinInt32))
+}
+
+// scalePriority returns x*factor as a Priority, clamping values
+// outside the range of the type.  The magnitude of 'factor' must be
+// less than 2^31.
+func scalePriority(x int64, factor int64) Priority {
+	return priorityOf(max(min(x, 1<<32), -1<<32) * factor)
+}
@@ -0,0 +1,90 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriorityAdd(t *testing.T) {
+	testData := []struct {
+		prio  Priority
+		delta int
+		res   Priority
+	}{
+		{PrioInfo, 500, 500},
+		{PrioError, -1000, PrioInfo},
+		{PrioAll, -1, PrioAll},
+		{PrioAll, 1, math.MinInt32 + 1},
+		{PrioAll, math.MinInt, PrioAll},
+		{PrioAll, math.MaxInt, PrioMax},
+		{PrioMax, 1, PrioMax},
+		{PrioMax, -1, math.MaxInt32 - 1},
+		{PrioMax, math.MaxInt, PrioMax},
+		{PrioMax, math.MinInt, PrioAll},
+		{PrioError, math.MaxInt32, PrioMax},
+		{PrioDebug, math.MinInt32, PrioAll},
+	}
+	for _, test := range testData {
+		if res := test.prio.Add(test.delta); res != test.res {
+			t.Errorf("%d.Add(%d): expected %d, got %d",
+				test.prio, test.delta, test.res, res)
+		}
+	}
+}
+
+func TestPriorityClamp(t *testing.T) {
+	testData := []struct {
+		prio, lo, hi Priority
+		res          Priority
+	}{
+		{PrioInfo, PrioDebug, PrioError, PrioInfo},
+		{PrioCritical, PrioDebug, PrioError, PrioError},
+		{PrioAll, PrioDebug, PrioError, PrioDebug},
+		{PrioMax, PrioAll, PrioMax, PrioMax},
+		{PrioAll, PrioAll, PrioMax, PrioAll},
+		{PrioInfo, PrioInfo, PrioInfo, PrioInfo},
+	}
+	for _, test := range testData {
+		if res := test.prio.Clamp(test.lo, test.hi); res != test.res {
+			t.Errorf("%d.Clamp(%d, %d): expected %d, got %d",
+				test.prio, test.lo, test.hi, test.res, res)
+		}
+	}
+}
+
+func TestScalePriority(t *testing.T) {
+	testData := []struct {
+		x, factor int64
+		res       Priority
+	}{
+		{4, 250, PrioError},
+		{-8, 250, PrioVerbose},
+		{math.MaxInt64, 250, PrioMax},
+		{math.MinInt64, 250, PrioAll},
+		{math.MinInt32, 1, PrioAll},
+		{math.MaxInt32, -1, -math.MaxInt32},
+		{math.MinInt32, -1, PrioMax},
+	}
+	for _, test := range testData {
+		if res := scalePriority(test.x, test.factor); res != test.res {
+			t.Errorf("scalePriority(%d, %d): expected %d, got %d",
+				test.x, test.factor, test.res, res)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// profiling indicates whether the cost of T() calls is measured, see
+// SetProfiling().
+var profiling atomic.Bool
+
+var (
+	profileMutex sync.Mutex // protects profileByPC
+	profileByPC  = map[uintptr]*siteProfile{}
+)
+
+type siteProfile struct {
+	path     string
+	calls    int64
+	format   time.Duration
+	dispatch time.Duration
+}
+
+// SiteProfile gives the time spent in calls to T() for one call site.
+type SiteProfile struct {
+	// Site is the location of the call, in the form "file:line", and
+	// Path is the message path used by the most recent call.
+	Site string `json:"site"`
+	Path string `json:"path"`
+
+	// Calls is the number of calls which produced a message.  Format
+	// is the total time spent composing the message text, including
+	// the evaluation of Valuer arguments, and Dispatch is the total
+	// time spent in T(), including formatting and the delivery to all
+	// listeners.
+	Calls    int64         `json:"calls"`
+	Format   time.Duration `json:"format_ns"`
+	Dispatch time.Duration `json:"dispatch_ns"`
+}
+
+// SetProfiling enables or disables the profiler.  While the profiler
+// is enabled, the time spent formatting and delivering messages is
+// recorded for every call site, and can be inspected using Profile().
+// Calls to T() which do not produce a message, because no listener is
+// interested, are not recorded.  Profiling makes every call producing
+// a message more expensive, since the call site needs to be
+// determined.
+func SetProfiling(enabled bool) {
+	profiling.Store(enabled)
+}
+
+// recordProfile adds one call to the profile of the call site 'pc'.
+func recordProfile(pc uintptr, path string, format, dispatch time.Duration) {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	p := profileByPC[pc]
+	if p == nil {
+		p = &siteProfile{}
+		profileByPC[pc] = p
+	}
+	p.path = path
+	p.calls++
+	p.format += format
+	p.dispatch += dispatch
+}
+
+// Profile returns the profile data for the 'n' call sites which spent
+// the most time in T(), in order of decreasing time.  If 'n' is
+// negative, all call sites are returned.
+func Profile(n int) []SiteProfile {
+	profileMutex.Lock()
+	res := make([]SiteProfile, 0, len(profileByPC))
+	pcs := make([]uintptr, 0, len(profileByPC))
+	for pc, p := range profileByPC {
+		res = append(res, SiteProfile{
+			Path:     p.path,
+			Calls:    p.calls,
+			Format:   p.format,
+			Dispatch: p.dispatch,
+		})
+		pcs = append(pcs, pc)
+	}
+	profileMutex.Unlock()
+
+	for i, pc := range pcs {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if frame.File != "" {
+			res[i].Site = frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := &res[i], &res[j]
+		if a.Dispatch != b.Dispatch {
+			return a.Dispatch > b.Dispatch
+		}
+		return a.Site < b.Site
+	})
+	if n >= 0 && n < len(res) {
+		res = res[:n]
+	}
+	return res
+}
+
+// ResetProfile discards all profile data collected so far.
+func ResetProfile() {
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	profileByPC = map[uintptr]*siteProfile{}
+}
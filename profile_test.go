@@ -0,0 +1,61 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	ResetProfile()
+	SetProfiling(true)
+	defer SetProfiling(false)
+	defer ResetProfile()
+
+	handle := Register(func(m *Message) {}, "profile", PrioInfo)
+	defer handle.Unregister()
+	slow := Valuer(func() interface{} {
+		time.Sleep(10 * time.Millisecond)
+		return "slow"
+	})
+	for i := 0; i < 3; i++ {
+		T("profile/fast", PrioInfo, "fast %d", i)
+	}
+	T("profile/slow", PrioInfo, "%s", slow)
+	T("profile/ignored", PrioDebug, "%s", slow)
+
+	prof := Profile(-1)
+	if len(prof) != 2 {
+		t.Fatalf("expected 2 call sites, got %v", prof)
+	}
+	top := prof[0]
+	if top.Path != "profile/slow" || top.Calls != 1 ||
+		top.Format < 10*time.Millisecond || top.Dispatch < top.Format {
+		t.Errorf("wrong profile %v", top)
+	}
+	if !strings.HasSuffix(top.Site, "profile_test.go:40") {
+		t.Errorf("wrong call site %q", top.Site)
+	}
+	if prof[1].Path != "profile/fast" || prof[1].Calls != 3 {
+		t.Errorf("wrong profile %v", prof[1])
+	}
+	if len(Profile(1)) != 1 {
+		t.Error("Profile(1) returned wrong number of call sites")
+	}
+}
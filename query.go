@@ -0,0 +1,296 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a filter for trace messages, in the syntax described for
+// ParseQuery().  Queries are used by the introspection tools, like the
+// handlers in the admin package, so that they all select messages in
+// the same way.
+type Query struct {
+	text  string
+	terms []func(m *Message) bool
+
+	// path and prio describe the narrowest listener registration
+	// which receives all matching messages, see Scope().
+	path string
+	prio Priority
+}
+
+// queryOps lists the operators understood by ParseQuery(), with
+// longer operators before their prefixes.
+var queryOps = []string{">=", "<=", "!=", "!~", "=", "~", ">", "<"}
+
+// ParseQuery parses a query like
+//
+//	path~"net/*" prio>=error msg~"timeout" since=5m
+//
+// A query consists of terms separated by white space, and a message
+// matches the query if it matches all terms.  Each term consists of a
+// field name, an operator and a value; values containing white space
+// must be quoted, using Go string syntax.  The following terms are
+// supported:
+//
+//	path=P     the message path is P or a sub-path of P
+//	path~G     the message path, or one of its parent paths, matches
+//	           the glob pattern G (see path.Match)
+//	prio>=P    the message priority is at least P, given as a name
+//	           like "error" or as a number; the operators =, !=, >,
+//	           <, and <= can be used as well
+//	msg~R      the message text matches the regular expression R
+//	msg=S      the message text is S
+//	since=T    the message was sent at or after time T
+//	until=T    the message was sent before time T
+//
+// The operators != and !~ negate path and msg terms.  Times are given
+// either in RFC 3339 format, or as a duration like "5m", which is
+// taken relative to the time of the call to ParseQuery.  The empty
+// query matches all messages.
+func ParseQuery(query string) (*Query, error) {
+	return parseQuery(query, time.Now())
+}
+
+func parseQuery(query string, now time.Time) (*Query, error) {
+	words, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{text: query, prio: PrioAll}
+	for _, word := range words {
+		field, op, value, err := splitTerm(word)
+		if err != nil {
+			return nil, err
+		}
+		var term func(m *Message) bool
+		switch field {
+		case "path":
+			term, err = q.pathTerm(op, value)
+		case "prio":
+			term, err = q.prioTerm(op, value)
+		case "msg":
+			term, err = msgTerm(op, value)
+		case "since", "until":
+			term, err = timeTerm(field, op, value, now)
+		default:
+			err = fmt.Errorf("unknown field %q in query term %q", field, word)
+		}
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, term)
+	}
+	return q, nil
+}
+
+// splitQuery splits a query into terms, keeping quoted values intact.
+func splitQuery(query string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inQuote, escaped := false, false
+	for _, c := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && c == '\\':
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && (c == ' ' || c == '\t' || c == '\n'):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(c)
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in query %q", query)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// splitTerm splits a query term into field name, operator and value.
+func splitTerm(word string) (field, op, value string, err error) {
+	i := 0
+	for i < len(word) && word[i] >= 'a' && word[i] <= 'z' {
+		i++
+	}
+	field = word[:i]
+	for _, candidate := range queryOps {
+		if strings.HasPrefix(word[i:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if field == "" || op == "" {
+		return "", "", "", fmt.Errorf("invalid query term %q", word)
+	}
+	value = word[i+len(op):]
+	if strings.HasPrefix(value, `"`) {
+		value, err = strconv.Unquote(value)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid quoted value in query term %q", word)
+		}
+	}
+	return field, op, value, nil
+}
+
+func opError(field, op string) error {
+	return fmt.Errorf("operator %q cannot be used with field %q", op, field)
+}
+
+func (q *Query) pathTerm(op, value string) (func(m *Message) bool, error) {
+	var match func(p string) bool
+	switch op {
+	case "=", "!=":
+		match = func(p string) bool {
+			return value == "" || p == value || strings.HasPrefix(p, value+"/")
+		}
+		if op == "=" && len(value) > len(q.path) {
+			q.path = value
+		}
+	case "~", "!~":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q", value)
+		}
+		match = func(p string) bool {
+			for i := 0; i <= len(p); i++ {
+				if i < len(p) && p[i] != '/' {
+					continue
+				}
+				if ok, _ := path.Match(value, p[:i]); ok {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		return nil, opError("path", op)
+	}
+	if strings.HasPrefix(op, "!") {
+		return func(m *Message) bool { return !match(m.Path) }, nil
+	}
+	return func(m *Message) bool { return match(m.Path) }, nil
+}
+
+func (q *Query) prioTerm(op, value string) (func(m *Message) bool, error) {
+	prio, err := ParsePriority(value)
+	if err != nil {
+		return nil, err
+	}
+	lower := PrioAll
+	var term func(m *Message) bool
+	switch op {
+	case "=":
+		lower = prio
+		term = func(m *Message) bool { return m.Prio == prio }
+	case "!=":
+		term = func(m *Message) bool { return m.Prio != prio }
+	case ">=":
+		lower = prio
+		term = func(m *Message) bool { return m.Prio >= prio }
+	case ">":
+		lower = prio.Add(1)
+		term = func(m *Message) bool { return m.Prio > prio }
+	case "<=":
+		term = func(m *Message) bool { return m.Prio <= prio }
+	case "<":
+		term = func(m *Message) bool { return m.Prio < prio }
+	default:
+		return nil, opError("prio", op)
+	}
+	q.prio = max(q.prio, lower)
+	return term, nil
+}
+
+func msgTerm(op, value string) (func(m *Message) bool, error) {
+	switch op {
+	case "=":
+		return func(m *Message) bool { return m.Msg == value }, nil
+	case "!=":
+		return func(m *Message) bool { return m.Msg != value }, nil
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!~" {
+			return func(m *Message) bool { return !re.MatchString(m.Msg) }, nil
+		}
+		return func(m *Message) bool { return re.MatchString(m.Msg) }, nil
+	default:
+		return nil, opError("msg", op)
+	}
+}
+
+func timeTerm(field, op, value string, now time.Time) (func(m *Message) bool, error) {
+	if op != "=" {
+		return nil, opError(field, op)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		d, err2 := time.ParseDuration(value)
+		if err2 != nil {
+			return nil, fmt.Errorf("invalid time %q for field %q", value, field)
+		}
+		t = now.Add(-d)
+	}
+	if field == "since" {
+		return func(m *Message) bool { return !m.Time.Before(t) }, nil
+	}
+	return func(m *Message) bool { return m.Time.Before(t) }, nil
+}
+
+// Match reports whether the message 'm' matches the query.  A nil
+// query matches all messages.
+func (q *Query) Match(m *Message) bool {
+	if q == nil {
+		return true
+	}
+	for _, term := range q.terms {
+		if !term(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Scope returns a path and priority such that a listener registered
+// using these values receives all messages matching the query, see
+// Register().  The path is taken from the path= terms, and the
+// priority from the lower bounds given by prio terms; if the query has
+// no such terms, the empty path and PrioAll are returned.
+func (q *Query) Scope() (string, Priority) {
+	return q.path, q.prio
+}
+
+// String returns the query, as passed to ParseQuery().
+func (q *Query) String() string {
+	return q.text
+}
@@ -0,0 +1,128 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	now := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	msgs := []*Message{
+		{Time: now.Add(-10 * time.Minute), Path: "net/http", Prio: PrioError, Msg: "read timeout"},
+		{Time: now.Add(-2 * time.Minute), Path: "net/http/client", Prio: PrioDebug, Msg: "dial timeout"},
+		{Time: now.Add(-time.Minute), Path: "net", Prio: PrioCritical, Msg: "no network"},
+		{Time: now, Path: "db", Prio: PrioInfo, Msg: "query took 5s"},
+	}
+	testData := []struct {
+		query string
+		match string // one character per message
+	}{
+		{"", "1111"},
+		{`path~"net/*" prio>=error msg~"timeout" since=5m`, "0000"},
+		{`path~"net/*" prio>=error msg~"timeout"`, "1000"},
+		{"path~net/*", "1100"},
+		{"path=net", "1110"},
+		{"path!=net", "0001"},
+		{"path~n*", "1110"},
+		{"path!~net", "0001"},
+		{"prio>=error", "1010"},
+		{"prio>error", "0010"},
+		{"prio<info", "0100"},
+		{"prio<=info", "0101"},
+		{"prio=debug", "0100"},
+		{"prio!=-1000", "1011"},
+		{"msg~timeout$", "1100"},
+		{"msg!~timeout", "0011"},
+		{`msg="query took 5s"`, "0001"},
+		{"since=5m", "0111"},
+		{"until=1m", "1100"},
+		{"since=2013-05-01T11:58:00Z until=2013-05-01T12:00:00Z", "0110"},
+	}
+	for _, test := range testData {
+		q, err := parseQuery(test.query, now)
+		if err != nil {
+			t.Errorf("%q: %s", test.query, err)
+			continue
+		}
+		match := ""
+		for _, m := range msgs {
+			if q.Match(m) {
+				match += "1"
+			} else {
+				match += "0"
+			}
+		}
+		if match != test.match {
+			t.Errorf("%q: expected %s, got %s", test.query, test.match, match)
+		}
+		if q.String() != test.query {
+			t.Errorf("wrong query string %q", q.String())
+		}
+	}
+
+	var q *Query
+	if !q.Match(msgs[0]) {
+		t.Error("nil query does not match")
+	}
+}
+
+func TestQueryScope(t *testing.T) {
+	testData := []struct {
+		query string
+		path  string
+		prio  Priority
+	}{
+		{"", "", PrioAll},
+		{"path=net prio>=error", "net", PrioError},
+		{"path=net path=net/http prio>info prio<=error", "net/http", PrioInfo + 1},
+		{"path~net/* prio=debug", "", PrioDebug},
+		{"prio<debug", "", PrioAll},
+	}
+	for _, test := range testData {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path, prio := q.Scope(); path != test.path || prio != test.prio {
+			t.Errorf("%q: expected %q/%d, got %q/%d",
+				test.query, test.path, test.prio, path, prio)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"color=red",
+		"path",
+		"=net",
+		`msg~"unterminated`,
+		`msg~"bad\q"`,
+		"msg~(",
+		"msg>x",
+		"path<net",
+		"path~[",
+		"prio>=loud",
+		"since>5m",
+		"since=yesterday",
+	} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("%q: missing error", query)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaWindow is the time span over which byte quotas are enforced.
+var quotaWindow = time.Minute
+
+// quota limits the number of message bytes delivered to a listener for
+// one path prefix.
+type quota struct {
+	listener *listenerInfo
+	prefix   string
+	limit    int
+
+	mutex   sync.Mutex // protects all following fields
+	start   time.Time  // start of the current window
+	used    int
+	dropped int
+	bytes   int
+	maxPrio Priority
+	timer   *time.Timer
+	stopped bool
+}
+
+// MaxBytesPerMinute limits the size of the messages delivered to a
+// listener for the path 'prefix' and its sub-paths to 'limit' bytes per
+// minute, where the size of a message is the length of its text.  The
+// empty prefix applies to all messages.  Once the quota for a minute
+// is used up, the remaining messages are dropped, and at the end of the
+// minute a single summary message, giving the number and size of the
+// dropped messages, is sent to the listener instead.  The summary uses
+// the highest priority of all dropped messages.
+//
+// The option can be given several times, for different prefixes.  Each
+// message counts against the quota with the longest matching prefix
+// only.  This can be used to protect a paid log backend from a single
+// runaway subsystem.
+func MaxBytesPerMinute(prefix string, limit int) Option {
+	return func(c *listenerInfo) {
+		c.quotas = append(c.quotas, &quota{
+			listener: c,
+			prefix:   prefix,
+			limit:    limit,
+		})
+	}
+}
+
+// findQuota returns the quota with the longest prefix matching 'path',
+// or nil if there is none.
+func findQuota(quotas []*quota, path string) *quota {
+	var best *quota
+	for _, q := range quotas {
+		if q.prefix != "" && path != q.prefix &&
+			!strings.HasPrefix(path, q.prefix+"/") {
+			continue
+		}
+		if best == nil || len(q.prefix) > len(best.prefix) {
+			best = q
+		}
+	}
+	return best
+}
+
+// allow decides whether 'm' fits into the quota, and records the
+// message for the summary otherwise.
+func (q *quota) allow(m *Message) bool {
+	size := len(m.Msg)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(q.start) >= quotaWindow {
+		q.start = now
+		q.used = 0
+	}
+	if q.used+size <= q.limit {
+		q.used += size
+		return true
+	}
+
+	if q.dropped == 0 || m.Prio > q.maxPrio {
+		q.maxPrio = m.Prio
+	}
+	q.dropped++
+	q.bytes += size
+	if q.timer == nil && !q.stopped {
+		q.timer = time.AfterFunc(q.start.Add(quotaWindow).Sub(now), q.summary)
+	}
+	return false
+}
+
+// summary reports the messages dropped in the current window to the
+// listener.
+func (q *quota) summary() {
+	q.mutex.Lock()
+	n := q.dropped
+	bytes := q.bytes
+	prio := q.maxPrio
+	q.dropped = 0
+	q.bytes = 0
+	q.timer = nil
+	stopped := q.stopped
+	q.mutex.Unlock()
+	if n == 0 || stopped {
+		return
+	}
+
+	path := q.prefix
+	if path == "" {
+		path = "trace"
+	}
+	q.listener.deliver(&Message{
+		Time: time.Now(),
+		Path: path,
+		Prio: prio,
+		Msg: fmt.Sprintf("%d messages (%d bytes) suppressed by byte quota of %d bytes per minute",
+			n, bytes, q.limit),
+	})
+}
+
+// stop cancels pending summary messages.  This is called when the
+// listener is unregistered.
+func (q *quota) stop() {
+	q.mutex.Lock()
+	q.stopped = true
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	q.mutex.Unlock()
+}
@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFindQuota(t *testing.T) {
+	quotas := []*quota{{prefix: ""}, {prefix: "db"}, {prefix: "db/mysql"}}
+	for _, test := range []struct {
+		path, prefix string
+	}{
+		{"http", ""},
+		{"db", "db"},
+		{"dbx", ""},
+		{"db/mysql/slow", "db/mysql"},
+		{"db/pg", "db"},
+	} {
+		q := findQuota(quotas, test.path)
+		if q == nil || q.prefix != test.prefix {
+			t.Errorf("%q: wrong quota %v", test.path, q)
+		}
+	}
+	if findQuota(quotas[1:], "http") != nil {
+		t.Error("unexpected quota for http")
+	}
+}
+
+func TestMaxBytesPerMinute(t *testing.T) {
+	defer func(d time.Duration) { quotaWindow = d }(quotaWindow)
+	quotaWindow = 20 * time.Millisecond
+
+	var (
+		mutex   sync.Mutex
+		msgs    []string
+		summary *Message
+	)
+	done := make(chan struct{})
+	handle := Register(func(m *Message) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if strings.Contains(m.Msg, "suppressed") {
+			summary = m
+			close(done)
+			return
+		}
+		msgs = append(msgs, m.Msg)
+	}, "quota", PrioAll, MaxBytesPerMinute("quota/noisy", 10))
+	defer handle.Unregister()
+
+	T("quota/noisy", PrioDebug, "12345")
+	T("quota/noisy", PrioDebug, "12345")
+	T("quota/noisy", PrioError, "1")
+	T("quota/noisy", PrioDebug, "123")
+	T("quota/quiet", PrioInfo, "not limited by the quota")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no summary message received")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(msgs) != 3 {
+		t.Errorf("wrong messages delivered %q", msgs)
+	}
+	expected := "2 messages (4 bytes) suppressed by byte quota of 10 bytes per minute"
+	if summary.Msg != expected {
+		t.Errorf("wrong summary %q", summary.Msg)
+	}
+	if summary.Prio != PrioError {
+		t.Errorf("wrong summary priority %s", summary.Prio)
+	}
+}
+
+func TestQuotaSummaryPanic(t *testing.T) {
+	defer func(d time.Duration) { quotaWindow = d }(quotaWindow)
+	quotaWindow = 10 * time.Millisecond
+
+	reports := make(chan string, 10)
+	h1 := Register(func(m *Message) {
+		reports <- m.Msg
+	}, "trace", PrioError)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {
+		if strings.Contains(m.Msg, "suppressed") {
+			panic("summary")
+		}
+	}, "quotapanic", PrioAll, MaxBytesPerMinute("", 1))
+	defer h2.Unregister()
+
+	T("quotapanic", PrioInfo, "too long")
+	select {
+	case msg := <-reports:
+		if msg != `listener for path "quotapanic" panicked: summary` {
+			t.Errorf("wrong report %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic in summary not recovered")
+	}
+}
@@ -0,0 +1,191 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Realtime is a dispatcher for latency-sensitive code, like trading
+// systems or control loops, which must not allocate memory once it is
+// running.  Messages are sent using the T method of a Realtime value
+// instead of the T() function of this package.  All memory is
+// allocated by NewRealtime(): every message is formatted into one of a
+// fixed number of preallocated slots, with the message text truncated
+// to a fixed size, and the slots are delivered to a single listener by
+// a background goroutine.  If no slot is free, the message is dropped.
+//
+// The messages passed to the listener, including the message text,
+// are stored in the slots and are reused once the listener returns.
+// The listener must thus not retain the message after returning; it
+// can make a copy, using strings.Clone() for the message text.
+type Realtime struct {
+	next   Listener
+	prio   Priority
+	free   chan *rtSlot
+	queue  chan *rtSlot
+	worker *Supervisor
+
+	dropped   atomic.Uint64
+	truncated atomic.Uint64
+
+	mutex  sync.RWMutex // protects closed and sending on queue
+	closed bool
+}
+
+// rtSlot is a preallocated message of a Realtime dispatcher.
+type rtSlot struct {
+	m         Message
+	buf       []byte
+	truncated bool
+}
+
+// Write appends to the buffer of the slot, discarding data which does
+// not fit.  This implements io.Writer for fmt.Fprintf().
+func (s *rtSlot) Write(p []byte) (int, error) {
+	n := copy(s.buf[len(s.buf):cap(s.buf)], p)
+	s.buf = s.buf[:len(s.buf)+n]
+	if n < len(p) {
+		s.truncated = true
+	}
+	return len(p), nil
+}
+
+// NewRealtime returns a new Realtime dispatcher which delivers the
+// messages of priority 'prio' and higher to 'next'.  Up to 'slots'
+// messages can be waiting for delivery, and message texts are
+// truncated to at most 'msgSize' bytes.
+func NewRealtime(next Listener, prio Priority, slots, msgSize int) *Realtime {
+	slots = max(slots, 1)
+	msgSize = max(msgSize, 1)
+	r := &Realtime{
+		next:  next,
+		prio:  prio,
+		free:  make(chan *rtSlot, slots),
+		queue: make(chan *rtSlot, slots),
+	}
+	buf := make([]byte, slots*msgSize)
+	for i := 0; i < slots; i++ {
+		r.free <- &rtSlot{buf: buf[i*msgSize : i*msgSize : (i+1)*msgSize]}
+	}
+	r.worker = Supervise("realtime dispatcher", r.run)
+	return r
+}
+
+// T sends a message to the listener of the dispatcher, see the
+// function T() for the meaning of the arguments.  In contrast to the
+// function T(), arguments of type Valuer, func() interface{}, Route and
+// Binary are passed to fmt.Fprintf() unchanged, and strict mode does
+// not apply.  T does not allocate memory, unless the formatting of the
+// arguments does; for example, implementations of fmt.Stringer may
+// allocate.
+func (r *Realtime) T(path string, prio Priority, format string, args ...interface{}) {
+	if prio < r.prio {
+		return
+	}
+	var slot *rtSlot
+	select {
+	case slot = <-r.free:
+	default:
+		r.dropped.Add(1)
+		return
+	}
+
+	slot.buf = slot.buf[:0]
+	slot.truncated = false
+	fmt.Fprintf(slot, format, args...)
+	if slot.truncated {
+		r.truncated.Add(1)
+		slot.buf = trimRune(slot.buf)
+	}
+	slot.m = Message{
+		Time:   time.Now(),
+		Path:   path,
+		Prio:   prio,
+		Msg:    unsafe.String(unsafe.SliceData(slot.buf), len(slot.buf)),
+		Format: format,
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		r.free <- slot
+		return
+	}
+	r.queue <- slot
+}
+
+// trimRune removes an incomplete UTF-8 sequence at the end of 'b'.
+func trimRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// Dropped returns the number of messages which were discarded because
+// no slot was free, or because Close() had been called.
+func (r *Realtime) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Truncated returns the number of messages whose text has been
+// truncated to fit into a slot.
+func (r *Realtime) Truncated() uint64 {
+	return r.truncated.Load()
+}
+
+// run delivers the queued messages.  If the listener panics, the
+// worker goroutine is restarted by the supervisor; the message being
+// delivered is lost, but its slot is reused.
+func (r *Realtime) run() error {
+	for slot := range r.queue {
+		r.deliver(slot)
+	}
+	return nil
+}
+
+func (r *Realtime) deliver(slot *rtSlot) {
+	defer func() {
+		slot.m = Message{}
+		r.free <- slot
+	}()
+	r.next(&slot.m)
+}
+
+// Close stops accepting new messages, waits until all queued messages
+// have been delivered and then stops the worker goroutine.
+func (r *Realtime) Close() {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mutex.Unlock()
+	r.worker.Wait()
+}
@@ -0,0 +1,92 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRealtime(t *testing.T) {
+	var seen []string
+	r := NewRealtime(func(m *Message) {
+		seen = append(seen, m.Path+":"+strings.Clone(m.Msg))
+	}, PrioInfo, 4, 8)
+	r.T("rt", PrioInfo, "x=%d", 1)
+	r.T("rt", PrioDebug, "ignored")
+	r.T("rt/a", PrioError, "%s", "truncated text")
+	r.T("rt", PrioInfo, "aäöü%s", "ü")
+	r.Close()
+	r.T("rt", PrioInfo, "late")
+
+	expected := []string{"rt:x=1", "rt/a:truncate", "rt:aäöü"}
+	if strings.Join(seen, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q, got %q", expected, seen)
+	}
+	if r.Truncated() != 2 {
+		t.Errorf("expected 2 truncated messages, got %d", r.Truncated())
+	}
+	if r.Dropped() != 1 {
+		t.Errorf("expected 1 dropped message, got %d", r.Dropped())
+	}
+}
+
+func TestRealtimeFull(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	n := 0
+	r := NewRealtime(func(m *Message) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-block
+		n++
+	}, PrioAll, 2, 16)
+	r.T("rt", PrioInfo, "1")
+	<-started
+	r.T("rt", PrioInfo, "2")
+	r.T("rt", PrioInfo, "3")
+	if r.Dropped() != 1 {
+		t.Errorf("expected 1 dropped message, got %d", r.Dropped())
+	}
+	close(block)
+	r.Close()
+	if n != 2 {
+		t.Errorf("expected 2 delivered messages, got %d", n)
+	}
+}
+
+func TestRealtimeAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates memory")
+	}
+	done := make(chan struct{}, 100)
+	r := NewRealtime(func(m *Message) {
+		done <- struct{}{}
+	}, PrioInfo, 100, 64)
+	defer r.Close()
+	x, s := 12345, "hello"
+	allocs := testing.AllocsPerRun(50, func() {
+		r.T("rt", PrioInfo, "x=%d s=%s f=%.2f", x, s, 1.5)
+		r.T("rt", PrioDebug, "ignored %d", x)
+		<-done
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %g", allocs)
+	}
+}
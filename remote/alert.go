@@ -0,0 +1,396 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seehuhn/trace"
+)
+
+// AlertSink is a destination for alerts, like a webhook or an email
+// address.
+type AlertSink interface {
+	Alert(source string, m *trace.Message) error
+}
+
+// AlertRule selects the messages which are forwarded to an AlertSink.
+type AlertRule struct {
+	// Path selects the messages for the given path and its sub-paths.
+	// The empty string selects all messages.
+	Path string
+
+	// Prio is the minimum priority of selected messages.
+	Prio trace.Priority
+
+	// Match, if not nil, must match the message text.
+	Match *regexp.Regexp
+
+	// Sink receives the selected messages.
+	Sink AlertSink
+}
+
+// matches reports whether 'm' is selected by the rule.
+func (rule *AlertRule) matches(m *trace.Message) bool {
+	if m.Prio < rule.Prio {
+		return false
+	}
+	if rule.Path != "" && m.Path != rule.Path &&
+		!strings.HasPrefix(m.Path, rule.Path+"/") {
+		return false
+	}
+	return rule.Match == nil || rule.Match.MatchString(m.Msg)
+}
+
+// AlertRouter forwards the messages received by a collector to alert
+// sinks, according to a list of rules.  This way, only the collector
+// needs the credentials for the alerting services.  Use the Handle
+// method as the handler of a Receiver, for example:
+//
+//	router := remote.NewAlertRouter([]remote.AlertRule{
+//		{Prio: trace.PrioCritical, Sink: &remote.WebhookSink{URL: url}},
+//	}, handler)
+//	defer router.Close()
+//	r := remote.NewReceiver(router.Handle)
+//
+// The alerts are passed to the sinks by a background goroutine, so
+// that slow sinks like WebhookSink do not delay the collector.  If
+// more than 64 alerts are waiting, further alerts are dropped.
+type AlertRouter struct {
+	rules []AlertRule
+	next  func(source string, m *trace.Message)
+
+	mutex  sync.Mutex // protects queue and closed
+	queue  chan routedAlert
+	closed bool
+	done   chan struct{}
+}
+
+type routedAlert struct {
+	sink   AlertSink
+	source string
+	m      *trace.Message
+}
+
+// NewAlertRouter returns a new AlertRouter for the given rules.  All
+// messages, whether they match a rule or not, are passed on to 'next'
+// unless 'next' is nil.
+func NewAlertRouter(rules []AlertRule, next func(source string, m *trace.Message)) *AlertRouter {
+	r := &AlertRouter{
+		rules: rules,
+		next:  next,
+		queue: make(chan routedAlert, 64),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Handle queues a message for the sinks of all matching rules, and
+// then passes it to the next handler.  Alerts which cannot be
+// delivered are reported as trace messages with path "trace/remote".
+func (r *AlertRouter) Handle(source string, m *trace.Message) {
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if !rule.matches(m) {
+			continue
+		}
+		if err := r.enqueue(routedAlert{rule.Sink, source, m}); err != nil {
+			trace.T("trace/remote", trace.PrioError,
+				"cannot send alert for %q: %s", m.Path, err)
+		}
+	}
+	if r.next != nil {
+		r.next(source, m)
+	}
+}
+
+func (r *AlertRouter) enqueue(a routedAlert) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return errors.New("alert router closed")
+	}
+	select {
+	case r.queue <- a:
+		return nil
+	default:
+		return ErrAlertQueueFull
+	}
+}
+
+// Close stops accepting new alerts and waits until the queued alerts
+// have been passed to their sinks.
+func (r *AlertRouter) Close() {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mutex.Unlock()
+	<-r.done
+}
+
+func (r *AlertRouter) run() {
+	defer close(r.done)
+	for a := range r.queue {
+		if err := a.sink.Alert(a.source, a.m); err != nil {
+			trace.T("trace/remote", trace.PrioError,
+				"cannot send alert for %q: %s", a.m.Path, err)
+		}
+	}
+}
+
+// WebhookSink sends alerts as HTTP POST requests to a URL.  The body
+// of each request is a JSON object with fields "source", giving the
+// source of the message, and "message", containing the message in the
+// trace file format.
+type WebhookSink struct {
+	URL string
+
+	// Client is used to send the requests.  If Client is nil, a client
+	// with a timeout of 10 seconds is used.
+	Client *http.Client
+}
+
+type webhookBody struct {
+	Source  string         `json:"source"`
+	Message *trace.Message `json:"message"`
+}
+
+var defaultAlertClient = &http.Client{Timeout: 10 * time.Second}
+
+// Alert sends a single alert.
+func (s *WebhookSink) Alert(source string, m *trace.Message) error {
+	body, err := json.Marshal(&webhookBody{Source: source, Message: m})
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = defaultAlertClient
+	}
+	resp, err := client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// EmailSink sends alerts by email, using the SMTP server at Addr (for
+// example "mail.example.com:587").  If Auth is not nil, it is used to
+// authenticate to the server.  The emails are sent by a background
+// goroutine, so that a slow mail server does not delay the collector;
+// if more than Queue alerts are waiting, further alerts are dropped.
+type EmailSink struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+
+	// Queue is the maximum number of alerts waiting to be sent.  If
+	// Queue is zero, 64 is used.
+	Queue int
+
+	// Timeout limits the time for sending a single email, including
+	// connecting to the server.  If Timeout is zero, 30 seconds are
+	// used.
+	Timeout time.Duration
+
+	once   sync.Once
+	mutex  sync.Mutex // protects queue and closed
+	queue  chan []byte
+	closed bool
+	done   chan struct{}
+}
+
+// ErrAlertQueueFull is returned by EmailSink.Alert, and reported by
+// AlertRouter, if too many alerts are waiting to be sent.
+var ErrAlertQueueFull = errors.New("alert queue full")
+
+// Alert queues a single alert for sending.  Errors which occur while
+// the email is sent are reported as trace messages with path
+// "trace/remote".
+func (s *EmailSink) Alert(source string, m *trace.Message) error {
+	s.once.Do(s.start)
+	mail := s.mail(source, m)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.New("email sink closed")
+	}
+	select {
+	case s.queue <- mail:
+		return nil
+	default:
+		return ErrAlertQueueFull
+	}
+}
+
+// Close stops accepting new alerts and waits until the queued alerts
+// have been sent.
+func (s *EmailSink) Close() {
+	s.once.Do(s.start)
+	s.mutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mutex.Unlock()
+	<-s.done
+}
+
+func (s *EmailSink) start() {
+	size := s.Queue
+	if size <= 0 {
+		size = 64
+	}
+	s.queue = make(chan []byte, size)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		for mail := range s.queue {
+			if err := s.send(mail); err != nil {
+				trace.T("trace/remote", trace.PrioError,
+					"cannot send alert email via %s: %s", s.Addr, err)
+			}
+		}
+	}()
+}
+
+// send delivers one email.  Unlike smtp.SendMail, the whole exchange
+// with the server is subject to a deadline.
+func (s *EmailSink) send(mail []byte) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", s.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if err := c.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(mail); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// mail composes the email for an alert.  The subject consists of the
+// message priority, path and the first line of the message text,
+// shortened to 100 characters.
+func (s *EmailSink) mail(source string, m *trace.Message) []byte {
+	subject, _, _ := strings.Cut(m.Msg, "\n")
+	if utf8.RuneCountInString(subject) > 100 {
+		n := 0
+		for i := 0; i < 100; i++ {
+			_, size := utf8.DecodeRuneInString(subject[n:])
+			n += size
+		}
+		subject = subject[:n] + "..."
+	}
+	subject = fmt.Sprintf("[%s] %s: %s", m.Prio, m.Path, subject)
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", headerValue(s.From))
+	fmt.Fprintf(buf, "To: %s\r\n", headerValue(strings.Join(s.To, ", ")))
+	fmt.Fprintf(buf, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(buf, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(buf, "Time:     %s\r\n", m.Time.Format(time.RFC3339Nano))
+	fmt.Fprintf(buf, "Source:   %s\r\n", oneLine(source))
+	fmt.Fprintf(buf, "Path:     %s\r\n", oneLine(m.Path))
+	fmt.Fprintf(buf, "Priority: %s\r\n\r\n", m.Prio)
+	body := strings.ReplaceAll(m.Msg, "\r", "")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// oneLine removes line breaks from 's'.  Message paths and sources are
+// received from remote programs, and must not be able to add header
+// lines to an email.
+func oneLine(s string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '\r' || c == '\n' {
+			return -1
+		}
+		return c
+	}, s)
+}
+
+// headerValue prepares 's' for use in an email header, removing line
+// breaks and encoding non-ASCII text as described in RFC 2047.
+func headerValue(s string) string {
+	s = oneLine(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return mime.QEncoding.Encode("utf-8", s)
+		}
+	}
+	return s
+}
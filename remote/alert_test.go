@@ -0,0 +1,212 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seehuhn/trace"
+)
+
+type recordSink struct {
+	msgs []string
+	err  error
+}
+
+func (s *recordSink) Alert(source string, m *trace.Message) error {
+	s.msgs = append(s.msgs, source+" "+m.Msg)
+	return s.err
+}
+
+func TestAlertRouter(t *testing.T) {
+	critical := &recordSink{}
+	db := &recordSink{err: errors.New("unreachable")}
+	var passed int
+	router := NewAlertRouter([]AlertRule{
+		{Prio: trace.PrioCritical, Sink: critical},
+		{Path: "db", Prio: trace.PrioError, Match: regexp.MustCompile("dead"), Sink: db},
+	}, func(source string, m *trace.Message) { passed++ })
+
+	for _, m := range []*trace.Message{
+		{Path: "http", Prio: trace.PrioCritical, Msg: "out of memory"},
+		{Path: "db/mysql", Prio: trace.PrioError, Msg: "deadlock"},
+		{Path: "db/mysql", Prio: trace.PrioError, Msg: "slow query"},
+		{Path: "dbx", Prio: trace.PrioError, Msg: "deadlock"},
+		{Path: "db", Prio: trace.PrioInfo, Msg: "dead"},
+	} {
+		router.Handle("host1", m)
+	}
+	router.Close()
+
+	if strings.Join(critical.msgs, ",") != "host1 out of memory" {
+		t.Errorf("wrong critical alerts %q", critical.msgs)
+	}
+	if strings.Join(db.msgs, ",") != "host1 deadlock" {
+		t.Errorf("wrong db alerts %q", db.msgs)
+	}
+	if passed != 5 {
+		t.Errorf("expected 5 messages passed on, got %d", passed)
+	}
+}
+
+type blockingSink chan struct{}
+
+func (s blockingSink) Alert(source string, m *trace.Message) error {
+	<-s
+	return nil
+}
+
+func TestAlertRouterAsync(t *testing.T) {
+	sink := blockingSink(make(chan struct{}))
+	router := NewAlertRouter([]AlertRule{{Sink: sink}}, nil)
+	m := &trace.Message{Path: "db", Prio: trace.PrioError, Msg: "disk full"}
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		router.Handle("host1", m)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("Handle blocked")
+	}
+	close(sink)
+	router.Close()
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got webhookBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	s := &WebhookSink{URL: server.URL}
+	m := &trace.Message{Path: "db", Prio: trace.PrioError, Msg: "deadlock"}
+	if err := s.Alert("host1", m); err != nil {
+		t.Fatal(err)
+	}
+	if got.Source != "host1" || got.Message == nil || got.Message.Msg != "deadlock" {
+		t.Errorf("wrong request body %v", got)
+	}
+
+	s.URL = server.URL + "/%zz"
+	if err := s.Alert("host1", m); err == nil {
+		t.Error("missing error for invalid URL")
+	}
+}
+
+func TestEmailSinkMail(t *testing.T) {
+	s := &EmailSink{From: "trace@example.com", To: []string{"a@example.com", "b@example.com"}}
+	m := &trace.Message{
+		Time: time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC),
+		Path: "db",
+		Prio: trace.PrioCritical,
+		Msg:  "disk full\ndetails",
+	}
+	mail := string(s.mail("host1", m))
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [critical] db: disk full\r\n",
+		"Source:   host1\r\n",
+		"disk full\r\ndetails\r\n",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("missing %q in mail:\n%s", want, mail)
+		}
+	}
+}
+
+func TestEmailSinkHeaders(t *testing.T) {
+	s := &EmailSink{From: "trace@example.com", To: []string{"a@example.com"}}
+	m := &trace.Message{
+		Path: "db\r\nBcc: evil@example.com",
+		Prio: trace.PrioError,
+		Msg:  strings.Repeat("x", 99) + "äöü",
+	}
+	mail := string(s.mail("host1\nBcc: evil@example.com", m))
+	header, _, _ := strings.Cut(mail, "\r\n\r\n")
+	if strings.Contains(mail, "\nBcc:") {
+		t.Errorf("header injected:\n%s", mail)
+	}
+	if !strings.Contains(header, "Subject: =?utf-8?q?") {
+		t.Errorf("subject not encoded:\n%s", header)
+	}
+	for _, line := range strings.Split(header, "\r\n") {
+		if !utf8.ValidString(line) {
+			t.Errorf("invalid header line %q", line)
+		}
+		for _, c := range line {
+			if c >= utf8.RuneSelf {
+				t.Errorf("non-ASCII header line %q", line)
+				break
+			}
+		}
+	}
+}
+
+func TestEmailSinkQueue(t *testing.T) {
+	// The server accepts connections, but never answers.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s := &EmailSink{
+		Addr:    l.Addr().String(),
+		From:    "trace@example.com",
+		To:      []string{"a@example.com"},
+		Queue:   1,
+		Timeout: 100 * time.Millisecond,
+	}
+	m := &trace.Message{Path: "db", Prio: trace.PrioError, Msg: "disk full"}
+	start := time.Now()
+	var errs []error
+	for i := 0; i < 3; i++ {
+		if err := s.Alert("host1", m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("Alert blocked")
+	}
+	if len(errs) == 0 || errs[0] != ErrAlertQueueFull {
+		t.Errorf("expected ErrAlertQueueFull, got %v", errs)
+	}
+	s.Close()
+	if err := s.Alert("host1", m); err == nil {
+		t.Error("Alert succeeded after Close")
+	}
+}
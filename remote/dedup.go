@@ -0,0 +1,149 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// Dedup merges identical messages received from different sources
+// within a short time window into a single message.  This reduces
+// noise when a condition affecting a whole fleet of programs is
+// reported by every instance at once.  Use the Handle method as the
+// handler of a Receiver, for example:
+//
+//	d := remote.NewDedup(time.Second, handler)
+//	r := remote.NewReceiver(d.Handle)
+//
+// Messages are considered identical if they have the same path,
+// priority and text.
+type Dedup struct {
+	window time.Duration
+	next   func(source string, m *trace.Message)
+
+	mutex  sync.Mutex // protects groups and closed
+	groups map[dedupKey]*dedupGroup
+	closed bool
+}
+
+type dedupKey struct {
+	path string
+	prio trace.Priority
+	msg  string
+}
+
+// dedupGroup collects the copies of one message.
+type dedupGroup struct {
+	first   *trace.Message
+	source  string
+	sources map[string]bool
+	timer   *time.Timer
+}
+
+// NewDedup returns a new Dedup which passes the merged messages to
+// 'next'.  A message is held back for the duration 'window' after its
+// first copy has been received; copies from other sources which arrive
+// during this time are merged into it.  The merged message is a copy
+// of the first copy, with the Replicas field set to the number of
+// sources, and is passed to 'next' together with the source of the
+// first copy.
+func NewDedup(window time.Duration, next func(source string, m *trace.Message)) *Dedup {
+	return &Dedup{
+		window: window,
+		next:   next,
+		groups: map[dedupKey]*dedupGroup{},
+	}
+}
+
+// Handle receives a message from the given source.  A message which
+// repeats a message from the same source within the time window is not
+// merged, but passed on immediately.  After Close has been called,
+// all messages are passed on immediately.
+func (d *Dedup) Handle(source string, m *trace.Message) {
+	key := dedupKey{path: m.Path, prio: m.Prio, msg: m.Msg}
+
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		d.next(source, m)
+		return
+	}
+	g := d.groups[key]
+	if g == nil {
+		g = &dedupGroup{
+			first:   m,
+			source:  source,
+			sources: map[string]bool{source: true},
+		}
+		g.timer = time.AfterFunc(d.window, func() { d.flush(key, g) })
+		d.groups[key] = g
+		d.mutex.Unlock()
+		return
+	}
+	repeat := g.sources[source]
+	g.sources[source] = true
+	d.mutex.Unlock()
+
+	if repeat {
+		d.next(source, m)
+	}
+}
+
+// flush passes on the merged message for group 'g', unless this has
+// already been done.
+func (d *Dedup) flush(key dedupKey, g *dedupGroup) {
+	d.mutex.Lock()
+	if d.groups[key] != g {
+		d.mutex.Unlock()
+		return
+	}
+	delete(d.groups, key)
+	out := *g.first
+	out.Replicas = len(g.sources)
+	d.mutex.Unlock()
+
+	d.next(g.source, &out)
+}
+
+// Close passes on all messages which are currently held back, in the
+// order in which their first copies were sent.
+func (d *Dedup) Close() {
+	d.mutex.Lock()
+	d.closed = true
+	var keys []dedupKey
+	for key, g := range d.groups {
+		g.timer.Stop()
+		keys = append(keys, key)
+	}
+	groups := d.groups
+	d.groups = map[dedupKey]*dedupGroup{}
+	d.mutex.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		return groups[keys[i]].first.Time.Before(groups[keys[j]].first.Time)
+	})
+	for _, key := range keys {
+		g := groups[key]
+		out := *g.first
+		out.Replicas = len(g.sources)
+		d.next(g.source, &out)
+	}
+}
@@ -0,0 +1,97 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestDedup(t *testing.T) {
+	type result struct {
+		source   string
+		msg      string
+		replicas int
+	}
+	var (
+		mutex sync.Mutex
+		out   []result
+	)
+	d := NewDedup(time.Hour, func(source string, m *trace.Message) {
+		mutex.Lock()
+		out = append(out, result{source, m.Msg, m.Replicas})
+		mutex.Unlock()
+	})
+
+	start := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, in := range []struct {
+		source string
+		msg    string
+	}{
+		{"a", "disk full"},
+		{"b", "disk full"},
+		{"c", "disk full"},
+		{"a", "cache miss"},
+		{"a", "disk full"},
+	} {
+		d.Handle(in.source, &trace.Message{
+			Time: start.Add(time.Duration(i) * time.Second),
+			Path: "test",
+			Prio: trace.PrioError,
+			Msg:  in.msg,
+		})
+	}
+	d.Close()
+	d.Handle("b", &trace.Message{Path: "test", Msg: "late"})
+
+	expected := []result{
+		{"a", "disk full", 0},
+		{"a", "disk full", 3},
+		{"a", "cache miss", 1},
+		{"b", "late", 0},
+	}
+	if len(out) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, out)
+	}
+	for i := range expected {
+		if out[i] != expected[i] {
+			t.Errorf("%d: expected %v, got %v", i, expected[i], out[i])
+		}
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	done := make(chan *trace.Message, 1)
+	d := NewDedup(10*time.Millisecond, func(source string, m *trace.Message) {
+		done <- m
+	})
+	defer d.Close()
+	m := &trace.Message{Path: "test", Msg: "hello"}
+	d.Handle("a", m)
+	d.Handle("b", m)
+	select {
+	case out := <-done:
+		if out.Replicas != 2 {
+			t.Errorf("expected 2 replicas, got %d", out.Replicas)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
@@ -0,0 +1,164 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package remote streams trace messages over the network to a remote
+// collector.
+//
+// A Sender, used as a trace listener, forwards messages to a Receiver
+// over a TCP or unix domain socket connection.  While the connection is
+// down, the Sender buffers messages locally and tries to reconnect with
+// exponential backoff.  Example:
+//
+//	s := remote.NewSender("tcp", "diag.example.com:7000")
+//	handle := trace.Register(s.Listen, "", trace.PrioInfo,
+//		trace.CaptureCaller())
+//	// ... code which calls trace.T()
+//	handle.Unregister()
+//	s.Close()
+//
+// On the collector:
+//
+//	l, err := net.Listen("tcp", ":7000")
+//	// ... error handling
+//	r := remote.NewReceiver(func(source string, m *trace.Message) {
+//		fmt.Println(source, m.Path, m.Msg)
+//	})
+//	err = r.Serve(l)
+//
+// Each message is transmitted as a frame, consisting of a 24 byte
+// header followed by the JSON encoding of the trace.Message.  The
+// header contains, in big-endian byte order, the length of the JSON
+// data (4 bytes), the stream ID (8 bytes), the sequence number (8
+// bytes) and the CRC-32 (IEEE) checksum of the JSON data (4 bytes).
+// Every Sender chooses a random stream ID and numbers its messages
+// consecutively, starting at 1, so that the Receiver can detect lost
+// and corrupted messages for every source, across reconnections.
+//
+// Before the first frame, the Sender and the Receiver exchange a
+// handshake, so that both sides can be upgraded independently.  Each
+// handshake message consists of the bytes "TRCH", the length of the
+// JSON data (4 bytes, big-endian) and a JSON object.  The Sender lists
+// the protocol versions, encodings and compression methods it
+// supports, in order of preference, and optionally an authentication
+// token:
+//
+//	{"versions":[2],"encodings":["json"],"compression":["gzip","none"],"token":"...","acks":true}
+//
+// The Receiver answers with its choices, or with an error message, in
+// which case it closes the connection:
+//
+//	{"version":2,"encoding":"json","compression":"gzip","acks":true}
+//
+// With gzip compression, all following frames are sent as one gzip
+// stream.  If both sides set "acks" in their handshake messages, the
+// Receiver confirms the frames it has received, after each batch of
+// frames, by sending a handshake-format message of the form
+//
+//	{"stream":1234,"seq":42}
+//
+// back to the Sender.  Sender.Verify uses these acknowledgements to
+// confirm delivery for trace.SelfTest().  Connections from Senders which predate the handshake
+// (protocol version 1) start directly with a frame and are still
+// accepted.  Conversely, a Sender falls back to version 1 if the
+// Receiver drops the connection in response to the handshake.
+package remote
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/seehuhn/trace"
+)
+
+// MaxFrameSize is the maximal size in bytes of the JSON encoding of a
+// single message.  Longer messages are discarded by the Sender and
+// cause the Receiver to drop the connection.
+const MaxFrameSize = 1 << 20
+
+// headerSize is the size of the frame header in bytes.
+const headerSize = 24
+
+var errFrameSize = errors.New("frame too large")
+
+// ErrChecksum is returned by ReadFrame() if the checksum of a frame
+// does not match its contents.
+var ErrChecksum = errors.New("frame checksum mismatch")
+
+// Frame is a single message, as transmitted over the network.
+type Frame struct {
+	Stream  uint64
+	Seq     uint64
+	Message *trace.Message
+}
+
+// WriteFrame writes a single frame to 'w', in the format described in
+// the package documentation.
+func WriteFrame(w io.Writer, f *Frame) error {
+	body, err := json.Marshal(f.Message)
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxFrameSize {
+		return errFrameSize
+	}
+	frame := make([]byte, headerSize+len(body))
+	binary.BigEndian.PutUint32(frame[0:], uint32(len(body)))
+	binary.BigEndian.PutUint64(frame[4:], f.Stream)
+	binary.BigEndian.PutUint64(frame[12:], f.Seq)
+	binary.BigEndian.PutUint32(frame[20:], crc32.ChecksumIEEE(body))
+	copy(frame[headerSize:], body)
+	_, err = w.Write(frame)
+	return err
+}
+
+// ReadFrame reads a single frame from 'r', in the format described in
+// the package documentation.  At the end of the stream, io.EOF is
+// returned.  If the checksum does not match, the returned frame has
+// the Stream and Seq fields set, but no message, and the error is
+// ErrChecksum; the stream can still be read after such an error.
+func ReadFrame(r io.Reader) (*Frame, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[0:])
+	if n > MaxFrameSize {
+		return nil, errFrameSize
+	}
+	f := &Frame{
+		Stream: binary.BigEndian.Uint64(header[4:]),
+		Seq:    binary.BigEndian.Uint64(header[12:]),
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[20:]) {
+		return f, ErrChecksum
+	}
+	m := &trace.Message{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, err
+	}
+	f.Message = m
+	return f, nil
+}
@@ -0,0 +1,146 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestFrames(t *testing.T) {
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	frames := []*Frame{
+		{Stream: 7, Seq: 1, Message: &trace.Message{Time: when, Path: "a/b",
+			Prio: trace.PrioError, Msg: "hello"}},
+		{Stream: 7, Seq: 2, Message: &trace.Message{Time: when, Path: "c",
+			Prio: trace.PrioDebug, Msg: "two\nlines", File: "c.go", Line: 3}},
+	}
+	buf := &bytes.Buffer{}
+	for _, f := range frames {
+		if err := WriteFrame(buf, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range frames {
+		got, err := ReadFrame(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, gm := f.Message, got.Message
+		if got.Stream != f.Stream || got.Seq != f.Seq ||
+			!gm.Time.Equal(m.Time) || gm.Path != m.Path ||
+			gm.Prio != m.Prio || gm.Msg != m.Msg || gm.Line != m.Line {
+			t.Errorf("expected %v, got %v", f, got)
+		}
+	}
+	if _, err := ReadFrame(buf); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	short := make([]byte, headerSize+1)
+	short[3] = 9
+	if _, err := ReadFrame(bytes.NewReader(short)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	huge := make([]byte, headerSize)
+	huge[0] = 255
+	if _, err := ReadFrame(bytes.NewReader(huge)); err != errFrameSize {
+		t.Errorf("expected errFrameSize, got %v", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	buf := &bytes.Buffer{}
+	for seq := uint64(1); seq <= 2; seq++ {
+		err := WriteFrame(buf, &Frame{Stream: 1, Seq: seq,
+			Message: &trace.Message{Path: "a", Msg: "hello"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+	data[headerSize+3] ^= 1
+
+	r := bytes.NewReader(data)
+	f, err := ReadFrame(r)
+	if err != ErrChecksum || f.Seq != 1 {
+		t.Errorf("expected checksum error for frame 1, got %v %v", f, err)
+	}
+	f, err = ReadFrame(r)
+	if err != nil || f.Seq != 2 || f.Message.Msg != "hello" {
+		t.Errorf("cannot read frame after checksum error: %v %v", f, err)
+	}
+}
+
+func TestReceiverCheck(t *testing.T) {
+	r := NewReceiver(nil)
+	var delivered []uint64
+	for _, f := range []struct {
+		seq     uint64
+		corrupt bool
+	}{
+		{1, false}, {2, false}, {5, false}, {3, false}, {6, true}, {7, false},
+	} {
+		if r.check(&connInfo{source: "src"}, &Frame{Stream: 9, Seq: f.seq}, f.corrupt) {
+			delivered = append(delivered, f.seq)
+		}
+	}
+	if fmt.Sprint(delivered) != "[1 2 5 7]" {
+		t.Errorf("wrong frames delivered %v", delivered)
+	}
+	expected := StreamStats{Stream: 9, Source: "src", LastSeq: 7,
+		Received: 4, Missing: 3, Duplicates: 1, Corrupt: 1}
+	if st := r.Streams(); len(st) != 1 || st[0] != expected {
+		t.Errorf("wrong statistics %+v", st)
+	}
+}
+
+func TestReceiverExpiry(t *testing.T) {
+	defer func(n int) { maxStreams = n }(maxStreams)
+	maxStreams = 3
+
+	r := NewReceiver(nil)
+	c := &connInfo{source: "src"}
+	for id := uint64(1); id <= 3; id++ {
+		r.check(c, &Frame{Stream: id, Seq: 1}, false)
+	}
+	r.streams[2].lastSeen = time.Now().Add(-2 * streamExpiry)
+	r.check(c, &Frame{Stream: 4, Seq: 1}, false)
+	if st := r.Streams(); len(st) != 3 || st[0].Stream != 1 || st[1].Stream != 3 {
+		t.Errorf("expired stream not removed: %+v", st)
+	}
+
+	r.streams[3].lastSeen = time.Now().Add(-time.Minute)
+	r.check(c, &Frame{Stream: 5, Seq: 1}, false)
+	var ids []uint64
+	for _, st := range r.Streams() {
+		ids = append(ids, st.Stream)
+	}
+	if fmt.Sprint(ids) != "[1 4 5]" {
+		t.Errorf("wrong streams kept %v", ids)
+	}
+
+	// A forgotten stream starts again from scratch.
+	if !r.check(c, &Frame{Stream: 3, Seq: 1}, false) {
+		t.Error("frame of forgotten stream not delivered")
+	}
+}
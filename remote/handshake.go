@@ -0,0 +1,165 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// ProtocolVersion is the newest version of the network protocol
+// implemented by this package.  Version 1 is the original protocol,
+// where frames are sent without a handshake; Receivers still accept
+// such connections.
+const ProtocolVersion = 2
+
+// handshakeMagic starts the handshake messages.  The first frame of a
+// version 1 connection starts with the frame length, which is less
+// than MaxFrameSize, so that the two cases can be told apart by the
+// first byte.
+var handshakeMagic = [4]byte{'T', 'R', 'C', 'H'}
+
+// maxHandshakeSize is the maximal size in bytes of the JSON encoding of
+// a handshake message.
+const maxHandshakeSize = 1 << 16
+
+// Supported encodings and compression methods, in order of preference.
+var (
+	supportedEncodings   = []string{"json"}
+	supportedCompression = []string{"gzip", "none"}
+)
+
+// hello is sent by a Sender at the start of a connection.  Every list
+// is in order of preference.  ClockServer and ClockOffset give the
+// result of the sender's most recent trace.MeasureClockOffset() call,
+// if any, so that the receiver can judge the message timestamps.  Acks
+// is set if the sender reads acknowledgements.
+type hello struct {
+	Versions    []int    `json:"versions"`
+	Encodings   []string `json:"encodings"`
+	Compression []string `json:"compression"`
+	Token       string   `json:"token,omitempty"`
+	ClockServer string   `json:"clock_server,omitempty"`
+	ClockOffset int64    `json:"clock_offset_ns,omitempty"`
+	Acks        bool     `json:"acks,omitempty"`
+}
+
+// welcome is the reply of the Receiver to a hello message.  Either
+// Error is set, or the other fields give the choices for the
+// connection.  Acks is set if the receiver sends acknowledgements.
+type welcome struct {
+	Version     int    `json:"version,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	Compression string `json:"compression,omitempty"`
+	Acks        bool   `json:"acks,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ack is sent by a Receiver, in the format of a handshake message, to
+// confirm that all frames of a stream up to sequence number Seq have
+// been received.  Frames which were lost on the way count as received.
+type ack struct {
+	Stream uint64 `json:"stream"`
+	Seq    uint64 `json:"seq"`
+}
+
+var errNoHandshake = errors.New("no handshake")
+
+// writeHandshake writes a handshake message, consisting of the magic
+// bytes, the length of the JSON data (4 bytes, big-endian) and the JSON
+// encoding of 'v'.
+func writeHandshake(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := make([]byte, 8+len(body))
+	copy(msg, handshakeMagic[:])
+	binary.BigEndian.PutUint32(msg[4:], uint32(len(body)))
+	copy(msg[8:], body)
+	_, err = w.Write(msg)
+	return err
+}
+
+// readHandshake reads a handshake message into 'v'.  If the data in
+// 'r' does not start with a handshake message, errNoHandshake is
+// returned and no data is consumed.
+func readHandshake(r *bufio.Reader, v interface{}) error {
+	magic, err := r.Peek(len(handshakeMagic))
+	if err != nil {
+		return err
+	}
+	if [4]byte(magic) != handshakeMagic {
+		return errNoHandshake
+	}
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(header[4:])
+	if n > maxHandshakeSize {
+		return errFrameSize
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// negotiate chooses the protocol version, encoding and compression for
+// a connection, given the sender's hello message.  The authentication
+// token is checked by the caller.
+func negotiate(h *hello) *welcome {
+	w := &welcome{}
+	for _, v := range h.Versions {
+		if v > w.Version && v >= 2 && v <= ProtocolVersion {
+			w.Version = v
+		}
+	}
+	w.Encoding = choose(h.Encodings, supportedEncodings)
+	w.Compression = choose(h.Compression, supportedCompression)
+	w.Acks = h.Acks
+	switch {
+	case w.Version == 0:
+		return &welcome{Error: fmt.Sprintf("no common protocol version in %v", h.Versions)}
+	case w.Encoding == "":
+		return &welcome{Error: fmt.Sprintf("no common encoding in %q", h.Encodings)}
+	case w.Compression == "":
+		return &welcome{Error: fmt.Sprintf("no common compression in %q", h.Compression)}
+	}
+	return w
+}
+
+// choose returns the first element of 'offered' which is contained in
+// 'supported', or the empty string if there is no such element.
+func choose(offered, supported []string) string {
+	for _, s := range offered {
+		if slices.Contains(supported, s) {
+			return s
+		}
+	}
+	return ""
+}
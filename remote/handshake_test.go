@@ -0,0 +1,243 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		h    hello
+		want welcome
+	}{
+		{hello{Versions: []int{2}, Encodings: []string{"json"}, Compression: []string{"gzip", "none"}},
+			welcome{Version: 2, Encoding: "json", Compression: "gzip"}},
+		{hello{Versions: []int{3, 2}, Encodings: []string{"cbor", "json"}, Compression: []string{"zstd", "none"}},
+			welcome{Version: 2, Encoding: "json", Compression: "none"}},
+		{hello{Versions: []int{3}, Encodings: []string{"json"}, Compression: []string{"none"}},
+			welcome{Error: "no common protocol version in [3]"}},
+		{hello{Versions: []int{2}, Encodings: []string{"cbor"}, Compression: []string{"none"}},
+			welcome{Error: `no common encoding in ["cbor"]`}},
+		{hello{Versions: []int{2}, Encodings: []string{"json"}},
+			welcome{Error: "no common compression in []"}},
+	}
+	for i, c := range cases {
+		got := negotiate(&c.h)
+		if *got != c.want {
+			t.Errorf("%d: expected %+v, got %+v", i, c.want, *got)
+		}
+	}
+}
+
+func TestHandshakeMessages(t *testing.T) {
+	buf := &bytes.Buffer{}
+	in := &welcome{Version: 2, Encoding: "json", Compression: "none"}
+	if err := writeHandshake(buf, in); err != nil {
+		t.Fatal(err)
+	}
+	out := &welcome{}
+	if err := readHandshake(bufio.NewReader(buf), out); err != nil {
+		t.Fatal(err)
+	}
+	if *out != *in {
+		t.Errorf("expected %+v, got %+v", *in, *out)
+	}
+
+	err := WriteFrame(buf, &Frame{Stream: 1, Seq: 1, Message: &trace.Message{Msg: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd := bufio.NewReader(buf)
+	if err := readHandshake(rd, out); err != errNoHandshake {
+		t.Errorf("expected errNoHandshake, got %v", err)
+	}
+	if f, err := ReadFrame(rd); err != nil || f.Message.Msg != "x" {
+		t.Errorf("frame not readable after handshake check: %v %v", f, err)
+	}
+}
+
+func TestHandshakeClock(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		writeHandshake(client, &hello{
+			Versions:    []int{ProtocolVersion},
+			Encodings:   []string{"json"},
+			Compression: []string{"none"},
+			ClockServer: "pool.ntp.org:123",
+			ClockOffset: int64(-3 * time.Millisecond),
+		})
+		readHandshake(bufio.NewReader(client), &welcome{})
+	}()
+
+	r := NewReceiver(func(string, *trace.Message) {})
+	c, _, err := r.handshake(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.clockServer != "pool.ntp.org:123" || c.clockOffset != -3*time.Millisecond {
+		t.Errorf("wrong clock information %q %s", c.clockServer, c.clockOffset)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	s := NewSender("tcp", l.Addr().String())
+	s.SetCompression(true)
+	s.Listen(&trace.Message{Path: "a", Msg: "1"})
+	s.Listen(&trace.Message{Path: "a", Msg: "2"})
+	if msgs := wait(2); msgs[0] != "1" || msgs[1] != "2" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+	s.Listen(&trace.Message{Path: "a", Msg: "3"})
+	s.Close()
+	wait(3)
+
+	st := r.Streams()
+	if len(st) != 1 || st[0].Version != ProtocolVersion || st[0].Compression != "gzip" {
+		t.Errorf("wrong stream statistics %+v", st)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	saved := minBackoff
+	minBackoff = 10 * time.Millisecond
+	defer func() { minBackoff = saved }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	r.SetAuthenticator(func(source, token string) error {
+		if token != "secret" {
+			return errors.New("wrong token")
+		}
+		return nil
+	})
+	go r.Serve(l)
+	defer r.Close()
+
+	bad := NewSender("tcp", l.Addr().String())
+	bad.SetAuthToken("guess")
+	bad.Listen(&trace.Message{Path: "a", Msg: "rejected"})
+	deadline := time.Now().Add(5 * time.Second)
+	for bad.HealthCheck() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := bad.HealthCheck(); err == nil {
+		t.Error("connection with wrong token not rejected")
+	}
+	bad.Close()
+
+	// protocol version 1 connections have no token
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	WriteFrame(conn, &Frame{Stream: 1, Seq: 1, Message: &trace.Message{Msg: "old"}})
+	conn.Close()
+
+	good := NewSender("tcp", l.Addr().String())
+	good.SetAuthToken("secret")
+	good.Listen(&trace.Message{Path: "a", Msg: "accepted"})
+	good.Close()
+	if msgs := wait(1); len(msgs) != 1 || msgs[0] != "accepted" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	// a Sender which predates the handshake
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	WriteFrame(conn, &Frame{Stream: 1, Seq: 1, Message: &trace.Message{Msg: "old"}})
+	conn.Close()
+	if msgs := wait(1); msgs[0] != "old" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+	if st := r.Streams(); len(st) != 1 || st[0].Version != 1 {
+		t.Errorf("wrong stream statistics %+v", st)
+	}
+
+	// a Receiver which predates the handshake, and drops the
+	// connection because the handshake looks like an invalid frame
+	old, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := old.Accept()
+			if err != nil {
+				return
+			}
+			f, err := ReadFrame(conn)
+			conn.Close()
+			if err == nil {
+				received <- f.Message.Msg
+				return
+			}
+		}
+	}()
+	s := NewSender("tcp", old.Addr().String())
+	s.Listen(&trace.Message{Msg: "new"})
+	select {
+	case msg := <-received:
+		if msg != "new" {
+			t.Errorf("wrong message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no fallback to protocol version 1")
+	}
+	if err := s.Verify(context.Background(), nil); !errors.Is(err, trace.ErrNotVerifiable) {
+		t.Errorf("expected ErrNotVerifiable, got %v", err)
+	}
+	s.Close()
+}
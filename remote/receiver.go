@@ -0,0 +1,370 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// Limits for the statistics kept by a Receiver.  Streams which have not
+// sent a frame for streamExpiry are forgotten, and if more than
+// maxStreams streams are known, the least recently seen ones are
+// forgotten.  A forgotten stream which sends again is counted as a new
+// stream.
+var (
+	streamExpiry = time.Hour
+	maxStreams   = 1024
+)
+
+// Receiver accepts connections from Senders and passes the received
+// messages to a handler function.  For every stream, the Receiver
+// checks the sequence numbers and checksums of the frames: gaps are
+// reported as missing messages, repeated frames (which a Sender may
+// re-transmit after a reconnect) are discarded, and frames with wrong
+// checksums are counted as corrupt.  Streams which have been idle for
+// a long time are forgotten.
+type Receiver struct {
+	handler func(source string, m *trace.Message)
+
+	mutex     sync.Mutex // protects the following fields
+	auth      func(source, token string) error
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	streams   map[uint64]*streamInfo
+	closed    bool
+
+	wg sync.WaitGroup
+}
+
+// NewReceiver returns a new Receiver which calls 'handler' for every
+// message received.  The argument 'source' gives the remote address of
+// the connection the message was received on.  The handler may be
+// called concurrently for messages from different connections.
+func NewReceiver(handler func(source string, m *trace.Message)) *Receiver {
+	return &Receiver{
+		handler:   handler,
+		listeners: map[net.Listener]bool{},
+		conns:     map[net.Conn]bool{},
+		streams:   map[uint64]*streamInfo{},
+	}
+}
+
+// SetAuthenticator installs a function which checks the token sent by
+// each Sender in the handshake, see Sender.SetAuthToken().  If 'auth'
+// returns an error, the connection is rejected.  Once an authenticator
+// is installed, connections using protocol version 1, which has no
+// handshake, are rejected as well.  The function only affects
+// connections established after the call.
+func (r *Receiver) SetAuthenticator(auth func(source, token string) error) {
+	r.mutex.Lock()
+	r.auth = auth
+	r.mutex.Unlock()
+}
+
+// StreamStats describes the messages received from one Sender.
+type StreamStats struct {
+	// Stream is the stream ID chosen by the sender, and Source is the
+	// remote address of the most recent connection for the stream.
+	Stream uint64
+	Source string
+
+	// Version and Compression give the protocol version and the
+	// compression method negotiated for the most recent connection.
+	Version     int
+	Compression string
+
+	// ClockServer and ClockOffset give the clock offset of the sender
+	// to an NTP server, as measured by trace.MeasureClockOffset() and
+	// reported in the handshake of the most recent connection.  If the
+	// sender has made no measurement, ClockServer is empty.
+	ClockServer string
+	ClockOffset time.Duration
+
+	// LastSeq is the highest sequence number seen.
+	LastSeq uint64
+
+	// Received counts the messages passed to the handler, Missing the
+	// messages lost in gaps of the sequence numbers, Duplicates the
+	// discarded repeated frames, and Corrupt the frames with checksum
+	// errors.
+	Received   uint64
+	Missing    uint64
+	Duplicates uint64
+	Corrupt    uint64
+}
+
+// streamInfo holds the statistics for one stream, together with the
+// time the stream was last seen.
+type streamInfo struct {
+	StreamStats
+	lastSeen time.Time
+}
+
+// Streams returns the statistics for all streams seen so far, ordered
+// by stream ID.  Streams which have been forgotten, see streamExpiry,
+// are not included.
+func (r *Receiver) Streams() []StreamStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	res := make([]StreamStats, 0, len(r.streams))
+	for _, st := range r.streams {
+		res = append(res, st.StreamStats)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Stream < res[j].Stream })
+	return res
+}
+
+// check updates the statistics for the stream of 'f' and reports
+// whether the frame should be passed to the handler.  Gaps and checksum
+// errors are reported as trace messages.
+func (r *Receiver) check(c *connInfo, f *Frame, corrupt bool) bool {
+	source := c.source
+	now := time.Now()
+	r.mutex.Lock()
+	st := r.streams[f.Stream]
+	if st == nil {
+		r.pruneStreams(now)
+		st = &streamInfo{StreamStats: StreamStats{Stream: f.Stream}}
+		r.streams[f.Stream] = st
+	}
+	st.lastSeen = now
+	st.Source = source
+	st.Version = c.version
+	st.Compression = c.compression
+	st.ClockServer = c.clockServer
+	st.ClockOffset = c.clockOffset
+	var missing uint64
+	deliver := false
+	switch {
+	case corrupt:
+		st.Corrupt++
+	case f.Seq <= st.LastSeq:
+		st.Duplicates++
+	default:
+		missing = f.Seq - st.LastSeq - 1
+		st.Missing += missing
+		st.LastSeq = f.Seq
+		st.Received++
+		deliver = true
+	}
+	r.mutex.Unlock()
+
+	if corrupt {
+		trace.T("trace/remote", trace.PrioError,
+			"corrupt message %d from stream %016x (%s)", f.Seq, f.Stream, source)
+	} else if missing > 0 {
+		trace.T("trace/remote", trace.PrioError,
+			"%d messages missing before message %d from stream %016x (%s)",
+			missing, f.Seq, f.Stream, source)
+	}
+	return deliver
+}
+
+// pruneStreams removes the streams which have expired, and the least
+// recently seen streams if there is no room for another stream.  The
+// caller must hold r.mutex.
+func (r *Receiver) pruneStreams(now time.Time) {
+	for id, st := range r.streams {
+		if now.Sub(st.lastSeen) > streamExpiry {
+			delete(r.streams, id)
+		}
+	}
+	excess := len(r.streams) - maxStreams + 1
+	if excess <= 0 {
+		return
+	}
+	idle := make([]*streamInfo, 0, len(r.streams))
+	for _, st := range r.streams {
+		idle = append(idle, st)
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return idle[i].lastSeen.Before(idle[j].lastSeen)
+	})
+	for _, st := range idle[:excess] {
+		delete(r.streams, st.Stream)
+	}
+}
+
+// Serve accepts connections on 'l' and reads messages from each
+// connection in a separate goroutine.  Serve returns when 'l' fails,
+// for example because Close has been called, and always returns a
+// non-nil error.
+func (r *Receiver) Serve(l net.Listener) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		l.Close()
+		return net.ErrClosed
+	}
+	r.listeners[l] = true
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.listeners, l)
+		r.mutex.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		r.mutex.Lock()
+		if r.closed {
+			r.mutex.Unlock()
+			conn.Close()
+			return net.ErrClosed
+		}
+		r.conns[conn] = true
+		r.wg.Add(1)
+		r.mutex.Unlock()
+		go r.serveConn(conn)
+	}
+}
+
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mutex.Lock()
+		delete(r.conns, conn)
+		r.mutex.Unlock()
+		conn.Close()
+	}()
+
+	c, rd, err := r.handshake(conn)
+	if err == io.EOF {
+		return
+	} else if err != nil {
+		trace.T("trace/remote", trace.PrioError,
+			"handshake with %s failed: %s", conn.RemoteAddr(), err)
+		return
+	}
+	for {
+		f, err := ReadFrame(rd)
+		if err == ErrChecksum {
+			r.check(c, f, true)
+			continue
+		} else if err == io.EOF {
+			return
+		} else if err != nil {
+			trace.T("trace/remote", trace.PrioError,
+				"cannot read from %s: %s", c.source, err)
+			return
+		}
+		if r.check(c, f, false) {
+			r.handler(c.source, f.Message)
+		}
+		// Frames are acknowledged once all data received so far has
+		// been processed, so that there is one acknowledgement per
+		// batch of frames instead of one per frame.
+		if c.acks && rd.Buffered() == 0 {
+			err := writeHandshake(conn, &ack{Stream: f.Stream, Seq: f.Seq})
+			if err != nil {
+				trace.T("trace/remote", trace.PrioError,
+					"cannot send acknowledgement to %s: %s", c.source, err)
+				return
+			}
+		}
+	}
+}
+
+// connInfo describes the parameters negotiated for a connection.
+type connInfo struct {
+	source      string
+	version     int
+	compression string
+	clockServer string
+	clockOffset time.Duration
+	acks        bool
+}
+
+// handshake performs the server side of the handshake on a new
+// connection and returns the reader for the frames.  Connections from
+// Senders using protocol version 1, which start with a frame instead of
+// a handshake, are accepted unless an authenticator is installed.
+func (r *Receiver) handshake(conn net.Conn) (*connInfo, *bufio.Reader, error) {
+	c := &connInfo{source: conn.RemoteAddr().String()}
+	r.mutex.Lock()
+	auth := r.auth
+	r.mutex.Unlock()
+
+	rd := bufio.NewReader(conn)
+	h := &hello{}
+	err := readHandshake(rd, h)
+	if err == errNoHandshake {
+		if auth != nil {
+			return nil, nil, errors.New("protocol version 1 connections not allowed")
+		}
+		c.version = 1
+		c.compression = "none"
+		return c, rd, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	w := negotiate(h)
+	if w.Error == "" && auth != nil {
+		if err := auth(c.source, h.Token); err != nil {
+			w = &welcome{Error: "authentication failed: " + err.Error()}
+		}
+	}
+	if err := writeHandshake(conn, w); err != nil {
+		return nil, nil, err
+	}
+	if w.Error != "" {
+		return nil, nil, errors.New(w.Error)
+	}
+	c.version = w.Version
+	c.compression = w.Compression
+	c.clockServer = h.ClockServer
+	c.clockOffset = time.Duration(h.ClockOffset)
+	c.acks = w.Acks
+	if w.Compression == "gzip" {
+		zr, err := gzip.NewReader(rd)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c, bufio.NewReader(zr), nil
+	}
+	return c, rd, nil
+}
+
+// Close closes all listeners passed to Serve, as well as all open
+// connections, and waits until all handler calls have returned.
+func (r *Receiver) Close() error {
+	r.mutex.Lock()
+	r.closed = true
+	for l := range r.listeners {
+		l.Close()
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,522 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// DefaultBufferSize is the number of messages a Sender buffers while the
+// connection to the receiver is down.
+const DefaultBufferSize = 4096
+
+// FlushTimeout is the maximal time Sender.Flush() waits for queued
+// messages to be sent.
+var FlushTimeout = 5 * time.Second
+
+// Limits for the delay between connection attempts.  The delay starts
+// at minBackoff and is doubled after every failed attempt and after
+// every lost connection, up to maxBackoff.  It is reset to minBackoff
+// only when a connection is lost after staying up for at least
+// stableTime, so that a receiver which accepts connections and then
+// drops them does not cause a tight reconnect loop.
+var (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+	stableTime = time.Minute
+)
+
+// dialTimeout is the maximal time a Sender waits for a connection to
+// the receiver to be established.
+var dialTimeout = 10 * time.Second
+
+// handshakeTimeout is the maximal time a Sender waits for the reply of
+// the receiver to its handshake.
+var handshakeTimeout = 10 * time.Second
+
+// errLegacy is returned by Sender.handshake when the receiver does not
+// support the handshake, and version 1 of the protocol is to be used
+// for the next connection.
+var errLegacy = errors.New("receiver does not support the handshake")
+
+// Sender forwards trace messages to a remote Receiver.  Use the Listen
+// method as the listener argument of trace.Register().
+type Sender struct {
+	network, addr string
+	capacity      int
+
+	mutex   sync.Mutex // protects the following fields
+	cond    *sync.Cond
+	stream  uint64
+	conn    net.Conn // the current connection, or nil
+	pending []*Frame
+	sending int
+	seq     uint64
+	acked   uint64 // highest sequence number acknowledged for stream
+	noAcks  bool   // the receiver does not send acknowledgements
+	dropped uint64
+	closed  bool
+	connErr error
+
+	token    string
+	compress bool
+	legacy   bool // the receiver only supports protocol version 1
+	reopened bool // the connection was closed by Reopen
+
+	stop   chan struct{}
+	worker *trace.Supervisor
+}
+
+// NewSender returns a Sender which connects to the receiver at address
+// 'addr' on the named network, for example "tcp" or "unix".  The
+// connection is established in the background.  While the receiver
+// cannot be reached, up to DefaultBufferSize messages are buffered;
+// if the buffer is full, the oldest messages are discarded.  Discarded
+// messages show up as gaps in the sequence numbers on the receiver.
+func NewSender(network, addr string) *Sender {
+	s := &Sender{
+		network:  network,
+		addr:     addr,
+		capacity: DefaultBufferSize,
+		stream:   rand.Uint64(),
+		stop:     make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	s.worker = trace.Supervise("remote sender", s.run)
+	return s
+}
+
+// Listen queues a message for sending.
+func (s *Sender) Listen(m *trace.Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	if len(s.pending) >= s.capacity {
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+	s.seq++
+	s.pending = append(s.pending, &Frame{Stream: s.stream, Seq: s.seq, Message: m})
+	s.cond.Broadcast()
+}
+
+// SetAuthToken sets the token sent to the receiver during the
+// handshake, see Receiver.SetAuthenticator().  The token is sent
+// unencrypted and is only used for connections established after the
+// call.
+func (s *Sender) SetAuthToken(token string) {
+	s.mutex.Lock()
+	s.token = token
+	s.mutex.Unlock()
+}
+
+// SetCompression determines whether gzip compression is offered to
+// the receiver during the handshake.  Compression is only used if the
+// receiver supports it, and only for connections established after the
+// call.  By default, messages are sent uncompressed.
+func (s *Sender) SetCompression(enabled bool) {
+	s.mutex.Lock()
+	s.compress = enabled
+	s.mutex.Unlock()
+}
+
+// Dropped returns the number of messages which have been discarded
+// because the buffer was full.
+func (s *Sender) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// run maintains the connection to the receiver and sends the queued
+// messages.  If sending panics, the goroutine running this function is
+// restarted by a supervisor.
+func (s *Sender) run() error {
+	backoff := minBackoff
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	for {
+		conn, err := dialer.Dial(s.network, s.addr)
+		var compression string
+		var acks *bufio.Reader
+		if err == nil {
+			compression, acks, err = s.handshake(conn)
+			if err != nil {
+				conn.Close()
+			}
+			if err == errLegacy {
+				trace.T("trace/remote", trace.PrioInfo,
+					"%s does not support the handshake, using protocol version 1",
+					s.addr)
+				continue
+			} else if err != nil {
+				trace.T("trace/remote", trace.PrioError,
+					"handshake with %s failed: %s", s.addr, err)
+			}
+		}
+		s.setConnErr(err)
+		if err != nil {
+			if !s.pause(backoff) {
+				return nil
+			}
+			backoff = min(2*backoff, maxBackoff)
+			continue
+		}
+		trace.T("trace/remote", trace.PrioInfo, "connected to %s", s.addr)
+		connected := time.Now()
+
+		s.mutex.Lock()
+		s.conn = conn
+		s.noAcks = acks == nil
+		s.mutex.Unlock()
+		if acks != nil {
+			go s.readAcks(acks)
+		}
+		err = s.send(conn, compression)
+		s.mutex.Lock()
+		s.conn = nil
+		reopened := s.reopened
+		s.reopened = false
+		s.mutex.Unlock()
+		if reopened {
+			continue
+		}
+		s.setConnErr(err)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-s.stop:
+			return nil
+		default:
+		}
+		trace.T("trace/remote", trace.PrioError,
+			"connection to %s lost: %s", s.addr, err)
+		if time.Since(connected) >= stableTime {
+			backoff = minBackoff
+		}
+		if !s.pause(backoff) {
+			return nil
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// pause waits for the given time before the next connection attempt.
+// The result is false if the Sender has been closed in the meantime.
+func (s *Sender) pause(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// handshake performs the client side of the handshake on a new
+// connection and returns the negotiated compression method.  If the
+// receiver sends acknowledgements, the reader for these is returned as
+// well, otherwise the reader is nil.  If the receiver drops the connection without a reply, it is assumed to only
+// implement protocol version 1: errLegacy is returned and no handshake
+// is attempted for later connections.  This fallback is disabled if an
+// authentication token is set.
+func (s *Sender) handshake(conn net.Conn) (string, *bufio.Reader, error) {
+	s.mutex.Lock()
+	token, compress, legacy := s.token, s.compress, s.legacy
+	s.mutex.Unlock()
+	if legacy {
+		return "none", nil, nil
+	}
+
+	clock := trace.Clock()
+	h := &hello{
+		Versions:    []int{ProtocolVersion},
+		Encodings:   supportedEncodings,
+		Compression: []string{"none"},
+		Token:       token,
+		ClockServer: clock.NTPServer,
+		ClockOffset: int64(clock.NTPOffset),
+		Acks:        true,
+	}
+	if compress {
+		h.Compression = supportedCompression
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	if err := writeHandshake(conn, h); err != nil {
+		return "", nil, err
+	}
+	w := &welcome{}
+	rd := bufio.NewReader(conn)
+	err := readHandshake(rd, w)
+	if (err == io.EOF || errors.Is(err, syscall.ECONNRESET)) && token == "" {
+		s.mutex.Lock()
+		s.legacy = true
+		s.mutex.Unlock()
+		return "", nil, errLegacy
+	} else if err == errNoHandshake {
+		return "", nil, errors.New("invalid reply from receiver")
+	} else if err != nil {
+		return "", nil, err
+	}
+	if w.Error != "" {
+		return "", nil, errors.New("rejected by receiver: " + w.Error)
+	}
+	if !w.Acks {
+		rd = nil
+	}
+	return w.Compression, rd, nil
+}
+
+// readAcks reads the acknowledgements sent by the receiver, until the
+// connection is closed.
+func (s *Sender) readAcks(rd *bufio.Reader) {
+	for {
+		a := &ack{}
+		if err := readHandshake(rd, a); err != nil {
+			return
+		}
+		s.mutex.Lock()
+		if a.Stream == s.stream && a.Seq > s.acked {
+			s.acked = a.Seq
+			s.cond.Broadcast()
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// send transmits queued messages over 'conn', until either an error
+// occurs or the Sender is closed and the queue is empty.  Messages
+// which may not have been delivered because of an error are kept in
+// the queue.  The connection is closed before send returns.
+func (s *Sender) send(conn net.Conn, compression string) error {
+	defer conn.Close()
+	var batch []*Frame
+	defer func() {
+		if r := recover(); r != nil {
+			s.requeue(batch)
+			panic(r)
+		}
+	}()
+
+	var out io.Writer = conn
+	var zw *gzip.Writer
+	if compression == "gzip" {
+		// The gzip header is sent right away, so that the receiver
+		// does not wait for it.
+		zw = gzip.NewWriter(conn)
+		if err := zw.Flush(); err != nil {
+			return err
+		}
+		out = zw
+	}
+	w := bufio.NewWriter(out)
+	flush := func() error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if zw != nil {
+			return zw.Flush()
+		}
+		return nil
+	}
+	for {
+		s.mutex.Lock()
+		for len(s.pending) == 0 && !s.closed && !s.reopened {
+			s.cond.Wait()
+		}
+		if s.reopened {
+			s.mutex.Unlock()
+			return net.ErrClosed
+		}
+		batch = s.pending
+		s.pending = nil
+		s.sending = len(batch)
+		closed := s.closed
+		s.mutex.Unlock()
+
+		if len(batch) == 0 && closed {
+			if zw != nil {
+				return zw.Close()
+			}
+			return nil
+		}
+		for _, f := range batch {
+			out := *f.Message
+			if out.Delivered.IsZero() {
+				out.Delivered = time.Now()
+			}
+			err := WriteFrame(w, &Frame{Stream: f.Stream, Seq: f.Seq, Message: &out})
+			if err == errFrameSize {
+				continue
+			} else if err != nil {
+				s.requeue(batch)
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			s.requeue(batch)
+			return err
+		}
+		batch = nil
+		s.mutex.Lock()
+		s.sending = 0
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	}
+}
+
+// requeue puts the messages in 'batch' back at the front of the queue,
+// as far as the buffer capacity permits.
+func (s *Sender) requeue(batch []*Frame) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sending = 0
+	pending := append(batch, s.pending...)
+	if excess := len(pending) - s.capacity; excess > 0 {
+		pending = pending[excess:]
+		s.dropped += uint64(excess)
+	}
+	s.pending = pending
+}
+
+func (s *Sender) setConnErr(err error) {
+	s.mutex.Lock()
+	s.connErr = err
+	s.mutex.Unlock()
+}
+
+// Reopen closes the connection to the receiver, which is then
+// re-established in the background, and chooses a new stream ID for
+// the messages received after the call.  This implements
+// trace.Reopener, see trace.ReinitAfterFork().  Messages which were
+// queued before the call are sent with the old stream ID.
+func (s *Sender) Reopen() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stream = rand.Uint64()
+	s.seq = 0
+	s.acked = 0
+	if s.conn != nil {
+		s.reopened = true
+		s.conn.Close()
+		s.cond.Broadcast()
+	}
+	return nil
+}
+
+// Open is a no-op, needed for the Sender to implement trace.Sink.  The
+// connection is established in the background.
+func (s *Sender) Open() error {
+	return nil
+}
+
+// Flush waits until all queued messages have been sent, for at most
+// FlushTimeout.  If messages are still queued after this time, an
+// error is returned.
+func (s *Sender) Flush() error {
+	deadline := time.Now().Add(FlushTimeout)
+	timer := time.AfterFunc(FlushTimeout, func() {
+		s.mutex.Lock()
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for len(s.pending)+s.sending > 0 {
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%d messages not sent to %s",
+				len(s.pending)+s.sending, s.addr)
+		}
+		s.cond.Wait()
+	}
+	return nil
+}
+
+// Verify waits until the receiver has acknowledged all messages queued
+// so far, including 'm', for at most FlushTimeout.  This implements
+// trace.Verifier, see trace.SelfTest().  If the receiver predates
+// acknowledgements, an error wrapping trace.ErrNotVerifiable is
+// returned.
+func (s *Sender) Verify(ctx context.Context, m *trace.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, FlushTimeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		s.mutex.Lock()
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	})
+	defer stop()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stream, seq := s.stream, s.seq
+	for s.acked < seq {
+		switch {
+		case s.stream != stream:
+			return errors.New("stream changed by Reopen")
+		case s.noAcks:
+			return fmt.Errorf("%s: %w", s.addr, trace.ErrNotVerifiable)
+		case ctx.Err() != nil:
+			return fmt.Errorf("%d messages not acknowledged by %s",
+				seq-s.acked, s.addr)
+		}
+		s.cond.Wait()
+	}
+	return nil
+}
+
+// HealthCheck returns an error if the most recent attempt to connect to
+// the receiver, or to send messages, has failed.
+func (s *Sender) HealthCheck() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.connErr
+}
+
+// Close sends the remaining queued messages, if the receiver can be
+// reached, and closes the connection.  Messages received after Close
+// has been called are discarded.
+func (s *Sender) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+
+	close(s.stop)
+	s.worker.Wait()
+	return nil
+}
@@ -0,0 +1,284 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// collect returns a handler which stores the received messages, and a
+// function which waits until 'n' messages have been received.
+func collect(t *testing.T) (func(string, *trace.Message), func(n int) []string) {
+	var mutex sync.Mutex
+	var msgs []string
+	handler := func(source string, m *trace.Message) {
+		mutex.Lock()
+		msgs = append(msgs, m.Msg)
+		mutex.Unlock()
+	}
+	wait := func(n int) []string {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mutex.Lock()
+			if len(msgs) >= n {
+				res := append([]string{}, msgs...)
+				mutex.Unlock()
+				return res
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("timeout waiting for %d messages", n)
+		return nil
+	}
+	return handler, wait
+}
+
+func TestSenderReceiver(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	s := NewSender("tcp", l.Addr().String())
+	s.Listen(&trace.Message{Path: "a", Msg: "1"})
+	s.Listen(&trace.Message{Path: "a", Msg: "2"})
+	if msgs := wait(2); msgs[0] != "1" || msgs[1] != "2" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+	s.Listen(&trace.Message{Path: "a", Msg: "3"})
+	s.Close()
+	wait(3)
+	s.Listen(&trace.Message{Path: "a", Msg: "ignored"})
+}
+
+func TestSenderBuffering(t *testing.T) {
+	saved := minBackoff
+	minBackoff = 10 * time.Millisecond
+	defer func() { minBackoff = saved }()
+
+	// find a free port, where nobody listens yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := NewSender("tcp", addr)
+	s.mutex.Lock()
+	s.capacity = 3
+	s.mutex.Unlock()
+	for _, msg := range []string{"1", "2", "3", "4"} {
+		s.Listen(&trace.Message{Path: "a", Msg: msg})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := s.Dropped(); n != 1 {
+		t.Errorf("expected 1 dropped message, got %d", n)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("cannot re-open port:", err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	msgs := wait(3)
+	if msgs[0] != "2" || msgs[2] != "4" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+	s.Close()
+	st := r.Streams()
+	if len(st) != 1 || st[0].Stream != s.stream || st[0].Missing != 1 ||
+		st[0].Received != 3 {
+		t.Errorf("wrong stream statistics %+v", st)
+	}
+}
+
+func TestSenderReconnectBackoff(t *testing.T) {
+	saved := minBackoff
+	minBackoff = 10 * time.Millisecond
+	defer func() { minBackoff = saved }()
+
+	// The server accepts connections and drops them right away.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+		}
+	}()
+
+	s := NewSender("tcp", l.Addr().String())
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		s.Listen(&trace.Message{Path: "a", Msg: "x"})
+		time.Sleep(time.Millisecond)
+	}
+	s.Close()
+
+	// With delays of 10ms, 20ms, 40ms, ... there is room for about
+	// six connections.
+	if n := accepted.Load(); n > 10 {
+		t.Errorf("%d connections in 300ms", n)
+	}
+}
+
+var _ trace.Verifier = (*Sender)(nil)
+
+func TestSenderVerify(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	s := NewSender("tcp", l.Addr().String())
+	defer s.Close()
+	m := &trace.Message{Path: "a", Msg: "probe"}
+	s.Listen(m)
+	if err := s.Verify(context.Background(), m); err != nil {
+		t.Error(err)
+	}
+	wait(1)
+
+	// A receiver which accepts the handshake, but never acknowledges.
+	saved := FlushTimeout
+	FlushTimeout = 50 * time.Millisecond
+	defer func() { FlushTimeout = saved }()
+	mute, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mute.Close()
+	go func() {
+		conn, err := mute.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		readHandshake(rd, &hello{})
+		writeHandshake(conn, &welcome{Version: 2, Encoding: "json", Compression: "none", Acks: true})
+		io.Copy(io.Discard, rd)
+	}()
+	s2 := NewSender("tcp", mute.Addr().String())
+	defer s2.Close()
+	s2.Listen(m)
+	err = s2.Verify(context.Background(), m)
+	if err == nil || errors.Is(err, trace.ErrNotVerifiable) {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+var _ trace.Sink = (*Sender)(nil)
+
+func TestSenderSink(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	s := NewSender("tcp", l.Addr().String())
+	handle, err := trace.Install(s, "sink", trace.PrioInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace.T("sink", trace.PrioInfo, "hello")
+	if err := s.Flush(); err != nil {
+		t.Error(err)
+	}
+	wait(1)
+	if err := s.HealthCheck(); err != nil {
+		t.Errorf("unexpected health problem %v", err)
+	}
+	handle.Unregister()
+
+	saved := FlushTimeout
+	FlushTimeout = 20 * time.Millisecond
+	defer func() { FlushTimeout = saved }()
+	dead := NewSender("tcp", "127.0.0.1:1")
+	dead.Listen(&trace.Message{Msg: "lost"})
+	if err := dead.Flush(); err == nil {
+		t.Error("missing flush error")
+	}
+	if err := dead.HealthCheck(); err == nil {
+		t.Error("missing health problem")
+	}
+	dead.Close()
+}
+
+func TestSenderReopen(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, wait := collect(t)
+	r := NewReceiver(handler)
+	go r.Serve(l)
+	defer r.Close()
+
+	s := NewSender("tcp", l.Addr().String())
+	s.Listen(&trace.Message{Path: "a", Msg: "1"})
+	wait(1)
+	if err := s.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	s.Listen(&trace.Message{Path: "a", Msg: "2"})
+	s.Close()
+	if msgs := wait(2); msgs[1] != "2" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+	st := r.Streams()
+	if len(st) != 2 || st[0].LastSeq != 1 || st[1].LastSeq != 1 {
+		t.Errorf("wrong stream statistics %+v", st)
+	}
+}
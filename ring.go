@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// RingListener keeps the most recent trace messages in memory, so that
+// they can be inspected after a problem has occurred.  Use the Listen
+// method as the listener argument of Register(), normally with
+// priority PrioAll.
+type RingListener struct {
+	mutex    sync.Mutex // protects all following fields
+	entries  []*Message
+	next     int
+	full     bool
+	dumpTo   io.Writer
+	dumpPrio Priority
+}
+
+// NewRingListener returns a new RingListener which stores the last
+// 'capacity' messages.
+func NewRingListener(capacity int) *RingListener {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingListener{
+		entries: make([]*Message, capacity),
+	}
+}
+
+// SetAutoDump arranges for the stored messages to be written to 'w'
+// every time a message of priority 'prio' or higher is received, for
+// example with prio set to PrioCritical.  If 'w' is nil, automatic
+// dumps are disabled.
+func (r *RingListener) SetAutoDump(w io.Writer, prio Priority) {
+	r.mutex.Lock()
+	r.dumpTo = w
+	r.dumpPrio = prio
+	r.mutex.Unlock()
+}
+
+// Listen stores a message in the ring buffer, overwriting the oldest
+// stored message if the buffer is full.
+func (r *RingListener) Listen(m *Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries[r.next] = m
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+	if r.dumpTo != nil && m.Prio >= r.dumpPrio {
+		r.dump(r.dumpTo)
+	}
+}
+
+// Dump writes all stored messages to 'w', oldest first.
+func (r *RingListener) Dump(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.dump(w)
+}
+
+func (r *RingListener) dump(w io.Writer) error {
+	for _, m := range r.stored(nil) {
+		_, err := fmt.Fprintf(w, "%s %s [%d]: %s\n",
+			m.Time.Format("2006-01-02 15:04:05.000"), m.Path, m.Prio, m.Msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Messages returns the stored messages which match the query 'q',
+// oldest first.  If 'q' is nil, all stored messages are returned.
+func (r *RingListener) Messages(q *Query) []*Message {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stored(q)
+}
+
+// stored returns the stored messages matching 'q', oldest first.  This
+// must be called with r.mutex held.
+func (r *RingListener) stored(q *Query) []*Message {
+	start := 0
+	n := r.next
+	if r.full {
+		start = r.next
+		n = len(r.entries)
+	}
+	var res []*Message
+	for i := 0; i < n; i++ {
+		m := r.entries[(start+i)%len(r.entries)]
+		if q.Match(m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingListener(t *testing.T) {
+	r := NewRingListener(3)
+	buf := &bytes.Buffer{}
+	r.Dump(buf)
+	if buf.Len() != 0 {
+		t.Errorf("empty ring produced output %q", buf.String())
+	}
+
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		r.Listen(&Message{Time: time.Now(), Path: "ring", Prio: PrioVerbose, Msg: msg})
+	}
+	r.Dump(buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", lines)
+	}
+	for i, msg := range []string{"c", "d", "e"} {
+		if !strings.HasSuffix(lines[i], " ring [-2000]: "+msg) {
+			t.Errorf("wrong line %d: %q", i, lines[i])
+		}
+	}
+}
+
+func TestRingAutoDump(t *testing.T) {
+	r := NewRingListener(10)
+	buf := &bytes.Buffer{}
+	r.SetAutoDump(buf, PrioCritical)
+	handle := Register(r.Listen, "ring", PrioAll)
+	T("ring", PrioDebug, "step 1")
+	T("ring", PrioError, "step 2")
+	if buf.Len() != 0 {
+		t.Errorf("dump before critical message: %q", buf.String())
+	}
+	T("ring", PrioCritical, "crash")
+	handle.Unregister()
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 3 ||
+		!strings.Contains(out, "step 1") || !strings.Contains(out, "crash") {
+		t.Errorf("wrong dump %q", out)
+	}
+}
+
+func TestRingMessages(t *testing.T) {
+	r := NewRingListener(3)
+	for _, prio := range []Priority{PrioInfo, PrioError, PrioDebug, PrioError} {
+		r.Listen(&Message{Time: time.Now(), Path: "ring", Prio: prio, Msg: prio.String()})
+	}
+	q, err := ParseQuery("prio>=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range r.Messages(q) {
+		got = append(got, m.Msg)
+	}
+	if strings.Join(got, ",") != "error,error" {
+		t.Errorf("wrong messages %q", got)
+	}
+	if n := len(r.Messages(nil)); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+}
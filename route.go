@@ -0,0 +1,71 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+// Route is a special argument for T(), which directs the message to
+// the listeners in the named group, see InGroup().  Route arguments do
+// not take part in formatting the message.  For example, the call
+//
+//	trace.T("db", trace.PrioError, "disk %s is full", dev, trace.Route("alerts"))
+//
+// delivers the message to the listeners registered for the path "db"
+// as usual, and in addition to all listeners in the group "alerts",
+// whatever their path and priority.  This can be used for messages
+// which must reach a person, independent of the configuration of
+// path-based routing.  If several Route arguments are given, the last
+// one is used.
+type Route string
+
+// InGroup is an option for Register() which adds the listener to the
+// named group.  The listener receives the messages for its path and
+// priority as usual, and in addition all messages routed to the group
+// using a Route argument.  Messages routed to the listener are not
+// subject to rate limits or quotas.
+func InGroup(name string) Option {
+	return func(c *listenerInfo) {
+		c.group = name
+	}
+}
+
+// hasRoute reports whether 'args' contains a Route argument.
+func hasRoute(args []interface{}) bool {
+	for _, arg := range args {
+		if _, ok := arg.(Route); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRoute removes the Route arguments from 'args' and returns the
+// last route given, or the empty string if there is none.  The slice
+// 'args' is not modified.
+func splitRoute(args []interface{}) (string, []interface{}) {
+	if !hasRoute(args) {
+		return "", args
+	}
+	var route string
+	rest := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args {
+		if r, ok := arg.(Route); ok {
+			route = string(r)
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return route, rest
+}
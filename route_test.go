@@ -0,0 +1,73 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	var normal, alerts []string
+	h1 := Register(func(m *Message) {
+		normal = append(normal, m.Msg)
+	}, "route", PrioInfo)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {
+		alerts = append(alerts, m.Msg+"/"+m.Route)
+	}, "route/alerts-only", PrioCritical, InGroup("alerts"))
+	defer h2.Unregister()
+
+	SetStrict(true)
+	defer SetStrict(false)
+	T("route/db", PrioInfo, "disk %s full", "sda", Route("alerts"))
+	T("route/db", PrioInfo, "disk %s ok", "sda")
+	T("other", PrioDebug, "page %s", Route("alerts"), "me")
+	T("other", PrioDebug, "no listener %s", Route("nobody"), "x")
+
+	if strings.Join(normal, ",") != "disk sda full,disk sda ok" {
+		t.Errorf("wrong normal messages %q", normal)
+	}
+	if strings.Join(alerts, ",") != "disk sda full/alerts,page me/alerts" {
+		t.Errorf("wrong routed messages %q", alerts)
+	}
+}
+
+func TestSplitRoute(t *testing.T) {
+	args := []interface{}{1, Route("a"), 2, Route("b")}
+	route, rest := splitRoute(args)
+	if route != "b" || len(rest) != 2 || rest[0] != 1 || rest[1] != 2 {
+		t.Errorf("wrong result %q %v", route, rest)
+	}
+	if len(args) != 4 || args[1] != Route("a") {
+		t.Error("arguments modified")
+	}
+}
+
+func TestRouteWithoutGroups(t *testing.T) {
+	var msgs []string
+	h := Register(func(m *Message) {
+		msgs = append(msgs, m.Msg+"/"+m.Route)
+	}, "route-nogroup", PrioInfo)
+	defer h.Unregister()
+
+	T("route-nogroup", PrioInfo, "query %d", 1, Route("nowhere"))
+
+	if strings.Join(msgs, ",") != "query 1/nowhere" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+}
@@ -0,0 +1,139 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Parameters controlling how an AdaptiveSampler reacts to load.
+var (
+	adaptInterval       = 100 * time.Millisecond
+	adaptHigh           = 0.75
+	adaptLow            = 0.25
+	adaptMaxShift int32 = 10
+)
+
+// AdaptiveSampler reduces the rate of low-priority messages passed to
+// a listener when the listener cannot keep up.  Messages of priority
+// PrioInfo and higher are always delivered.  Of the messages with lower
+// priority (e.g. PrioDebug and PrioVerbose), only one in 2^k is
+// delivered, where k is increased while the load is high and decreased
+// again once the load subsides.  Use the Listen method as the listener
+// argument of Register().
+//
+// The load is determined from the time the wrapped listener takes to
+// process a message and, optionally, from the fill level of an
+// AsyncListener queue.
+type AdaptiveSampler struct {
+	next       Listener
+	maxLatency time.Duration
+	queue      *AsyncListener
+	load       func() float64
+
+	count      atomic.Uint64
+	suppressed atomic.Uint64
+	shift      atomic.Int32
+	latency    atomic.Int64 // moving average, in nanoseconds
+	lastAdapt  atomic.Int64 // time of last adjustment, in nanoseconds
+}
+
+// NewAdaptiveSampler returns a new AdaptiveSampler which delivers
+// messages to 'next'.  The load is considered high if the average time
+// spent in 'next' approaches 'maxLatency', or if the queue of 'queue'
+// becomes close to full.  Either criterion can be disabled by passing
+// 0 for 'maxLatency' or nil for 'queue', respectively.
+func NewAdaptiveSampler(next Listener, maxLatency time.Duration, queue *AsyncListener) *AdaptiveSampler {
+	s := &AdaptiveSampler{
+		next:       next,
+		maxLatency: maxLatency,
+		queue:      queue,
+	}
+	s.load = s.currentLoad
+	s.lastAdapt.Store(time.Now().UnixNano())
+	return s
+}
+
+// Listen passes a message to the wrapped listener, subject to the
+// current sampling rate.
+func (s *AdaptiveSampler) Listen(m *Message) {
+	s.adapt()
+	if m.Prio < PrioInfo {
+		mask := uint64(1)<<uint(s.shift.Load()) - 1
+		if s.count.Add(1)&mask != 0 {
+			s.suppressed.Add(1)
+			count(m.Path, m.Prio, outSuppressed)
+			return
+		}
+	}
+
+	if s.maxLatency <= 0 {
+		s.next(m)
+		return
+	}
+	start := time.Now()
+	s.next(m)
+	d := int64(time.Since(start))
+	avg := s.latency.Load()
+	s.latency.Store(avg + (d-avg)/8)
+}
+
+// currentLoad returns the current load, where values close to 1
+// indicate that the wrapped listener is overloaded.
+func (s *AdaptiveSampler) currentLoad() float64 {
+	var load float64
+	if s.maxLatency > 0 {
+		load = float64(s.latency.Load()) / float64(s.maxLatency)
+	}
+	if s.queue != nil {
+		fill := float64(s.queue.Depth()) / float64(s.queue.Capacity())
+		if fill > load {
+			load = fill
+		}
+	}
+	return load
+}
+
+// adapt adjusts the sampling rate, at most once per adaptInterval.
+func (s *AdaptiveSampler) adapt() {
+	now := time.Now().UnixNano()
+	last := s.lastAdapt.Load()
+	if now-last < int64(adaptInterval) || !s.lastAdapt.CompareAndSwap(last, now) {
+		return
+	}
+
+	load := s.load()
+	shift := s.shift.Load()
+	if load > adaptHigh && shift < adaptMaxShift {
+		s.shift.Store(shift + 1)
+	} else if load < adaptLow && shift > 0 {
+		s.shift.Store(shift - 1)
+	}
+}
+
+// Rate returns the fraction of low-priority messages which are
+// currently delivered.
+func (s *AdaptiveSampler) Rate() float64 {
+	return 1 / float64(uint64(1)<<uint(s.shift.Load()))
+}
+
+// Suppressed returns the total number of messages which were not
+// delivered because of sampling.
+func (s *AdaptiveSampler) Suppressed() uint64 {
+	return s.suppressed.Load()
+}
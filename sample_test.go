@@ -0,0 +1,89 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveSampler(t *testing.T) {
+	saved := adaptInterval
+	adaptInterval = 0
+	defer func() { adaptInterval = saved }()
+
+	var debug, info int
+	next := func(m *Message) {
+		if m.Prio < PrioInfo {
+			debug++
+		} else {
+			info++
+		}
+	}
+	s := NewAdaptiveSampler(next, 0, nil)
+
+	load := 1.0
+	s.load = func() float64 { return load }
+	for i := 0; i < 3; i++ {
+		s.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "adapt"})
+	}
+	if r := s.Rate(); r != 0.125 {
+		t.Errorf("expected rate 1/8 under load, got %g", r)
+	}
+
+	debug, info = 0, 0
+	for i := 0; i < 3000; i++ {
+		s.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioDebug, Msg: "hello"})
+		s.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioError, Msg: "hello"})
+	}
+	if r := s.Rate(); r != 1.0/1024 {
+		t.Errorf("expected minimal rate under sustained load, got %g", r)
+	}
+	if info != 3000 {
+		t.Errorf("high-priority messages were sampled: %d of 3000 delivered", info)
+	}
+	if debug == 0 || debug > 10 {
+		t.Errorf("wrong number of debug messages delivered: %d", debug)
+	}
+	if s.Suppressed() != uint64(3000-debug) {
+		t.Errorf("wrong suppressed count %d", s.Suppressed())
+	}
+
+	load = 0
+	for i := 0; i < 20; i++ {
+		s.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "adapt"})
+	}
+	if r := s.Rate(); r != 1 {
+		t.Errorf("rate not restored after load subsided: %g", r)
+	}
+}
+
+func TestAdaptiveSamplerQueueLoad(t *testing.T) {
+	block := make(chan struct{})
+	a := NewAsyncListener(func(m *Message) {
+		<-block
+	}, 4)
+	s := NewAdaptiveSampler(a.Listen, 0, a)
+	for i := 0; i < 10; i++ {
+		a.Listen(&Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "fill"})
+	}
+	if load := s.currentLoad(); load < 0.75 {
+		t.Errorf("expected high load for full queue, got %g", load)
+	}
+	close(block)
+	a.Close()
+}
@@ -0,0 +1,227 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FieldType is the type of the values of a message field, see
+// FieldSchema.
+type FieldType string
+
+// These are the field types understood by CheckFields().
+const (
+	FieldString   FieldType = "string"
+	FieldInt      FieldType = "int"
+	FieldFloat    FieldType = "float"
+	FieldBool     FieldType = "bool"
+	FieldDuration FieldType = "duration"
+)
+
+// FieldSchema describes the fields expected in the messages for a path.
+// Fields are the key=value pairs in the message text, as written for
+// example by SlogHandler, see ParseFields().
+type FieldSchema struct {
+	// Fields gives the type of each known field.
+	Fields map[string]FieldType `json:"fields"`
+
+	// Required lists the fields which every message must contain.
+	Required []string `json:"required,omitempty"`
+
+	// AllowOther indicates that fields not listed in Fields are
+	// permitted.
+	AllowOther bool `json:"allow_other,omitempty"`
+}
+
+var (
+	schemaMutex sync.RWMutex // protects schemas
+	schemas     = map[string]*FieldSchema{}
+)
+
+// SetSchema declares the fields expected in messages for the path
+// 'prefix' and its sub-paths.  If several schemas apply to a message,
+// the one with the longest prefix is used.  Setting the schema to nil
+// removes the declaration for the prefix.  In strict mode (see
+// SetStrict()), every message delivered to a listener is checked
+// against its schema, and mismatches are reported in messages for the
+// path "trace", with the priority half-way between PrioInfo and
+// PrioError.  Messages for "trace" and its sub-paths are never
+// checked.
+func SetSchema(prefix string, schema *FieldSchema) {
+	schemaMutex.Lock()
+	defer schemaMutex.Unlock()
+	if schema == nil {
+		delete(schemas, prefix)
+	} else {
+		schemas[prefix] = schema
+	}
+}
+
+// findSchema returns the schema with the longest prefix matching 'path',
+// or nil if there is none.
+func findSchema(path string) *FieldSchema {
+	schemaMutex.RLock()
+	defer schemaMutex.RUnlock()
+	for prefix := path; ; {
+		if s := schemas[prefix]; s != nil {
+			return s
+		}
+		if prefix == "" {
+			return nil
+		}
+		i := strings.LastIndexByte(prefix, '/')
+		if i < 0 {
+			prefix = ""
+		} else {
+			prefix = prefix[:i]
+		}
+	}
+}
+
+// ParseFields extracts the fields from a message text.  Fields are the
+// words of the form key=value, where the value may be a quoted Go
+// string; all other words are ignored.  If a key occurs more than once,
+// the last value is used.
+func ParseFields(msg string) map[string]string {
+	fields := map[string]string{}
+	for _, f := range ParseFieldList(msg) {
+		fields[f.Key] = f.Value
+	}
+	return fields
+}
+
+// Field is a key=value field of a message text, see ParseFieldList().
+type Field struct {
+	Key, Value string
+}
+
+// ParseFieldList extracts the fields from a message text, like
+// ParseFields(), but returns them in the order in which they occur in
+// the text.  Keys which occur more than once are included every time.
+func ParseFieldList(msg string) []Field {
+	var fields []Field
+	for rest := msg; rest != ""; {
+		rest = strings.TrimLeft(rest, " \t\n")
+		end := strings.IndexAny(rest, " \t\n")
+		if end < 0 {
+			end = len(rest)
+		}
+		eq := strings.IndexByte(rest[:end], '=')
+		if eq <= 0 {
+			rest = rest[end:]
+			continue
+		}
+		key := rest[:eq]
+		value := rest[eq+1 : end]
+		if strings.HasPrefix(rest[eq+1:], `"`) {
+			if quoted, err := strconv.QuotedPrefix(rest[eq+1:]); err == nil {
+				value, _ = strconv.Unquote(quoted)
+				end = eq + 1 + len(quoted)
+			}
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+		rest = rest[end:]
+	}
+	return fields
+}
+
+// CheckFields checks the fields of the message text 'msg' against the
+// schema for 'path', see SetSchema().  The returned error lists all
+// problems found.  If no schema applies, nil is returned.
+func CheckFields(path, msg string) error {
+	schema := findSchema(path)
+	if schema == nil {
+		return nil
+	}
+	return schema.Check(msg)
+}
+
+// Check checks the fields of the message text 'msg' against the schema.
+// The returned error lists all problems found.
+func (schema *FieldSchema) Check(msg string) error {
+	fields := ParseFields(msg)
+	var problems []string
+	for _, key := range schema.Required {
+		if _, ok := fields[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing field %q", key))
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		tp, ok := schema.Fields[key]
+		if !ok {
+			if !schema.AllowOther {
+				problems = append(problems, fmt.Sprintf("unknown field %q", key))
+			}
+			continue
+		}
+		if !tp.valid(fields[key]) {
+			problems = append(problems,
+				fmt.Sprintf("field %q has value %q, expected %s", key, fields[key], tp))
+		}
+	}
+	if problems == nil {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// valid reports whether 'value' is a valid value of type 'tp'.  Unknown
+// types accept all values.
+func (tp FieldType) valid(value string) bool {
+	var err error
+	switch tp {
+	case FieldInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case FieldFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case FieldBool:
+		_, err = strconv.ParseBool(value)
+	case FieldDuration:
+		_, err = time.ParseDuration(value)
+	}
+	return err == nil
+}
+
+// prioSchemaWarning is the priority of the reports about schema
+// violations.  A mismatch deserves attention, but is not an error of
+// the program, so the priority half-way between PrioInfo and PrioError
+// is used, which is the priority of slog.LevelWarn (see
+// SlogPriority()).
+const prioSchemaWarning = (PrioInfo + PrioError) / 2
+
+// checkSchema reports schema violations of 'm' in strict mode.
+func checkSchema(m *Message) {
+	if m.Path == "trace" || strings.HasPrefix(m.Path, "trace/") {
+		return
+	}
+	if err := CheckFields(m.Path, m.Msg); err != nil {
+		T("trace", prioSchemaWarning,
+			"message for %q does not match schema: %s", m.Path, err)
+	}
+}
@@ -0,0 +1,110 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	for _, test := range []struct {
+		msg      string
+		expected map[string]string
+	}{
+		{"request done status=200 took=5ms", map[string]string{"status": "200", "took": "5ms"}},
+		{`user="John Smith" ok=true`, map[string]string{"user": "John Smith", "ok": "true"}},
+		{"a=1 a=2 =3 b=", map[string]string{"a": "2", "b": ""}},
+		{"no fields here", map[string]string{}},
+	} {
+		fields := ParseFields(test.msg)
+		if !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.msg, test.expected, fields)
+		}
+	}
+}
+
+func TestParseFieldList(t *testing.T) {
+	fields := ParseFieldList(`b=1 a="x y" b=2`)
+	expected := []Field{{"b", "1"}, {"a", "x y"}, {"b", "2"}}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	SetSchema("http", &FieldSchema{
+		Fields: map[string]FieldType{
+			"status": FieldInt,
+			"took":   FieldDuration,
+			"path":   FieldString,
+		},
+		Required: []string{"status"},
+	})
+	SetSchema("http/admin", &FieldSchema{AllowOther: true})
+	defer SetSchema("http", nil)
+	defer SetSchema("http/admin", nil)
+
+	for _, test := range []struct {
+		path, msg string
+		problems  []string
+	}{
+		{"http", "done status=200 took=5ms", nil},
+		{"http/api", "done took=fast", []string{`missing field "status"`,
+			`field "took" has value "fast", expected duration`}},
+		{"http", "status=200 user=jochen", []string{`unknown field "user"`}},
+		{"http/admin", "user=jochen", nil},
+		{"db", "anything=goes", nil},
+	} {
+		err := CheckFields(test.path, test.msg)
+		if test.problems == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error %s", test.msg, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != strings.Join(test.problems, "; ") {
+			t.Errorf("%q: wrong error %v", test.msg, err)
+		}
+	}
+}
+
+func TestStrictSchema(t *testing.T) {
+	SetSchema("schema", &FieldSchema{Fields: map[string]FieldType{"n": FieldInt}})
+	defer SetSchema("schema", nil)
+	SetStrict(true)
+	defer SetStrict(false)
+
+	var warnings []string
+	h1 := Register(func(m *Message) {
+		if m.Prio != (PrioInfo+PrioError)/2 {
+			t.Errorf("wrong priority %d", m.Prio)
+		}
+		warnings = append(warnings, m.Msg)
+	}, "trace", PrioInfo)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {}, "schema", PrioInfo)
+	defer h2.Unregister()
+
+	T("schema", PrioInfo, "n=%d", 1)
+	T("schema", PrioInfo, "n=%s", "one")
+	if len(warnings) != 1 ||
+		!strings.Contains(warnings[0], `field "n" has value "one", expected int`) {
+		t.Errorf("wrong warnings %q", warnings)
+	}
+}
@@ -0,0 +1,101 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package security emits trace messages for security relevant events in
+// a consistent format.
+//
+// Each event type has its own path below "security/" and a fixed set
+// of fields.  The message text consists of the event name followed by
+// the fields, in the form
+//
+//	event=auth_failure user="alice" source="192.0.2.7" reason="bad password"
+//
+// where all field values are quoted as Go string literals.  The
+// function Parse() can be used to decode such messages again, and
+// ingestion rules in a SIEM system can rely on this format.
+// Alternatively, Device.CEF() and Device.LEEF() convert messages into
+// the record formats used by ArcSight and QRadar, for example:
+//
+//	d := &security.Device{Vendor: "Example", Product: "server", Version: "1.0"}
+//	trace.Register(security.Writer(f, d.CEF), "security", trace.PrioAll)
+package security
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/seehuhn/trace"
+)
+
+// Paths used for the different event types.
+const (
+	PathAuth      = "security/auth"
+	PathPrivilege = "security/privilege"
+	PathConfig    = "security/config"
+)
+
+// AuthFailure reports a failed authentication attempt for 'user' from
+// the network address or other origin 'source'.
+func AuthFailure(user, source, reason string) {
+	trace.T(PathAuth, trace.PrioError,
+		"event=auth_failure user=%q source=%q reason=%q",
+		user, source, reason)
+}
+
+// PrivilegeChange reports that 'actor' has changed the privileges of
+// 'subject' from 'from' to 'to'.
+func PrivilegeChange(actor, subject, from, to string) {
+	trace.T(PathPrivilege, trace.PrioInfo,
+		"event=privilege_change actor=%q subject=%q from=%q to=%q",
+		actor, subject, from, to)
+}
+
+// ConfigChange reports that 'actor' has changed the configuration
+// setting 'setting' from value 'from' to value 'to'.
+func ConfigChange(actor, setting, from, to string) {
+	trace.T(PathConfig, trace.PrioInfo,
+		"event=config_change actor=%q setting=%q from=%q to=%q",
+		actor, setting, from, to)
+}
+
+var errSyntax = errors.New("malformed security event")
+
+// Parse decodes the text of a message emitted by this package.  It
+// returns the event name, for example "auth_failure", and the values
+// of the remaining fields.
+func Parse(msg string) (string, map[string]string, error) {
+	event, rest, ok := strings.Cut(msg, " ")
+	if !ok || !strings.HasPrefix(event, "event=") {
+		return "", nil, errSyntax
+	}
+	event = strings.TrimPrefix(event, "event=")
+
+	fields := map[string]string{}
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok || key == "" {
+			return "", nil, errSyntax
+		}
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return "", nil, errSyntax
+		}
+		fields[key], _ = strconv.Unquote(quoted)
+		rest = strings.TrimPrefix(value[len(quoted):], " ")
+	}
+	return event, fields, nil
+}
@@ -0,0 +1,77 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package security
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/tracetest"
+)
+
+func TestEvents(t *testing.T) {
+	rec := tracetest.Install(t, "security", trace.PrioAll)
+	AuthFailure("alice", "192.0.2.7", `bad "password"`)
+	PrivilegeChange("root", "bob", "user", "admin")
+	ConfigChange("bob", "log level", "", "debug")
+
+	testData := []struct {
+		path   string
+		event  string
+		fields map[string]string
+	}{
+		{PathAuth, "auth_failure", map[string]string{
+			"user": "alice", "source": "192.0.2.7", "reason": `bad "password"`}},
+		{PathPrivilege, "privilege_change", map[string]string{
+			"actor": "root", "subject": "bob", "from": "user", "to": "admin"}},
+		{PathConfig, "config_change", map[string]string{
+			"actor": "bob", "setting": "log level", "from": "", "to": "debug"}},
+	}
+	msgs := rec.Messages()
+	if len(msgs) != len(testData) {
+		t.Fatalf("expected %d messages, got %d", len(testData), len(msgs))
+	}
+	for i, test := range testData {
+		m := msgs[i]
+		if m.Path != test.path {
+			t.Errorf("%d: expected path %q, got %q", i, test.path, m.Path)
+		}
+		event, fields, err := Parse(m.Msg)
+		if err != nil {
+			t.Errorf("%q: %s", m.Msg, err)
+			continue
+		}
+		if event != test.event || !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%q: wrong result %q %q", m.Msg, event, fields)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, msg := range []string{
+		"",
+		"hello world",
+		`event=x user=alice`,
+		`event=x user="alice`,
+		`event=x ="alice"`,
+	} {
+		if _, _, err := Parse(msg); err == nil {
+			t.Errorf("%q: missing error", msg)
+		}
+	}
+}
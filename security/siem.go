@@ -0,0 +1,201 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package security
+
+import (
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/seehuhn/trace"
+)
+
+// Device identifies the product emitting CEF and LEEF records.
+type Device struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+// cefKeys maps the fields of the security events to CEF extension keys.
+// Fields not listed here are stored in custom string extensions.
+var cefKeys = map[string]string{
+	"user":    "duser",
+	"subject": "duser",
+	"actor":   "suser",
+	"reason":  "reason",
+}
+
+// leefKeys maps the fields of the security events to LEEF attributes.
+// Fields not listed here are stored under their own name.
+var leefKeys = map[string]string{
+	"user":    "usrName",
+	"subject": "usrName",
+	"actor":   "suser",
+}
+
+// decode returns the event name and fields of a message.  For messages
+// not emitted by this package, the event name is the message path and
+// there are no fields.
+func decode(m *trace.Message) (string, map[string]string) {
+	if strings.HasPrefix(m.Path, "security/") {
+		if event, fields, err := Parse(m.Msg); err == nil {
+			return event, fields
+		}
+	}
+	return m.Path, nil
+}
+
+// sortedKeys returns the keys of 'fields' in sorted order.
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// severity maps a message priority to the 0-10 severity scale used by
+// CEF and LEEF.
+func severity(prio trace.Priority) int {
+	switch {
+	case prio >= trace.PrioCritical:
+		return 10
+	case prio >= trace.PrioError:
+		return 7
+	case prio >= trace.PrioInfo:
+		return 3
+	case prio >= trace.PrioDebug:
+		return 1
+	default:
+		return 0
+	}
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefEscaper      = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`, `|`, `\|`)
+)
+
+// CEF formats a message as an ArcSight Common Event Format record.  For
+// events emitted by this package, the event name is used as the
+// signature ID and the fields are stored in the extension; the source
+// field becomes src if it is an IP address, and shost otherwise.  For
+// other messages, the message path is used as the signature ID.
+func (d *Device) CEF(m *trace.Message) string {
+	event, fields := decode(m)
+	b := &strings.Builder{}
+	b.WriteString("CEF:0|")
+	for _, s := range []string{d.Vendor, d.Product, d.Version, event, event} {
+		b.WriteString(cefHeaderEscaper.Replace(s))
+		b.WriteByte('|')
+	}
+	b.WriteString(strconv.Itoa(severity(m.Prio)))
+	b.WriteByte('|')
+
+	first := true
+	ext := func(key, value string) {
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(value))
+	}
+	ext("rt", strconv.FormatInt(m.Time.UnixMilli(), 10))
+	ext("cs1Label", "path")
+	ext("cs1", m.Path)
+	custom := 2
+	for _, name := range sortedKeys(fields) {
+		value := fields[name]
+		if key, ok := cefKeys[name]; ok {
+			ext(key, value)
+		} else if name == "source" {
+			if net.ParseIP(value) != nil {
+				ext("src", value)
+			} else {
+				ext("shost", value)
+			}
+		} else if custom <= 6 {
+			n := strconv.Itoa(custom)
+			ext("cs"+n+"Label", name)
+			ext("cs"+n, value)
+			custom++
+		}
+	}
+	ext("msg", m.Msg)
+	return b.String()
+}
+
+// LEEF formats a message as an IBM QRadar Log Event Extended Format
+// (version 1.0) record, with tab separated attributes.  As for CEF(),
+// the event name of security events, or the path of other messages,
+// is used as the event ID.
+func (d *Device) LEEF(m *trace.Message) string {
+	event, fields := decode(m)
+	b := &strings.Builder{}
+	b.WriteString("LEEF:1.0|")
+	for _, s := range []string{d.Vendor, d.Product, d.Version, event} {
+		b.WriteString(leefEscaper.Replace(s))
+		b.WriteByte('|')
+	}
+
+	first := true
+	attr := func(key, value string) {
+		if !first {
+			b.WriteByte('\t')
+		}
+		first = false
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(leefEscaper.Replace(value))
+	}
+	attr("devTime", m.Time.UTC().Format("Jan 02 2006 15:04:05.000 UTC"))
+	attr("sev", strconv.Itoa(severity(m.Prio)))
+	attr("cat", m.Path)
+	for _, name := range sortedKeys(fields) {
+		value := fields[name]
+		if key, ok := leefKeys[name]; ok {
+			attr(key, value)
+		} else if name == "source" && net.ParseIP(value) != nil {
+			attr("src", value)
+		} else {
+			attr(name, value)
+		}
+	}
+	attr("msg", m.Msg)
+	return b.String()
+}
+
+// Writer returns a listener which writes every message to 'w', as a
+// record formatted by 'format' on a line of its own.  For example,
+// Writer(w, d.CEF) writes CEF records.  Write errors are ignored.
+func Writer(w io.Writer, format func(*trace.Message) string) trace.Listener {
+	var mutex sync.Mutex
+	return func(m *trace.Message) {
+		line := format(m) + "\n"
+		mutex.Lock()
+		io.WriteString(w, line)
+		mutex.Unlock()
+	}
+}
@@ -0,0 +1,89 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package security
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+var device = &Device{Vendor: "Example", Product: "Server|X", Version: "1.0"}
+
+func TestCEF(t *testing.T) {
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	testData := []struct {
+		m        *trace.Message
+		expected string
+	}{
+		{
+			&trace.Message{Time: when, Path: PathAuth, Prio: trace.PrioError,
+				Msg: `event=auth_failure user="alice" source="192.0.2.7" reason="a=b"`},
+			`CEF:0|Example|Server\|X|1.0|auth_failure|auth_failure|7|` +
+				`rt=1367411400000 cs1Label=path cs1=security/auth reason=a\=b ` +
+				`src=192.0.2.7 duser=alice ` +
+				`msg=event\=auth_failure user\="alice" source\="192.0.2.7" reason\="a\=b"`,
+		},
+		{
+			&trace.Message{Time: when, Path: PathConfig, Prio: trace.PrioInfo,
+				Msg: `event=config_change actor="bob" setting="mode" from="a" to="b"`},
+			`CEF:0|Example|Server\|X|1.0|config_change|config_change|3|` +
+				`rt=1367411400000 cs1Label=path cs1=security/config suser=bob ` +
+				`cs2Label=from cs2=a cs3Label=setting cs3=mode cs4Label=to cs4=b ` +
+				`msg=event\=config_change actor\="bob" setting\="mode" from\="a" to\="b"`,
+		},
+		{
+			&trace.Message{Time: when, Path: "db", Prio: trace.PrioCritical,
+				Msg: "two\nlines"},
+			`CEF:0|Example|Server\|X|1.0|db|db|10|` +
+				`rt=1367411400000 cs1Label=path cs1=db msg=two\nlines`,
+		},
+	}
+	for _, test := range testData {
+		if s := device.CEF(test.m); s != test.expected {
+			t.Errorf("wrong CEF record:\n  %s\nexpected:\n  %s", s, test.expected)
+		}
+	}
+}
+
+func TestLEEF(t *testing.T) {
+	m := &trace.Message{
+		Time: time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC),
+		Path: PathAuth,
+		Prio: trace.PrioError,
+		Msg:  `event=auth_failure user="alice" source="host.example" reason="x"`,
+	}
+	expected := "LEEF:1.0|Example|Server\\|X|1.0|auth_failure|" +
+		"devTime=May 01 2013 12:30:00.000 UTC\tsev=7\tcat=security/auth\t" +
+		"reason=x\tsource=host.example\tusrName=alice\tmsg=" + m.Msg
+	if s := device.LEEF(m); s != expected {
+		t.Errorf("wrong LEEF record:\n  %q\nexpected:\n  %q", s, expected)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	listen := Writer(buf, device.CEF)
+	listen(&trace.Message{Path: "a", Msg: "1"})
+	listen(&trace.Message{Path: "b", Msg: "2"})
+	if n := bytes.Count(buf.Bytes(), []byte("\nCEF:0|")); n != 1 ||
+		!bytes.HasPrefix(buf.Bytes(), []byte("CEF:0|")) {
+		t.Errorf("wrong output %q", buf.String())
+	}
+}
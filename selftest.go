@@ -0,0 +1,135 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Verifier is implemented by sinks which can check that a message has
+// reached its destination, for example by reading back a file or by
+// waiting for an acknowledgement from a collector.  If the destination
+// cannot confirm delivery, for example because a collector is too old,
+// Verify returns an error wrapping ErrNotVerifiable.
+type Verifier interface {
+	Verify(ctx context.Context, m *Message) error
+}
+
+// ErrNotVerifiable is returned by Verifier implementations if the
+// delivery of a message cannot be confirmed.
+var ErrNotVerifiable = errors.New("delivery cannot be verified")
+
+// SelfTestResult is the outcome of the self-test for one installed
+// sink, see SelfTest().
+type SelfTestResult struct {
+	// Handle is the handle returned by Install(), Path is the path the
+	// sink was installed for, and Sink gives the type of the sink.
+	Handle ListenerHandle `json:"handle"`
+	Path   string         `json:"path"`
+	Sink   string         `json:"sink"`
+
+	// Verified is true if the sink has confirmed that the probe
+	// message reached its destination.  Sinks which do not implement
+	// Verifier, or which report ErrNotVerifiable, pass the test without
+	// Verified being set if the probe message could be written and
+	// flushed; for these sinks, the self-test cannot tell whether the
+	// probe has actually arrived.
+	Verified bool `json:"verified"`
+
+	// Error describes the reason for a failed test.  The field is
+	// empty if the test passed.
+	Error string `json:"error,omitempty"`
+}
+
+// Passed reports whether the self-test of the sink was successful.
+func (r *SelfTestResult) Passed() bool {
+	return r.Error == ""
+}
+
+// SelfTest checks all sinks installed using Install(), for example as
+// a smoke test after deploying a program.  For every sink, the health
+// check is run, a probe message with path "trace/selftest" is passed
+// directly to the sink's Listen method and the sink is flushed.  If
+// the sink implements Verifier, it is then asked to confirm the
+// delivery of the probe.  The results are returned in the order in
+// which the sinks were installed.  If 'ctx' is cancelled, the
+// remaining sinks fail with the context's error.
+func SelfTest(ctx context.Context) []SelfTestResult {
+	sinks := installedSinks()
+	handles := make([]ListenerHandle, 0, len(sinks))
+	for handle := range sinks {
+		handles = append(handles, handle)
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+
+	res := make([]SelfTestResult, 0, len(handles))
+	for _, handle := range handles {
+		s := sinks[handle]
+		r := SelfTestResult{
+			Handle: handle,
+			Path:   s.path,
+			Sink:   fmt.Sprintf("%T", s.sink),
+		}
+		verified, err := selfTestSink(ctx, s.sink)
+		r.Verified = verified
+		if err != nil {
+			r.Error = err.Error()
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// selfTestSink runs the self-test for a single sink.
+func selfTestSink(ctx context.Context, sink Sink) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if err := sink.HealthCheck(); err != nil {
+		return false, fmt.Errorf("health check failed: %w", err)
+	}
+
+	var id [8]byte
+	rand.Read(id[:])
+	probe := &Message{
+		Time: time.Now(),
+		Path: "trace/selftest",
+		Prio: PrioInfo,
+		Msg:  "self-test probe " + hex.EncodeToString(id[:]),
+	}
+	sink.Listen(probe)
+	if err := sink.Flush(); err != nil {
+		return false, fmt.Errorf("flush failed: %w", err)
+	}
+
+	v, ok := sink.(Verifier)
+	if !ok {
+		return false, nil
+	}
+	if err := v.Verify(ctx, probe); errors.Is(err, ErrNotVerifiable) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("verification failed: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	file := NewFileSink(filepath.Join(t.TempDir(), "run.jsonl"))
+	broken := &testSink{}
+	plain := &testSink{}
+	var handles []ListenerHandle
+	for _, sink := range []Sink{file, broken, plain} {
+		handle, err := Install(sink, "selftest", PrioInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handles = append(handles, handle)
+	}
+	defer func() {
+		for _, handle := range handles {
+			handle.Unregister()
+		}
+	}()
+	broken.health = errors.New("server down")
+
+	results := SelfTest(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %v", results)
+	}
+	expected := []struct {
+		passed, verified bool
+	}{
+		{true, true},
+		{false, false},
+		{true, false},
+	}
+	for i, r := range results {
+		if r.Handle != handles[i] {
+			t.Errorf("%d: wrong handle %d", i, r.Handle)
+		}
+		if r.Passed() != expected[i].passed || r.Verified != expected[i].verified {
+			t.Errorf("%d: unexpected result %v", i, r)
+		}
+	}
+	if results[0].Sink != "*trace.FileSink" {
+		t.Errorf("wrong sink type %q", results[0].Sink)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range SelfTest(ctx) {
+		if r.Passed() {
+			t.Error("self-test passed with cancelled context")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Sink is the interface implemented by message destinations which own
+// resources, like network connections or files.  Sinks are installed
+// using Install(), and their lifecycle is managed by the trace
+// package: Open is called when the sink is installed, HealthCheck is
+// called periodically while the sink is installed, and Flush and Close
+// are called by Shutdown() or when the sink is unregistered.
+type Sink interface {
+	// Open prepares the sink for receiving messages.
+	Open() error
+
+	// Listen receives a single message, like a Listener.
+	Listen(m *Message)
+
+	// Flush writes out all buffered messages.
+	Flush() error
+
+	// Close flushes and releases all resources of the sink.
+	Close() error
+
+	// HealthCheck returns an error if the sink cannot currently
+	// deliver messages, for example because a remote server is not
+	// reachable.
+	HealthCheck() error
+}
+
+// HealthCheckInterval is the time between health checks of installed
+// sinks.
+var HealthCheckInterval = 30 * time.Second
+
+// sinkState is the listener state of an installed sink.
+type sinkState struct {
+	sink Sink
+	path string
+
+	mutex  sync.Mutex // protects health
+	health error
+}
+
+func (s *sinkState) Flush() error { return s.sink.Flush() }
+func (s *sinkState) Close() error { return s.sink.Close() }
+
+func listenSink(s *sinkState, m *Message) {
+	s.sink.Listen(m)
+}
+
+var healthOnce sync.Once
+
+// Install opens 'sink' and registers it as a listener for the given
+// path and priority, see Register() for the meaning of the arguments.
+// When the returned handle is unregistered, the sink is closed.  If
+// Open fails, the error is returned and the sink is not installed.
+func Install(sink Sink, path string, prio Priority, opts ...Option) (ListenerHandle, error) {
+	if err := sink.Open(); err != nil {
+		return 0, err
+	}
+	s := &sinkState{sink: sink, path: path}
+	handle := RegisterWith(listenSink, s, path, prio, opts...)
+	healthOnce.Do(func() {
+		go func() {
+			for {
+				time.Sleep(HealthCheckInterval)
+				CheckSinks()
+			}
+		}()
+	})
+	return handle, nil
+}
+
+// installedSinks returns the states of all installed sinks, by handle.
+func installedSinks() map[ListenerHandle]*sinkState {
+	listenerMutex.Lock()
+	defer listenerMutex.Unlock()
+	res := map[ListenerHandle]*sinkState{}
+	for handle, c := range listeners {
+		if s, ok := c.state.(*sinkState); ok {
+			res[handle] = s
+		}
+	}
+	return res
+}
+
+// CheckSinks runs the health checks of all installed sinks now,
+// instead of waiting for the next periodic check.  When a sink becomes
+// unhealthy, or healthy again, a message is emitted for the path
+// "trace".
+func CheckSinks() {
+	for _, s := range installedSinks() {
+		err := s.sink.HealthCheck()
+		s.mutex.Lock()
+		old := s.health
+		s.health = err
+		s.mutex.Unlock()
+
+		if err != nil && old == nil {
+			T("trace", PrioError, "sink for path %q is unhealthy: %s", s.path, err)
+		} else if err == nil && old != nil {
+			T("trace", PrioInfo, "sink for path %q has recovered", s.path)
+		}
+	}
+}
+
+// SinkHealth returns the result of the most recent health check for the
+// sink with the given handle.  For handles which do not belong to an
+// installed sink, an error is returned.
+func SinkHealth(handle ListenerHandle) error {
+	s := installedSinks()[handle]
+	if s == nil {
+		return errors.New("no sink installed for this handle")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.health
+}
+
+// Shutdown flushes and unregisters all installed sinks, for example
+// before the program exits.  The combined errors of the Flush methods
+// are returned.  The sinks are closed once all concurrent calls to
+// their Listen methods have returned.
+func Shutdown() error {
+	var errs []error
+	for handle, s := range installedSinks() {
+		if err := s.sink.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+		handle.Unregister()
+	}
+	return errors.Join(errs...)
+}
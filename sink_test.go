@@ -0,0 +1,87 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testSink struct {
+	openErr error
+	health  error
+	events  []string
+}
+
+func (s *testSink) Open() error {
+	s.events = append(s.events, "open")
+	return s.openErr
+}
+func (s *testSink) Listen(m *Message)  { s.events = append(s.events, m.Msg) }
+func (s *testSink) Flush() error       { s.events = append(s.events, "flush"); return nil }
+func (s *testSink) Close() error       { s.events = append(s.events, "close"); return nil }
+func (s *testSink) HealthCheck() error { return s.health }
+
+func TestInstall(t *testing.T) {
+	sink := &testSink{}
+	handle, err := Install(sink, "sink", PrioInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	h2 := Register(func(m *Message) {
+		msgs = append(msgs, m.Msg)
+	}, "trace", PrioAll)
+	T("sink", PrioInfo, "hello")
+	sink.health = errors.New("server down")
+	CheckSinks()
+	CheckSinks()
+	if err := SinkHealth(handle); err != sink.health {
+		t.Errorf("wrong health %v", err)
+	}
+	sink.health = nil
+	CheckSinks()
+	h2.Unregister()
+
+	if err := Shutdown(); err != nil {
+		t.Error(err)
+	}
+	T("sink", PrioInfo, "ignored")
+	if s := strings.Join(sink.events, " "); s != "open hello flush close" {
+		t.Errorf("wrong events %q", s)
+	}
+	if len(msgs) != 2 || !strings.Contains(msgs[0], "server down") ||
+		!strings.Contains(msgs[1], "recovered") {
+		t.Errorf("wrong health messages %q", msgs)
+	}
+	if SinkHealth(handle) == nil {
+		t.Error("missing error for removed sink")
+	}
+}
+
+func TestInstallOpenError(t *testing.T) {
+	sink := &testSink{openErr: errors.New("no such file")}
+	if _, err := Install(sink, "sink", PrioInfo); err != sink.openErr {
+		t.Errorf("expected open error, got %v", err)
+	}
+	T("sink", PrioInfo, "ignored")
+	if len(sink.events) != 1 {
+		t.Errorf("wrong events %q", sink.events)
+	}
+}
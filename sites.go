@@ -0,0 +1,119 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// SiteStat gives the number of messages, and the total size of the
+// message texts in bytes, emitted by one call site.
+type SiteStat struct {
+	Path  string `json:"path"`
+	Site  string `json:"site"`
+	Count int64  `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// SiteCounter collects message statistics by call site, to find the
+// program locations which produce the most trace messages.  Use the
+// Listen method as the listener argument of Register().
+//
+// Call sites are identified by file name and line number if caller
+// information is available (see CaptureCaller()), and by the format
+// string passed to T() otherwise.  Messages which contain neither, for
+// example messages received from a foreign program, are grouped by
+// path only.
+type SiteCounter struct {
+	mutex sync.Mutex
+	sites map[SiteStat]*SiteStat
+}
+
+// NewSiteCounter allocates a new, empty SiteCounter.
+func NewSiteCounter() *SiteCounter {
+	return &SiteCounter{
+		sites: map[SiteStat]*SiteStat{},
+	}
+}
+
+// CallSite returns a string which identifies the origin of a message
+// within its path: "file:line" if caller information is available, the
+// quoted format string otherwise, or the empty string if neither is
+// known.
+func (m *Message) CallSite() string {
+	if m.File != "" {
+		return m.File + ":" + strconv.Itoa(m.Line)
+	}
+	if m.Format != "" {
+		return strconv.Quote(m.Format)
+	}
+	return ""
+}
+
+// Listen records a single trace message.
+func (c *SiteCounter) Listen(m *Message) {
+	key := SiteStat{Path: m.Path, Site: m.CallSite()}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	stat := c.sites[key]
+	if stat == nil {
+		stat = &SiteStat{Path: key.Path, Site: key.Site}
+		c.sites[key] = stat
+	}
+	stat.Count++
+	stat.Bytes += int64(len(m.Msg))
+}
+
+// Top returns the statistics for the 'n' call sites which emitted the
+// most messages, in order of decreasing message count.  If 'n' is
+// negative, all call sites are returned.
+func (c *SiteCounter) Top(n int) []SiteStat {
+	c.mutex.Lock()
+	res := make([]SiteStat, 0, len(c.sites))
+	for _, stat := range c.sites {
+		res = append(res, *stat)
+	}
+	c.mutex.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := &res[i], &res[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Site < b.Site
+	})
+	if n >= 0 && n < len(res) {
+		res = res[:n]
+	}
+	return res
+}
+
+// Reset discards all statistics collected so far.
+func (c *SiteCounter) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sites = map[SiteStat]*SiteStat{}
+}
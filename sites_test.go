@@ -0,0 +1,52 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSiteCounter(t *testing.T) {
+	c := NewSiteCounter()
+	handle := Register(c.Listen, "sites", PrioAll)
+	for i := 0; i < 3; i++ {
+		T("sites/a", PrioDebug, "loop %d", i)
+	}
+	T("sites/a", PrioInfo, "hello")
+	T("sites/b", PrioInfo, "hello, world")
+	handle.Unregister()
+	c.Listen(&Message{Path: "sites/c", Msg: "abc", File: "x.go", Line: 7})
+
+	expected := []SiteStat{
+		{Path: "sites/a", Site: `"loop %d"`, Count: 3, Bytes: 18},
+		{Path: "sites/b", Site: `"hello, world"`, Count: 1, Bytes: 12},
+		{Path: "sites/a", Site: `"hello"`, Count: 1, Bytes: 5},
+	}
+	if top := c.Top(3); !reflect.DeepEqual(top, expected) {
+		t.Errorf("wrong statistics %v", top)
+	}
+	top := c.Top(-1)
+	if len(top) != 4 || top[3].Site != "x.go:7" {
+		t.Errorf("wrong statistics %v", top)
+	}
+
+	c.Reset()
+	if top := c.Top(-1); len(top) != 0 {
+		t.Errorf("Reset failed: %v", top)
+	}
+}
@@ -0,0 +1,185 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// SlogPriority converts a slog level into a message priority.  The
+// levels slog.LevelDebug, slog.LevelInfo and slog.LevelError map to
+// PrioDebug, PrioInfo and PrioError, respectively; other levels are
+// interpolated linearly, so that for example slog.LevelWarn maps to
+// the priority half-way between PrioInfo and PrioError.  Levels which
+// are out of the range of priorities are clamped to PrioAll and
+// PrioMax.
+func SlogPriority(level slog.Level) Priority {
+	switch {
+	case level >= slog.LevelError:
+		return PrioError.Add(int(scalePriority(int64(level-slog.LevelError), 250)))
+	case level >= slog.LevelInfo:
+		return scalePriority(int64(level), 125)
+	default:
+		return scalePriority(int64(level), 250)
+	}
+}
+
+// SlogLevel converts a message priority into a slog level.  This is the
+// inverse of SlogPriority(), rounding towards slog.LevelInfo for
+// priorities in between.
+func SlogLevel(prio Priority) slog.Level {
+	switch {
+	case prio >= PrioError:
+		return slog.LevelError + slog.Level((prio-PrioError)/250)
+	case prio >= PrioInfo:
+		return slog.Level(prio / 125)
+	default:
+		return slog.Level(prio / 250)
+	}
+}
+
+// SlogHandler is a slog.Handler which forwards log records to T().
+type SlogHandler struct {
+	path  string
+	attrs string
+}
+
+// NewSlogHandler returns a slog.Handler which emits every log record as
+// a trace message for the given path.  Log levels are converted using
+// SlogPriority(), and each group opened with WithGroup() adds one
+// segment to the message path.  Attributes are appended to the message
+// text in the form key=value.
+//
+// Records are only formatted if a listener for the resulting path and
+// priority is registered, or if strict mode is enabled.  The handler
+// must not be used for a slog.Logger which, directly or indirectly,
+// receives trace messages, since this would result in an endless
+// loop.
+func NewSlogHandler(path string) *SlogHandler {
+	return &SlogHandler{path: path}
+}
+
+// Enabled reports whether a listener is registered which would receive
+// a record with the given level.  In strict mode, Enabled always
+// returns true, so that all records are checked by Handle.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return strict.Load() || wanted(h.path, SlogPriority(level))
+}
+
+// Handle emits the record 'r' as a trace message.  The origin of the
+// message is taken from r.PC.  In strict mode (see SetStrict()), the
+// priority of the record must be a pre-defined priority or registered
+// using RegisterPriority().
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	prio := SlogPriority(r.Level)
+	if !strict.Load() {
+		s := current.Load()
+		if s == nil || !s.mayMatch(h.path, prio) {
+			return nil
+		}
+	}
+
+	buf := &strings.Builder{}
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(buf, "", a)
+		return true
+	})
+	// The record's message is used as the format string, so that
+	// tools like "trace top" can tell the call sites apart.
+	format := strings.ReplaceAll(r.Message, "%", "%%") + "%s"
+	TAt(r.PC, h.path, prio, format, buf.String())
+	return nil
+}
+
+// WithAttrs returns a handler which includes the given attributes in
+// all messages.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	buf := &strings.Builder{}
+	buf.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendAttr(buf, "", a)
+	}
+	return &SlogHandler{path: h.path, attrs: buf.String()}
+}
+
+// WithGroup returns a handler which appends 'name' to the message path.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	path := name
+	if h.path != "" {
+		path = h.path + "/" + name
+	}
+	return &SlogHandler{path: path, attrs: h.attrs}
+}
+
+// appendAttr appends ' key=value' to 'buf', flattening group-valued
+// attributes into keys of the form group.key.
+func appendAttr(buf *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(buf, prefix, ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(a.Key)
+	buf.WriteByte('=')
+	buf.WriteString(value)
+}
+
+// SlogListener returns a listener which writes all messages it receives
+// to 'logger'.  The message priority is converted using SlogLevel(),
+// and the message path is included in the "path" attribute.
+func SlogListener(logger *slog.Logger) Listener {
+	return func(m *Message) {
+		level := SlogLevel(m.Prio)
+		h := logger.Handler()
+		if !h.Enabled(context.Background(), level) {
+			return
+		}
+		r := slog.NewRecord(m.Time, level, m.Msg, m.PC)
+		r.AddAttrs(slog.String("path", m.Path))
+		h.Handle(context.Background(), r)
+	}
+}
+
+// LogListener returns a listener which writes all messages it receives
+// to 'logger', in the form "path [prio]: msg".
+func LogListener(logger *log.Logger) Listener {
+	return func(m *Message) {
+		logger.Print(m.Path + " [" + m.Prio.String() + "]: " + m.Msg)
+	}
+}
@@ -0,0 +1,146 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSlogPriority(t *testing.T) {
+	testData := []struct {
+		level slog.Level
+		prio  Priority
+	}{
+		{slog.LevelDebug, PrioDebug},
+		{slog.LevelInfo, PrioInfo},
+		{slog.LevelWarn, 500},
+		{slog.LevelError, PrioError},
+		{slog.LevelError + 4, PrioCritical},
+		{slog.LevelDebug - 4, PrioVerbose},
+	}
+	for _, test := range testData {
+		if prio := SlogPriority(test.level); prio != test.prio {
+			t.Errorf("%s: expected %d, got %d", test.level, test.prio, prio)
+		}
+		if level := SlogLevel(test.prio); level != test.level {
+			t.Errorf("%d: expected %s, got %s", test.prio, test.level, level)
+		}
+	}
+}
+
+func TestSlogPriorityRange(t *testing.T) {
+	testData := []struct {
+		level slog.Level
+		prio  Priority
+	}{
+		{math.MaxInt, PrioMax},
+		{math.MaxInt32, PrioMax},
+		{math.MinInt, PrioAll},
+		{math.MinInt32, PrioAll},
+	}
+	for _, test := range testData {
+		if prio := SlogPriority(test.level); prio != test.prio {
+			t.Errorf("%d: expected %d, got %d", test.level, test.prio, prio)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var msgs []*Message
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "slog", PrioInfo, CaptureCaller())
+	defer handle.Unregister()
+
+	logger := slog.New(NewSlogHandler("slog")).With("id", 7)
+	logger.Debug("ignored")
+	logger.WithGroup("db").Warn("slow query", "query", "SELECT 1",
+		slog.Group("t", "ms", 120))
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.Path != "slog/db" || m.Prio != 500 {
+		t.Errorf("wrong path/priority %q/%d", m.Path, m.Prio)
+	}
+	if m.Msg != `slow query id=7 query="SELECT 1" t.ms=120` {
+		t.Errorf("wrong message %q", m.Msg)
+	}
+	if !strings.HasSuffix(m.File, "slog_test.go") {
+		t.Errorf("wrong caller %s:%d", m.File, m.Line)
+	}
+
+	if NewSlogHandler("slog").Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug level wrongly enabled")
+	}
+}
+
+func TestSlogHandlerFormat(t *testing.T) {
+	var msgs []*Message
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "slog", PrioInfo)
+	defer handle.Unregister()
+
+	logger := slog.New(NewSlogHandler("slog"))
+	logger.Info("disk 90% full", "dev", "sda")
+	logger.Info("started")
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Msg != "disk 90% full dev=sda" || msgs[1].Msg != "started" {
+		t.Errorf("wrong messages %q, %q", msgs[0].Msg, msgs[1].Msg)
+	}
+	if msgs[0].Format == msgs[1].Format {
+		t.Errorf("records share the format %q", msgs[0].Format)
+	}
+}
+
+func TestSlogListener(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	handle := Register(SlogListener(logger), "slog2", PrioAll)
+	T("slog2/a", PrioDebug, "ignored")
+	T("slog2/a", PrioError, "hello")
+	handle.Unregister()
+	if s := buf.String(); s != "level=ERROR msg=hello path=slog2/a\n" {
+		t.Errorf("wrong output %q", s)
+	}
+
+	buf.Reset()
+	handle = Register(LogListener(log.New(buf, "", 0)), "slog2", PrioAll)
+	T("slog2/b", PrioInfo, "world")
+	handle.Unregister()
+	if s := buf.String(); s != "slog2/b [info]: world\n" {
+		t.Errorf("wrong output %q", s)
+	}
+}
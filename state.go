@@ -0,0 +1,132 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"io"
+)
+
+// Attacher can be implemented by the state of listeners registered with
+// RegisterWith().  OnAttach is called before the listener receives its
+// first message.
+type Attacher interface {
+	OnAttach()
+}
+
+// Detacher can be implemented by the state of listeners registered with
+// RegisterWith().  OnDetach is called after the listener has been
+// unregistered and has returned from all calls.
+type Detacher interface {
+	OnDetach()
+}
+
+// Flusher can be implemented by the state of listeners registered with
+// RegisterWith(), to be called by Flush().
+type Flusher interface {
+	Flush() error
+}
+
+// RegisterWith adds a listener which receives a state value on every
+// call, in addition to the message.  This can be used to keep
+// per-listener data, like a re-usable formatting buffer, without a
+// separate closure for every listener.  The arguments 'path', 'prio'
+// and 'opts' are the same as for Register().
+//
+// The state value may implement the optional interfaces Attacher,
+// Detacher, Flusher and io.Closer.  OnAttach is called before the
+// listener is installed.  When the listener is unregistered, OnDetach
+// and then Close are called, once all concurrent calls to the listener
+// have returned.  This may happen after Unregister() has returned, in
+// the goroutine delivering the last message.  Flushers are called by
+// Flush().
+func RegisterWith[S any](listener func(state S, m *Message), state S,
+	path string, prio Priority, opts ...Option) ListenerHandle {
+	if a, ok := any(state).(Attacher); ok {
+		a.OnAttach()
+	}
+	opts = append(opts[:len(opts):len(opts)], func(c *listenerInfo) {
+		c.state = state
+	})
+	return Register(func(m *Message) {
+		listener(state, m)
+	}, path, prio, opts...)
+}
+
+// Flush calls the Flush method of all registered listener states which
+// implement the Flusher interface, for example before the program
+// exits.  Errors returned by the Flush methods are combined.
+func Flush() error {
+	listenerMutex.Lock()
+	var flushers []Flusher
+	for _, c := range listeners {
+		if f, ok := c.state.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	listenerMutex.Unlock()
+
+	var errs []error
+	for _, f := range flushers {
+		if err := f.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enter records the start of a call to the listener.  It returns false
+// if the listener has already been removed.
+func (c *listenerInfo) enter() bool {
+	c.inflight.Add(1)
+	if c.removed.Load() {
+		c.exit()
+		return false
+	}
+	return true
+}
+
+// exit records the end of a call to the listener.  If this was the last
+// call after the listener has been removed, the state is detached.
+func (c *listenerInfo) exit() {
+	if c.inflight.Add(-1) == 0 && c.removed.Load() {
+		c.detach()
+	}
+}
+
+// remove marks the listener as removed and detaches the state, unless
+// calls to the listener are still in progress.
+func (c *listenerInfo) remove() {
+	c.removed.Store(true)
+	if c.inflight.Load() == 0 {
+		c.detach()
+	}
+}
+
+// detach calls the OnDetach and Close methods of the state, exactly
+// once.
+func (c *listenerInfo) detach() {
+	if !c.detached.CompareAndSwap(false, true) {
+		return
+	}
+	if d, ok := c.state.(Detacher); ok {
+		d.OnDetach()
+	}
+	if cl, ok := c.state.(io.Closer); ok {
+		cl.Close()
+	}
+}
@@ -0,0 +1,85 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testState struct {
+	buf    strings.Builder
+	events []string
+}
+
+func (s *testState) OnAttach()    { s.events = append(s.events, "attach") }
+func (s *testState) OnDetach()    { s.events = append(s.events, "detach") }
+func (s *testState) Flush() error { s.events = append(s.events, "flush"); return nil }
+func (s *testState) Close() error { s.events = append(s.events, "close"); return nil }
+
+func TestRegisterWith(t *testing.T) {
+	state := &testState{}
+	handle := RegisterWith(func(s *testState, m *Message) {
+		s.buf.WriteString(m.Msg)
+		s.events = append(s.events, "msg")
+	}, state, "state", PrioInfo)
+
+	T("state", PrioInfo, "a")
+	T("state/x", PrioInfo, "b")
+	if err := Flush(); err != nil {
+		t.Error(err)
+	}
+	handle.Unregister()
+	T("state", PrioInfo, "c")
+
+	if s := state.buf.String(); s != "ab" {
+		t.Errorf("wrong messages %q", s)
+	}
+	expected := "attach msg msg flush detach close"
+	if s := strings.Join(state.events, " "); s != expected {
+		t.Errorf("wrong events %q", s)
+	}
+}
+
+type failingFlusher struct{}
+
+func (failingFlusher) Flush() error { return errors.New("disk full") }
+
+func TestFlushError(t *testing.T) {
+	handle := RegisterWith(func(failingFlusher, *Message) {}, failingFlusher{},
+		"state", PrioInfo)
+	err := Flush()
+	handle.Unregister()
+	if err == nil || err.Error() != "disk full" {
+		t.Errorf("expected flush error, got %v", err)
+	}
+}
+
+func TestUnregisterWhileDelivering(t *testing.T) {
+	state := &testState{}
+	var handle ListenerHandle
+	handle = RegisterWith(func(s *testState, m *Message) {
+		handle.Unregister()
+		s.events = append(s.events, "msg")
+	}, state, "state", PrioInfo)
+	T("state", PrioInfo, "a")
+	T("state", PrioInfo, "b")
+	if s := strings.Join(state.events, " "); s != "attach msg detach close" {
+		t.Errorf("wrong events %q", s)
+	}
+}
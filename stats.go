@@ -0,0 +1,181 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counts gives the number of messages for one path and priority
+// bucket.  Emitted counts messages delivered to at least one listener,
+// Suppressed counts messages withheld from a listener by rate limiting,
+// byte quotas or sampling, and Dropped counts messages discarded by an
+// AsyncListener because its queue was full or closed.
+type Counts struct {
+	Emitted    uint64 `json:"emitted"`
+	Suppressed uint64 `json:"suppressed"`
+	Dropped    uint64 `json:"dropped"`
+}
+
+// PathStats gives the message counts for one path, by priority bucket.
+// The keys are PrioCritical, PrioError, PrioInfo, PrioDebug and
+// PrioVerbose, and each bucket contains the messages with a priority
+// at least as high as the key, but lower than the next-higher key.
+// Buckets without messages are omitted.
+type PathStats map[Priority]Counts
+
+// The outcomes counted for every message.
+const (
+	outEmitted = iota
+	outSuppressed
+	outDropped
+	numOutcomes
+)
+
+// statsBuckets lists the lower bounds of the priority buckets.
+var statsBuckets = [...]Priority{
+	PrioCritical, PrioError, PrioInfo, PrioDebug, PrioVerbose,
+}
+
+// bucket returns the index in statsBuckets of the bucket for 'prio'.
+func bucket(prio Priority) int {
+	for i, lower := range statsBuckets[:len(statsBuckets)-1] {
+		if prio >= lower {
+			return i
+		}
+	}
+	return len(statsBuckets) - 1
+}
+
+type pathCounter [len(statsBuckets)][numOutcomes]atomic.Uint64
+
+// statsByPath maps message paths to their *pathCounter.  A sync.Map is
+// used, since count() is called for every message and the set of
+// paths rarely changes, so that T() never needs to take a lock once
+// the counter for a path exists.
+var statsByPath sync.Map
+
+// count records one message with the given path, priority and outcome.
+func count(path string, prio Priority, outcome int) {
+	v, ok := statsByPath.Load(path)
+	if !ok {
+		v, _ = statsByPath.LoadOrStore(path, &pathCounter{})
+	}
+	v.(*pathCounter)[bucket(prio)][outcome].Add(1)
+}
+
+// forEachCount calls 'fn' for every path and bucket with non-zero
+// message counts, in order of increasing path.
+func forEachCount(fn func(path string, prio Priority, counts Counts)) {
+	type entry struct {
+		path string
+		c    *pathCounter
+	}
+	var entries []entry
+	statsByPath.Range(func(key, value interface{}) bool {
+		entries = append(entries, entry{key.(string), value.(*pathCounter)})
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	for _, e := range entries {
+		c := e.c
+		for i := range c {
+			counts := Counts{
+				Emitted:    c[i][outEmitted].Load(),
+				Suppressed: c[i][outSuppressed].Load(),
+				Dropped:    c[i][outDropped].Load(),
+			}
+			if counts != (Counts{}) {
+				fn(e.path, statsBuckets[i], counts)
+			}
+		}
+	}
+}
+
+// ExactStats returns the message counts for every path for which
+// messages have been seen.  In contrast to Stats(), the counts for a
+// path do not include the messages for its sub-paths.
+func ExactStats() map[string]PathStats {
+	res := map[string]PathStats{}
+	forEachCount(func(path string, prio Priority, counts Counts) {
+		if res[path] == nil {
+			res[path] = PathStats{}
+		}
+		res[path][prio] = counts
+	})
+	return res
+}
+
+// Stats returns the message counts for every path prefix.  The counts
+// for a path include all messages for the path and its sub-paths, so
+// for example Stats()["db"][PrioError].Emitted is the number of
+// messages of priority PrioError (but below PrioCritical) delivered
+// for "db", "db/mysql", and so on.  The entry for the empty path
+// covers all messages.
+func Stats() map[string]PathStats {
+	res := map[string]PathStats{}
+	add := func(prefix string, prio Priority, counts Counts) {
+		stats := res[prefix]
+		if stats == nil {
+			stats = PathStats{}
+			res[prefix] = stats
+		}
+		total := stats[prio]
+		total.Emitted += counts.Emitted
+		total.Suppressed += counts.Suppressed
+		total.Dropped += counts.Dropped
+		stats[prio] = total
+	}
+	forEachCount(func(path string, prio Priority, counts Counts) {
+		add("", prio, counts)
+		for i := 1; i <= len(path); i++ {
+			if i == len(path) || path[i] == '/' {
+				add(path[:i], prio, counts)
+			}
+		}
+	})
+	return res
+}
+
+// ResetStats sets all message counts to zero.
+func ResetStats() {
+	statsByPath.Range(func(key, _ interface{}) bool {
+		statsByPath.Delete(key)
+		return true
+	})
+}
+
+// PublishExpvar publishes the result of Stats() as an expvar variable
+// with the given name, using priority names like "error" as keys for
+// the priority buckets.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		res := map[string]map[string]Counts{}
+		for path, stats := range Stats() {
+			byName := map[string]Counts{}
+			for prio, counts := range stats {
+				byName[prio.String()] = counts
+			}
+			res[path] = byName
+		}
+		return res
+	}))
+}
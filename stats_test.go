@@ -0,0 +1,99 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	testData := []struct {
+		prio, bucket Priority
+	}{
+		{PrioCritical + 1, PrioCritical},
+		{PrioCritical, PrioCritical},
+		{PrioCritical - 1, PrioError},
+		{PrioInfo, PrioInfo},
+		{PrioDebug + 1, PrioDebug},
+		{PrioVerbose, PrioVerbose},
+		{PrioAll, PrioVerbose},
+	}
+	for _, test := range testData {
+		if b := statsBuckets[bucket(test.prio)]; b != test.bucket {
+			t.Errorf("%d: expected bucket %d, got %d", test.prio, test.bucket, b)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	ResetStats()
+	handle := Register(func(m *Message) {}, "stats", PrioInfo)
+	T("stats/a", PrioError, "1")
+	T("stats/a", PrioError, "2")
+	T("stats/b", PrioInfo, "3")
+	T("stats/b", PrioDebug, "not delivered")
+	handle.Unregister()
+
+	handle = Register(func(m *Message) {}, "stats/c", PrioAll,
+		MaxPerSecond(1, 1), SummaryInterval(time.Hour))
+	T("stats/c", PrioInfo, "4")
+	T("stats/c", PrioInfo, "suppressed")
+	handle.Unregister()
+
+	a := NewAsyncListener(func(m *Message) {}, 1)
+	a.Close()
+	a.Listen(&Message{Path: "stats/d", Prio: PrioVerbose})
+
+	stats := Stats()
+	if c := stats["stats"][PrioError]; c != (Counts{Emitted: 2}) {
+		t.Errorf("wrong error counts %v", c)
+	}
+	if c := stats["stats"][PrioInfo]; c != (Counts{Emitted: 2, Suppressed: 1}) {
+		t.Errorf("wrong info counts %v", c)
+	}
+	if c := stats[""][PrioVerbose]; c != (Counts{Dropped: 1}) {
+		t.Errorf("wrong verbose counts %v", c)
+	}
+	if c := stats["stats/b"][PrioInfo]; c != (Counts{Emitted: 1}) {
+		t.Errorf("wrong counts for stats/b %v", c)
+	}
+	if _, ok := stats["stats/b"][PrioDebug]; ok {
+		t.Error("undelivered message counted")
+	}
+
+	exact := ExactStats()
+	if _, ok := exact["stats"]; ok {
+		t.Error("unexpected prefix in ExactStats")
+	}
+	if c := exact["stats/a"][PrioError]; c != (Counts{Emitted: 2}) {
+		t.Errorf("wrong exact counts %v", c)
+	}
+
+	if expvar.Get("trace-test") == nil {
+		PublishExpvar("trace-test")
+	}
+	var res map[string]map[string]Counts
+	if err := json.Unmarshal([]byte(expvar.Get("trace-test").String()), &res); err != nil {
+		t.Fatal(err)
+	}
+	if c := res["stats/a"]["error"]; c != (Counts{Emitted: 2}) {
+		t.Errorf("wrong expvar counts %v", c)
+	}
+}
@@ -0,0 +1,44 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import "os"
+
+// SplitListener returns a listener which passes messages of priority
+// 'threshold' or higher to 'high', and all other messages to 'low'.
+func SplitListener(threshold Priority, low, high Listener) Listener {
+	return func(m *Message) {
+		if m.Prio >= threshold {
+			high(m)
+		} else {
+			low(m)
+		}
+	}
+}
+
+// StdStreams returns a listener which writes messages in the trace file
+// format, one JSON object per line, following the conventions for
+// programs running in containers: messages of priority PrioError and
+// higher are written to os.Stderr, all other messages are written to
+// os.Stdout.  The returned listener is also available as the output
+// "std" of Configure().  Example:
+//
+//	trace.Register(trace.StdStreams(), "", trace.PrioInfo)
+func StdStreams() Listener {
+	return SplitListener(PrioError,
+		NewJSONWriter(os.Stdout).Listen, NewJSONWriter(os.Stderr).Listen)
+}
@@ -0,0 +1,56 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSplitListener(t *testing.T) {
+	low := &bytes.Buffer{}
+	high := &bytes.Buffer{}
+	l := SplitListener(PrioError,
+		NewJSONWriter(low).Listen, NewJSONWriter(high).Listen)
+	for _, prio := range []Priority{PrioVerbose, PrioInfo, PrioError,
+		PrioCritical} {
+		l(&Message{Time: time.Now(), Path: "test", Prio: prio, Msg: prio.String()})
+	}
+
+	for _, c := range []struct {
+		buf      *bytes.Buffer
+		expected []string
+	}{
+		{low, []string{"verbose", "info"}},
+		{high, []string{"error", "critical"}},
+	} {
+		r := NewJSONReader(c.buf)
+		for _, msg := range c.expected {
+			m, err := r.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Msg != msg {
+				t.Errorf("expected %q, got %q", msg, m.Msg)
+			}
+		}
+		if _, err := r.Read(); err == nil {
+			t.Error("too many messages")
+		}
+	}
+}
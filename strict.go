@@ -0,0 +1,157 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// strict indicates whether calls to T() are checked, see SetStrict().
+var strict atomic.Bool
+
+var (
+	prioMutex sync.RWMutex // protects extraPrios
+
+	// extraPrios holds the priorities accepted in strict mode in
+	// addition to the pre-defined ones.  The priority of
+	// slog.LevelWarn, used by SlogHandler and for schema violations,
+	// is registered from the start.
+	extraPrios = map[Priority]bool{
+		SlogPriority(slog.LevelWarn): true,
+		prioSchemaWarning:            true,
+	}
+)
+
+// SetStrict enables or disables strict mode.  In strict mode, every
+// call to T() or TAuto() is checked for common mistakes, whether or not
+// a listener receives the message, and the call panics if the message
+// path is empty or starts or ends with a slash, if the priority is
+// neither one of the pre-defined priorities PrioCritical, PrioError,
+// PrioInfo, PrioDebug and PrioVerbose nor registered using
+// RegisterPriority(), or if the number of arguments does not match the
+// format string.  In addition, the fields of delivered
+// messages are checked against the schemas declared using SetSchema(),
+// and listeners which modify the messages they receive are reported
+// by a message of priority PrioError with path "trace".  For this
+// check, every listener receives its own copy of the message, so that
+// modifications do not affect the other listeners.  Modifications made
+// after the listener has returned, for example by a goroutine which
+// processes queued messages, are not detected.  Strict mode is meant
+// for development and tests; it can also be enabled by setting the
+// environment variable TRACE_STRICT to a non-empty value.
+func SetStrict(enabled bool) {
+	strict.Store(enabled)
+}
+
+// RegisterPriority adds 'prio' to the priorities accepted by strict
+// mode.  This is needed for programs which use priorities in between
+// the pre-defined ones, for example for custom slog levels passed
+// through SlogHandler:
+//
+//	trace.RegisterPriority(trace.SlogPriority(LevelNotice))
+//
+// The priority of slog.LevelWarn is registered by default.
+func RegisterPriority(prio Priority) {
+	prioMutex.Lock()
+	extraPrios[prio] = true
+	prioMutex.Unlock()
+}
+
+// knownPriority reports whether 'prio' is accepted by strict mode.
+func knownPriority(prio Priority) bool {
+	switch prio {
+	case PrioCritical, PrioError, PrioInfo, PrioDebug, PrioVerbose:
+		return true
+	}
+	prioMutex.RLock()
+	defer prioMutex.RUnlock()
+	return extraPrios[prio]
+}
+
+// checkCall panics if the arguments of a call to T() are invalid.
+func checkCall(path string, prio Priority, format string, args []interface{}) {
+	var problem string
+	switch {
+	case path == "":
+		problem = "empty message path"
+	case strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/"):
+		problem = fmt.Sprintf("message path %q starts or ends with a slash", path)
+	case !knownPriority(prio):
+		problem = fmt.Sprintf("non-standard priority %d", prio)
+	default:
+		_, args = splitRoute(args)
+		_, args = splitBinary(args)
+		if n, ok := countVerbs(format); ok && n != len(args) {
+			problem = fmt.Sprintf("format %q needs %d arguments, but %d given",
+				format, n, len(args))
+		}
+	}
+	if problem != "" {
+		panic("trace: strict mode: " + problem)
+	}
+}
+
+// countVerbs returns the number of arguments consumed by the format
+// string 'format'.  If the format uses explicit argument indices, the
+// number cannot be determined and ok is false.
+func countVerbs(format string) (n int, ok bool) {
+	skipDigits := func(i int) int {
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		return i
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		if i < len(format) && format[i] == '*' {
+			n++
+			i++
+		} else {
+			i = skipDigits(i)
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			if i < len(format) && format[i] == '*' {
+				n++
+				i++
+			} else {
+				i = skipDigits(i)
+			}
+		}
+		if i >= len(format) {
+			break
+		}
+		switch format[i] {
+		case '[':
+			return 0, false
+		case '%':
+		default:
+			n++
+		}
+	}
+	return n, true
+}
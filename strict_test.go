@@ -0,0 +1,134 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountVerbs(t *testing.T) {
+	testData := []struct {
+		format string
+		n      int
+		ok     bool
+	}{
+		{"hello", 0, true},
+		{"100%%", 0, true},
+		{"%d %s", 2, true},
+		{"%-8.3f|%+q", 2, true},
+		{"%*d %.*f", 4, true},
+		{"%[1]d %[1]d", 0, false},
+		{"trailing %", 0, true},
+	}
+	for _, test := range testData {
+		n, ok := countVerbs(test.format)
+		if n != test.n || ok != test.ok {
+			t.Errorf("%q: expected %d/%t, got %d/%t", test.format, test.n, test.ok, n, ok)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	check := func(problem string, fn func()) {
+		t.Helper()
+		defer func() {
+			r := recover()
+			s, _ := r.(string)
+			if problem == "" && r != nil {
+				t.Errorf("unexpected panic: %v", r)
+			} else if problem != "" && !strings.Contains(s, problem) {
+				t.Errorf("expected panic about %q, got %v", problem, r)
+			}
+		}()
+		fn()
+	}
+	check("", func() { T("strict", PrioInfo, "%d%%", 1) })
+	check("", func() { TAuto(PrioDebug, "hello") })
+	check("empty message path", func() { T("", PrioInfo, "hello") })
+	check("slash", func() { T("strict/", PrioInfo, "hello") })
+	check("non-standard priority 1", func() { T("strict", 1, "hello") })
+	check("needs 2 arguments, but 1 given", func() { T("strict", PrioInfo, "%s %s", "x") })
+	check("needs 0 arguments", func() { TAuto(PrioInfo, "hello", 1) })
+
+	logger := slog.New(NewSlogHandler("strict"))
+	check("", func() { logger.Warn("disk 90% full", "dev", "sda") })
+	check("non-standard priority", func() { logger.Log(context.Background(), slog.LevelWarn+1, "x") })
+	check("", func() { T("strict", SlogPriority(slog.LevelWarn), "hello") })
+
+	RegisterPriority(PrioInfo + 1)
+	check("", func() { T("strict", PrioInfo+1, "hello") })
+}
+
+func TestStrictModified(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	var reports, seen []string
+	h1 := Register(func(m *Message) {
+		reports = append(reports, m.Msg)
+	}, "trace", PrioError)
+	defer h1.Unregister()
+	h2 := Register(func(m *Message) {
+		m.Msg = "changed"
+	}, "strict", PrioInfo)
+	defer h2.Unregister()
+	h3 := Register(func(m *Message) {
+		seen = append(seen, m.Msg)
+	}, "strict", PrioInfo)
+	defer h3.Unregister()
+
+	T("strict", PrioInfo, "original")
+	if len(seen) != 1 || seen[0] != "original" {
+		t.Errorf("modification seen by other listener: %q", seen)
+	}
+	if len(reports) != 1 || !strings.Contains(reports[0], `modified message "original"`) {
+		t.Errorf("wrong reports %q", reports)
+	}
+}
+
+func TestStrictModifiedAsync(t *testing.T) {
+	SetStrict(true)
+	defer SetStrict(false)
+
+	// The asynchronous listener reads the message while the second
+	// listener modifies it; the race detector checks that the two
+	// listeners do not share the message.
+	seen := make(chan string, 10)
+	async := NewAsyncListener(func(m *Message) {
+		time.Sleep(time.Millisecond)
+		seen <- m.Msg
+	}, 10)
+	h1 := Register(async.Listen, "strictasync", PrioInfo)
+	h2 := Register(func(m *Message) {
+		m.Msg = "changed"
+	}, "strictasync", PrioInfo)
+	T("strictasync", PrioInfo, "original")
+	h1.Unregister()
+	h2.Unregister()
+	async.Close()
+
+	if msg := <-seen; msg != "original" {
+		t.Errorf("asynchronous listener saw %q", msg)
+	}
+}
@@ -0,0 +1,100 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Limits for the delay before a supervised function is restarted.  The
+// delay starts at superviseMinBackoff and is doubled after every
+// failure, up to superviseMaxBackoff.  If the function ran for at least
+// superviseStable before failing, the delay is reset.
+var (
+	superviseMinBackoff = 100 * time.Millisecond
+	superviseMaxBackoff = 30 * time.Second
+	superviseStable     = time.Minute
+)
+
+// Supervisor runs a function in a background goroutine and restarts it
+// if it fails.  Supervisors are used by listeners with background
+// goroutines, like AsyncListener, so that a transient failure does not
+// permanently stop the delivery of messages.
+type Supervisor struct {
+	name     string
+	run      func() error
+	restarts atomic.Uint64
+	done     chan struct{}
+}
+
+// Supervise starts 'run' in a new goroutine.  If 'run' panics or
+// returns a non-nil error, a message of priority PrioError is emitted
+// for the path "trace" and 'run' is started again after a delay.  If
+// 'run' returns nil, the supervisor stops.  The argument 'name' is used
+// in the messages to identify the function.
+func Supervise(name string, run func() error) *Supervisor {
+	s := &Supervisor{
+		name: name,
+		run:  run,
+		done: make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *Supervisor) loop() {
+	defer close(s.done)
+	backoff := superviseMinBackoff
+	for {
+		start := time.Now()
+		err := s.runOnce()
+		if err == nil {
+			return
+		}
+		if time.Since(start) >= superviseStable {
+			backoff = superviseMinBackoff
+		}
+		s.restarts.Add(1)
+		T("trace", PrioError, "%s failed: %s; restarting in %s",
+			s.name, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, superviseMaxBackoff)
+	}
+}
+
+// runOnce calls the supervised function, converting panics into errors.
+func (s *Supervisor) runOnce() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.run()
+}
+
+// Restarts returns the number of times the function has been
+// restarted.
+func (s *Supervisor) Restarts() uint64 {
+	return s.restarts.Load()
+}
+
+// Wait waits until the supervised function has returned nil.
+func (s *Supervisor) Wait() {
+	<-s.done
+}
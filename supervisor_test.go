@@ -0,0 +1,72 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervise(t *testing.T) {
+	defer func(d time.Duration) { superviseMinBackoff = d }(superviseMinBackoff)
+	superviseMinBackoff = time.Millisecond
+
+	var (
+		mutex  sync.Mutex
+		events []string
+	)
+	handle := Register(func(m *Message) {
+		mutex.Lock()
+		events = append(events, m.Msg)
+		mutex.Unlock()
+	}, "trace", PrioError)
+	defer handle.Unregister()
+
+	calls := 0
+	s := Supervise("test worker", func() error {
+		calls++
+		switch calls {
+		case 1:
+			panic("oops")
+		case 2:
+			return errors.New("broken")
+		}
+		return nil
+	})
+	s.Wait()
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if s.Restarts() != 2 {
+		t.Errorf("expected 2 restarts, got %d", s.Restarts())
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 messages, got %q", events)
+	}
+	for i, want := range []string{"test worker failed: panic: oops",
+		"test worker failed: broken"} {
+		if !strings.HasPrefix(events[i], want) {
+			t.Errorf("wrong message %q", events[i])
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Syslog facility codes, as defined in RFC 5424, which can be used as
+// the 'facility' argument of NewSyslogListener().
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+	FacilityLocal1 = 17
+	FacilityLocal2 = 18
+	FacilityLocal3 = 19
+	FacilityLocal4 = 20
+	FacilityLocal5 = 21
+	FacilityLocal6 = 22
+	FacilityLocal7 = 23
+)
+
+// Syslog severity codes, as defined in RFC 5424.
+const (
+	sevCritical = 2
+	sevError    = 3
+	sevInfo     = 6
+	sevDebug    = 7
+)
+
+// syslogSeverity maps a message priority to the corresponding syslog
+// severity.  Priorities between the pre-defined values are mapped to
+// the severity of the next-lower pre-defined priority.
+func syslogSeverity(prio Priority) int {
+	switch {
+	case prio >= PrioCritical:
+		return sevCritical
+	case prio >= PrioError:
+		return sevError
+	case prio >= PrioInfo:
+		return sevInfo
+	default:
+		return sevDebug
+	}
+}
+
+var syslogLocalSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogListener forwards trace messages to a syslog daemon, using the
+// message format described in RFC 5424.  Use the Listen method as the
+// listener argument of Register().
+type SyslogListener struct {
+	network  string
+	raddr    string
+	facility int
+	hostname string
+
+	mutex  sync.Mutex // protects conn and closed
+	conn   net.Conn
+	closed bool
+}
+
+// NewSyslogListener connects to the syslog daemon at address 'raddr'
+// on the named network.  Supported networks are "udp" and "unixgram"
+// (one message per datagram) as well as "tcp" and "unix" (using
+// octet-counting framing as described in RFC 6587).  If 'network' is
+// the empty string, the local syslog daemon is contacted via one of the
+// usual unix domain sockets.
+//
+// The argument 'facility' gives the syslog facility code used for all
+// messages, for example FacilityUser or FacilityDaemon.  The message
+// path is used as the syslog APP-NAME (tag) and the message priority
+// is mapped to the corresponding syslog severity.
+func NewSyslogListener(network, raddr string, facility int) (*SyslogListener, error) {
+	if facility < 0 || facility > 23 {
+		return nil, errors.New("invalid syslog facility " + strconv.Itoa(facility))
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	s := &SyslogListener{
+		network:  network,
+		raddr:    raddr,
+		facility: facility,
+		hostname: hostname,
+	}
+	s.conn, err = s.dial()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogListener) dial() (net.Conn, error) {
+	if s.network != "" {
+		return net.Dial(s.network, s.raddr)
+	}
+	var err error
+	for _, name := range syslogLocalSockets {
+		var conn net.Conn
+		conn, err = net.Dial("unixgram", name)
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (s *SyslogListener) isStream() bool {
+	return s.network == "tcp" || s.network == "tcp4" ||
+		s.network == "tcp6" || s.network == "unix"
+}
+
+// format renders a message in the RFC 5424 syslog format.
+func (s *SyslogListener) format(m *Message) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(s.facility*8 + syslogSeverity(m.Prio)))
+	buf.WriteString(">1 ")
+	buf.WriteString(m.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(s.hostname, 255))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(m.Path, 48))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.Itoa(os.Getpid()))
+	buf.WriteString(" - - ")
+	buf.WriteString(m.Msg)
+	return buf.Bytes()
+}
+
+// syslogHeaderField converts 's' into a valid RFC 5424 header field,
+// by replacing non-printable characters and truncating the value to
+// at most 'maxLen' bytes.
+func syslogHeaderField(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// Listen sends a single trace message to the syslog daemon.  If the
+// connection has been lost, one attempt is made to re-connect;
+// messages which cannot be delivered are discarded.
+func (s *SyslogListener) Listen(m *Message) {
+	data := s.format(m)
+	if s.isStream() {
+		data = append([]byte(strconv.Itoa(len(data))+" "), data...)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	if s.conn != nil {
+		if _, err := s.conn.Write(data); err == nil {
+			return
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	conn, err := s.dial()
+	if err != nil {
+		return
+	}
+	s.conn = conn
+	s.conn.Write(data)
+}
+
+// Close closes the connection to the syslog daemon.  Messages received
+// after Close has been called are discarded.
+func (s *SyslogListener) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,98 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSeverity(t *testing.T) {
+	testData := []struct {
+		prio Priority
+		sev  int
+	}{
+		{PrioCritical + 1, 2},
+		{PrioCritical, 2},
+		{PrioError, 3},
+		{PrioInfo + 10, 6},
+		{PrioInfo, 6},
+		{PrioDebug, 7},
+		{PrioAll, 7},
+	}
+	for _, test := range testData {
+		if sev := syslogSeverity(test.prio); sev != test.sev {
+			t.Errorf("priority %d: expected severity %d, got %d",
+				test.prio, test.sev, sev)
+		}
+	}
+}
+
+func TestSyslogListener(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	s, err := NewSyslogListener("udp", server.LocalAddr().String(), FacilityDaemon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	when := time.Date(2013, 5, 1, 12, 30, 0, 0, time.UTC)
+	s.Listen(&Message{Time: when, Path: "client/setup", Prio: PrioError, Msg: "hello syslog"})
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	fields := strings.SplitN(msg, " ", 8)
+	if len(fields) != 8 {
+		t.Fatalf("malformed syslog message %q", msg)
+	}
+	if fields[0] != "<27>1" {
+		t.Errorf("wrong priority/version %q", fields[0])
+	}
+	if fields[1] != "2013-05-01T12:30:00.000000Z" {
+		t.Errorf("wrong timestamp %q", fields[1])
+	}
+	if fields[3] != "client/setup" {
+		t.Errorf("wrong tag %q", fields[3])
+	}
+	if fields[7] != "hello syslog" {
+		t.Errorf("wrong message %q", fields[7])
+	}
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	if s := syslogHeaderField("", 10); s != "-" {
+		t.Errorf("wrong value for empty field: %q", s)
+	}
+	if s := syslogHeaderField("a b\tc", 10); s != "a_b_c" {
+		t.Errorf("non-printable characters not replaced: %q", s)
+	}
+	if s := syslogHeaderField("abcdef", 3); s != "abc" {
+		t.Errorf("field not truncated: %q", s)
+	}
+}
@@ -19,7 +19,12 @@ package trace
 import (
 	"fmt"
 	"math"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -77,6 +82,51 @@ const (
 	PrioAll Priority = math.MinInt32
 )
 
+// String returns the name of a pre-defined priority, for example
+// "error" for PrioError, or the numeric value for other priorities.
+func (prio Priority) String() string {
+	switch prio {
+	case PrioCritical:
+		return "critical"
+	case PrioError:
+		return "error"
+	case PrioInfo:
+		return "info"
+	case PrioDebug:
+		return "debug"
+	case PrioVerbose:
+		return "verbose"
+	case PrioAll:
+		return "all"
+	default:
+		return strconv.Itoa(int(prio))
+	}
+}
+
+// ParsePriority converts a priority name, as returned by
+// Priority.String(), or a decimal number into a Priority value.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "critical":
+		return PrioCritical, nil
+	case "error":
+		return PrioError, nil
+	case "info":
+		return PrioInfo, nil
+	case "debug":
+		return PrioDebug, nil
+	case "verbose":
+		return PrioVerbose, nil
+	case "all":
+		return PrioAll, nil
+	}
+	x, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse priority %q", s)
+	}
+	return Priority(x), nil
+}
+
 // T is used to send a trace message and to the registered listeners.
 //
 // The argument 'path' indicates which component of the program the
@@ -95,30 +145,288 @@ const (
 //
 // The argument 'format' and the following, optional arguments are
 // passed to fmt.Sprintf to compose the message reported to the
-// listeners registered for the given message path.
+// listeners registered for the given message path.  Arguments of type
+// Valuer or func() interface{} are replaced by the value they return;
+// these functions are only called if the message is delivered to at
+// least one listener.  Arguments of type Route are not used for
+// formatting, but select an additional group of listeners.  Arguments
+// of type Binary, as returned by Bytes(), are not used for formatting
+// either, but are attached to the message as binary fields.
 func T(path string, prio Priority, format string, args ...interface{}) {
-	listenerMutex.RLock()
-	defer listenerMutex.RUnlock()
-	if len(listeners) == 0 {
+	if strict.Load() {
+		checkCall(path, prio, format, args)
+	}
+	s := current.Load()
+	if s == nil || !s.mayMatch(path, prio) && (s.byGroup == nil || !hasRoute(args)) {
 		return
 	}
+	s.dispatch(0, path, prio, format, args)
+}
 
-	var (
-		t   time.Time
-		msg string
-	)
-	first := true
-	for _, c := range listeners {
-		if prio >= c.prio && strings.HasPrefix(path, c.path) {
-			if l := len(c.path); l > 0 && len(path) > l && path[l] != '/' {
+// TAuto sends a trace message, like T(), using the import path of the
+// calling function's package as the message path.  For example, a
+// call from package github.com/seehuhn/trace/otlp uses the path
+// "github.com/seehuhn/trace/otlp", and calls from the main package
+// use "main".  The path is computed once per call site and then
+// cached, but each call needs to determine the caller, so TAuto is
+// slower than T() when listeners are registered.
+func TAuto(prio Priority, format string, args ...interface{}) {
+	s := current.Load()
+	if s == nil && !strict.Load() {
+		return
+	}
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) < 1 {
+		return
+	}
+	path := callerPackage(pcs[0])
+	if strict.Load() {
+		checkCall(path, prio, format, args)
+	}
+	if s == nil || !s.mayMatch(path, prio) && (s.byGroup == nil || !hasRoute(args)) {
+		return
+	}
+	s.dispatch(pcs[0], path, prio, format, args)
+}
+
+// TAt sends a trace message, like T(), but attributes it to the call
+// site 'pc', as returned by runtime.Callers().  This is meant for
+// wrappers around T(), so that caller information (see
+// CaptureCaller()) and profiles refer to the caller of the wrapper
+// instead of the wrapper itself.  If 'pc' is zero, TAt is the same as
+// T().
+func TAt(pc uintptr, path string, prio Priority, format string, args ...interface{}) {
+	if strict.Load() {
+		checkCall(path, prio, format, args)
+	}
+	s := current.Load()
+	if s == nil || !s.mayMatch(path, prio) && (s.byGroup == nil || !hasRoute(args)) {
+		return
+	}
+	s.dispatch(pc, path, prio, format, args)
+}
+
+var (
+	packageMutex sync.RWMutex // protects packageByPC
+	packageByPC  = map[uintptr]string{}
+)
+
+// callerPackage returns the import path of the package containing the
+// call site 'pc'.
+func callerPackage(pc uintptr) string {
+	packageMutex.RLock()
+	path, ok := packageByPC[pc]
+	packageMutex.RUnlock()
+	if ok {
+		return path
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	path = packagePath(frame.Function)
+	packageMutex.Lock()
+	packageByPC[pc] = path
+	packageMutex.Unlock()
+	return path
+}
+
+// packagePath extracts the package import path from a fully qualified
+// function name like "github.com/a/b.(*T).Method".  Dots in the last
+// element of the import path appear as "%2e" in function names.
+func packagePath(funcName string) string {
+	path := funcName
+	start := strings.LastIndexByte(funcName, '/') + 1
+	if i := strings.IndexByte(funcName[start:], '.'); i >= 0 {
+		path = funcName[:start+i]
+	}
+	return strings.ReplaceAll(path, "%2e", ".")
+}
+
+// dispatch delivers a message to the matching listeners in 's'.  If
+// 'pc' is non-zero, it is used as the origin of the message;
+// otherwise the caller of the function calling dispatch is used.
+func (s *snapshot) dispatch(pc uintptr, path string, prio Priority, format string, args []interface{}) {
+	var m *Message
+	delivered := false
+	var start time.Time
+	var formatTime time.Duration
+	profile := profiling.Load()
+	if profile {
+		start = time.Now()
+	}
+	if pc == 0 && (s.caller || profile) {
+		var pcs [1]uintptr
+		runtime.Callers(3, pcs[:])
+		pc = pcs[0]
+	}
+	route, args := splitRoute(args)
+	binary, args := splitBinary(args)
+	var routed []*listenerInfo
+	if s.byGroup != nil {
+		routed = s.byGroup[route]
+	}
+	newMessage := func() {
+		var t0 time.Time
+		if profile {
+			t0 = time.Now()
+		}
+		m = &Message{
+			Time:   time.Now(),
+			Path:   path,
+			Prio:   prio,
+			Msg:    fmt.Sprintf(format, evaluate(args)...),
+			Format: format,
+			Route:  route,
+			Binary: binary,
+		}
+		if s.caller && pc != 0 {
+			// Caller information is filled in before the message is
+			// passed to the first listener, since asynchronous
+			// listeners may read the message concurrently.
+			m.setCaller(pc)
+		}
+		if profile {
+			formatTime = time.Since(t0)
+		}
+		if strict.Load() {
+			checkSchema(m)
+		}
+	}
+	// Listeners registered for a path receive the messages for this
+	// path and all its sub-paths.  Check the listeners for every prefix
+	// of 'path' which ends just before a slash, and for 'path' itself.
+	for i := 0; i <= len(path); i++ {
+		if i > 0 && i < len(path) && path[i] != '/' {
+			continue
+		}
+		for _, c := range s.byPath[path[:i]] {
+			if prio < c.prio || c.paused.Load() || route != "" && c.group == route {
 				continue
 			}
-			if first {
-				t = time.Now()
-				msg = fmt.Sprintf(format, args...)
-				first = false
+			if c.limit != nil && !c.limit.allow(prio) {
+				count(path, prio, outSuppressed)
+				continue
+			}
+			if m == nil {
+				newMessage()
+			}
+			if c.quotas != nil {
+				if q := findQuota(c.quotas, path); q != nil && !q.allow(m) {
+					count(path, prio, outSuppressed)
+					continue
+				}
+			}
+			c.deliver(m)
+			delivered = true
+		}
+	}
+	// Listeners in the group given by a Route argument receive the
+	// message whatever their path and priority.
+	for _, c := range routed {
+		if c.paused.Load() {
+			continue
+		}
+		if m == nil {
+			newMessage()
+		}
+		c.deliver(m)
+		delivered = true
+	}
+	if delivered {
+		count(path, prio, outEmitted)
+	}
+	if profile && m != nil {
+		recordProfile(pc, path, formatTime, time.Since(start))
+	}
+}
+
+// Valuer is the type of lazily evaluated arguments to T().  Valuers can
+// be used for arguments which are expensive to compute, for example
+// the result of a reverse DNS lookup, to avoid the cost for messages
+// which are not delivered to any listener.
+type Valuer func() interface{}
+
+// evaluate returns 'args', with Valuer arguments replaced by their
+// values.  The slice 'args' is not modified.
+func evaluate(args []interface{}) []interface{} {
+	var res []interface{}
+	for i, arg := range args {
+		var value interface{}
+		switch f := arg.(type) {
+		case Valuer:
+			value = f()
+		case func() interface{}:
+			value = f()
+		default:
+			if res != nil {
+				res[i] = arg
+			}
+			continue
+		}
+		if res == nil {
+			res = make([]interface{}, len(args))
+			copy(res, args[:i])
+		}
+		res[i] = value
+	}
+	if res == nil {
+		return args
+	}
+	return res
+}
+
+// deliver passes a message to a listener, recovering from panics in
+// the listener.
+func (c *listenerInfo) deliver(m *Message) {
+	if c.state != nil {
+		if !c.enter() {
+			return
+		}
+		defer c.exit()
+	}
+	if c.private {
+		cp := *m
+		m = &cp
+	} else if strict.Load() {
+		// The listener gets a copy, so that modifications are detected
+		// without affecting other listeners, even if the listener
+		// keeps the message after returning, like AsyncListener does.
+		orig := m
+		cp := *m
+		m = &cp
+		defer func() {
+			if !reflect.DeepEqual(&cp, orig) {
+				c.reportModified(orig)
 			}
-			c.listener(t, path, prio, msg)
+		}()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.reportPanic(r)
 		}
+	}()
+	c.listener(m)
+}
+
+// reportModified emits a message about a listener which has modified
+// the message 'm' in strict mode.  Modifications of the message emitted
+// here are not reported again.
+func (c *listenerInfo) reportModified(m *Message) {
+	if !c.modifying.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.modifying.Store(false)
+	T("trace", PrioError, "listener for path %q modified message %q from path %q",
+		c.path, m.Msg, m.Path)
+}
+
+// reportPanic emits a message about a listener which has panicked.  If
+// the listener panics again while it receives this message, the
+// second panic is silently discarded.
+func (c *listenerInfo) reportPanic(r interface{}) {
+	if !c.panicking.CompareAndSwap(false, true) {
+		return
 	}
+	defer c.panicking.Store(false)
+	T("trace", PrioError, "listener for path %q panicked: %v", c.path, r)
+	T("trace", PrioVerbose, "stack trace of listener panic:\n%s", debug.Stack())
 }
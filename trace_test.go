@@ -17,6 +17,10 @@
 package trace
 
 import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -86,9 +90,9 @@ func TestT(t *testing.T) {
 		seenMsg string
 	)
 	handle := Register(
-		func(t time.Time, path string, prio Priority, msg string) {
+		func(m *Message) {
 			called = true
-			seenMsg = msg
+			seenMsg = m.Msg
 		}, "trace", PrioInfo)
 
 	tryOne := func(idx int, run test) {
@@ -119,7 +123,7 @@ func TestT(t *testing.T) {
 
 func TestEmptyPath(t *testing.T) {
 	seen := false
-	handler := func(t time.Time, path string, prio Priority, msg string) {
+	handler := func(m *Message) {
 		seen = true
 	}
 	handle := Register(handler, "", PrioAll)
@@ -130,19 +134,149 @@ func TestEmptyPath(t *testing.T) {
 	}
 }
 
-func handlerFunc(t time.Time, path string, prio Priority, msg string) {
+func TestNoMatchAllocs(t *testing.T) {
+	handle1 := Register(handlerFunc, "path1", PrioInfo)
+	handle2 := Register(handlerFunc, "path2", PrioDebug)
+	defer handle1.Unregister()
+	defer handle2.Unregister()
+
+	for _, path := range []string{"elsewhere", "path", "path1x", "path2"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			T(path, PrioVerbose, "hello")
+			T("elsewhere", PrioInfo, "hello")
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %g allocations for unmatched messages", path, allocs)
+		}
+	}
+}
+
+func TestDeliveryOrder(t *testing.T) {
+	var seen []string
+	record := func(name string) Listener {
+		return func(m *Message) {
+			seen = append(seen, name)
+		}
+	}
+	handle1 := Register(record("a/b"), "a/b", PrioAll)
+	handle2 := Register(record("a"), "a", PrioAll)
+	handle3 := Register(record("root"), "", PrioAll)
+	handle4 := Register(record("a2"), "a", PrioAll)
+	T("a/b/c", PrioInfo, "hello")
+	handle1.Unregister()
+	handle2.Unregister()
+	handle3.Unregister()
+	handle4.Unregister()
+
+	if got := fmt.Sprint(seen); got != "[root a a2 a/b]" {
+		t.Errorf("wrong delivery order %s", got)
+	}
+}
+
+func TestValuer(t *testing.T) {
+	calls := 0
+	expensive := func() interface{} {
+		calls++
+		return "computed"
+	}
+	var msgs []string
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m.Msg)
+	}, "lazy", PrioInfo)
+	handle2 := Register(handlerFunc, "lazy", PrioInfo)
+	T("lazy", PrioDebug, "%v", expensive)
+	T("lazy", PrioInfo, "%d %v %v", 1, expensive, Valuer(expensive))
+	handle.Unregister()
+	handle2.Unregister()
+
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+	if len(msgs) != 1 || msgs[0] != "1 computed computed" {
+		t.Errorf("wrong messages %q", msgs)
+	}
+
+	args := []interface{}{1, Valuer(expensive)}
+	evaluate(args)
+	if _, ok := args[1].(Valuer); !ok {
+		t.Error("arguments modified")
+	}
+}
+
+func TestTAuto(t *testing.T) {
+	var msgs []*Message
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "github.com/seehuhn", PrioInfo, CaptureCaller())
+	for i := 0; i < 2; i++ {
+		TAuto(PrioInfo, "hello %d", i)
+	}
+	TAuto(PrioDebug, "ignored")
+	handle.Unregister()
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	m := msgs[1]
+	if m.Path != "github.com/seehuhn/trace" || m.Msg != "hello 1" {
+		t.Errorf("wrong message %q %q", m.Path, m.Msg)
+	}
+	if !strings.HasSuffix(m.File, "trace_test.go") {
+		t.Errorf("wrong caller %s:%d", m.File, m.Line)
+	}
+}
+
+func TestTAt(t *testing.T) {
+	var msgs []*Message
+	handle := Register(func(m *Message) {
+		msgs = append(msgs, m)
+	}, "tat", PrioInfo, CaptureCaller())
+	wrapper := func() {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		TAt(pcs[0], "tat", PrioInfo, "hello")
+	}
+	_, _, line, _ := runtime.Caller(0)
+	wrapper()
+	handle.Unregister()
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	if m := msgs[0]; m.Line != line+1 || m.Func != "github.com/seehuhn/trace.TestTAt" {
+		t.Errorf("wrong caller %s:%d %s", m.File, m.Line, m.Func)
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	testData := []struct{ name, path string }{
+		{"github.com/a/b.(*T).Method", "github.com/a/b"},
+		{"github.com/a/b.F.func1", "github.com/a/b"},
+		{"main.main", "main"},
+		{"gopkg.in/yaml%2ev3.Marshal", "gopkg.in/yaml.v3"},
+		{"weird", "weird"},
+	}
+	for _, test := range testData {
+		if path := packagePath(test.name); path != test.path {
+			t.Errorf("%s: expected %q, got %q", test.name, test.path, path)
+		}
+	}
+}
+
+func handlerFunc(m *Message) {
 	// do nothing
 }
 
 func BenchmarkFunctionCall(b *testing.B) {
-	t := time.Now()
+	m := &Message{Time: time.Now(), Path: "test", Prio: PrioInfo, Msg: "hell"}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		handlerFunc(t, "test", PrioInfo, "hell")
+		handlerFunc(m)
 	}
 }
 
 func BenchmarkNoListeners(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		T("trace", PrioInfo, "hello")
 	}
@@ -151,6 +285,7 @@ func BenchmarkNoListeners(b *testing.B) {
 func BenchmarkOtherListeners(b *testing.B) {
 	handle1 := Register(handlerFunc, "path1", PrioInfo)
 	handle2 := Register(handlerFunc, "path2", PrioInfo)
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		T("elsewhere", PrioInfo, "hello")
 	}
@@ -188,3 +323,61 @@ func BenchmarkBothListeners(b *testing.B) {
 	handle1.Unregister()
 	handle2.Unregister()
 }
+
+func BenchmarkLowPriority(b *testing.B) {
+	handle1 := Register(handlerFunc, "path1", PrioInfo)
+	handle2 := Register(handlerFunc, "", PrioError)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		T("path1", PrioDebug, "hello")
+	}
+	handle1.Unregister()
+	handle2.Unregister()
+}
+
+func BenchmarkManyListeners(b *testing.B) {
+	var handles []ListenerHandle
+	for i := 0; i < 100; i++ {
+		path := "path" + strconv.Itoa(i) + "/sub"
+		handles = append(handles, Register(handlerFunc, path, PrioInfo))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		T("path50/other/x", PrioInfo, "hello")
+	}
+	b.StopTimer()
+	for _, handle := range handles {
+		handle.Unregister()
+	}
+}
+
+func BenchmarkParallelOtherListeners(b *testing.B) {
+	handle1 := Register(handlerFunc, "path1", PrioInfo)
+	handle2 := Register(handlerFunc, "path2", PrioInfo)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			T("elsewhere", PrioInfo, "hello")
+		}
+	})
+	handle1.Unregister()
+	handle2.Unregister()
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, prio := range []Priority{PrioCritical, PrioError, PrioInfo,
+		PrioDebug, PrioVerbose, PrioAll, 17, -1} {
+		p, err := ParsePriority(prio.String())
+		if err != nil {
+			t.Error(err)
+		} else if p != prio {
+			t.Errorf("%s: expected %d, got %d", prio, prio, p)
+		}
+	}
+	for _, s := range []string{"", "warning", "1e3", "99999999999"} {
+		if _, err := ParsePriority(s); err == nil {
+			t.Errorf("%q: missing error", s)
+		}
+	}
+}
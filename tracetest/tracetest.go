@@ -0,0 +1,131 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracetest helps with writing tests which check the trace
+// messages emitted by the code under test.  Example:
+//
+//	func TestOpen(t *testing.T) {
+//		rec := tracetest.Install(t, "config", trace.PrioAll)
+//		readConfig("missing.conf")
+//		if !rec.Messages().ByPriority(trace.PrioError).Contains("missing.conf") {
+//			t.Error("missing file not reported")
+//		}
+//	}
+package tracetest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+// Recorder is a trace listener which stores all messages it receives.
+// Use the Listen method as the listener argument of trace.Register(),
+// or use Install() to register a new Recorder for the duration of a
+// test.
+type Recorder struct {
+	mutex sync.Mutex // protects msgs
+	msgs  Messages
+}
+
+// Install registers a new Recorder for the given path and priority.
+// The recorder is automatically unregistered when the test finishes.
+func Install(t testing.TB, path string, prio trace.Priority) *Recorder {
+	rec := &Recorder{}
+	handle := trace.Register(rec.Listen, path, prio, trace.CaptureCaller())
+	t.Cleanup(handle.Unregister)
+	return rec
+}
+
+// Listen stores a message.
+func (rec *Recorder) Listen(m *trace.Message) {
+	rec.mutex.Lock()
+	rec.msgs = append(rec.msgs, m)
+	rec.mutex.Unlock()
+}
+
+// Messages returns all messages recorded so far, oldest first.
+func (rec *Recorder) Messages() Messages {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append(Messages(nil), rec.msgs...)
+}
+
+// Reset discards all recorded messages.
+func (rec *Recorder) Reset() {
+	rec.mutex.Lock()
+	rec.msgs = nil
+	rec.mutex.Unlock()
+}
+
+// Messages is a list of recorded messages.  The methods of Messages
+// can be chained to select messages of interest.
+type Messages []*trace.Message
+
+// ByPath returns the messages for the given path and its sub-paths.
+func (msgs Messages) ByPath(path string) Messages {
+	var res Messages
+	for _, m := range msgs {
+		if path == "" || m.Path == path || strings.HasPrefix(m.Path, path+"/") {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// ByPriority returns the messages of priority 'prio' and higher.
+func (msgs Messages) ByPriority(prio trace.Priority) Messages {
+	var res Messages
+	for _, m := range msgs {
+		if m.Prio >= prio {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// Matching returns the messages whose text contains 'substr'.
+func (msgs Messages) Matching(substr string) Messages {
+	var res Messages
+	for _, m := range msgs {
+		if strings.Contains(m.Msg, substr) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// Contains reports whether the text of at least one message contains
+// 'substr'.
+func (msgs Messages) Contains(substr string) bool {
+	for _, m := range msgs {
+		if strings.Contains(m.Msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Texts returns the message texts.
+func (msgs Messages) Texts() []string {
+	res := make([]string, len(msgs))
+	for i, m := range msgs {
+		res[i] = m.Msg
+	}
+	return res
+}
@@ -0,0 +1,71 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := Install(t, "app", trace.PrioDebug)
+	trace.T("app/config", trace.PrioError, "cannot read %q", "missing.conf")
+	trace.T("app/config", trace.PrioInfo, "using defaults")
+	trace.T("app/net", trace.PrioDebug, "listening")
+	trace.T("app/net", trace.PrioVerbose, "ignored")
+	trace.T("other", trace.PrioError, "ignored")
+
+	msgs := rec.Messages()
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %q", msgs.Texts())
+	}
+	if !msgs.ByPath("app/config").ByPriority(trace.PrioError).Contains("missing.conf") {
+		t.Error("error message not found")
+	}
+	if msgs.ByPath("app/net").Contains("missing.conf") {
+		t.Error("message found under wrong path")
+	}
+	if texts := msgs.ByPriority(trace.PrioInfo).Texts(); !reflect.DeepEqual(texts,
+		[]string{`cannot read "missing.conf"`, "using defaults"}) {
+		t.Errorf("wrong messages %q", texts)
+	}
+	if n := len(msgs.Matching("default")); n != 1 {
+		t.Errorf("expected 1 matching message, got %d", n)
+	}
+	if !strings.HasSuffix(msgs[0].File, "tracetest_test.go") {
+		t.Errorf("caller not recorded: %q", msgs[0].File)
+	}
+
+	rec.Reset()
+	if len(rec.Messages()) != 0 {
+		t.Error("Reset() failed")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	var rec *Recorder
+	t.Run("inner", func(t *testing.T) {
+		rec = Install(t, "cleanup", trace.PrioAll)
+	})
+	trace.T("cleanup", trace.PrioInfo, "after test")
+	if len(rec.Messages()) != 0 {
+		t.Error("recorder not unregistered after test")
+	}
+}
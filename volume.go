@@ -0,0 +1,137 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// VolumeStat gives the volume of the messages for one path, as written
+// to one sink, together with a projection of the daily volume and
+// cost.
+type VolumeStat struct {
+	Path     string `json:"path"`
+	Sink     string `json:"sink"`
+	Messages int64  `json:"messages"`
+	Bytes    int64  `json:"bytes"`
+
+	// BytesPerDay is the volume extrapolated to one day, and CostPerDay
+	// is the corresponding cost, using the price given for the sink.
+	BytesPerDay float64 `json:"bytes_per_day"`
+	CostPerDay  float64 `json:"cost_per_day"`
+}
+
+// VolumeCounter estimates the volume of trace messages by path and
+// sink, to find the paths which cause the highest storage costs.  The
+// size of a message is the length of its encoding in the trace file
+// format.  Use the listener returned by Listener() as the listener
+// argument of Register(), with the same path and priority as the sink
+// to be measured:
+//
+//	v := trace.NewVolumeCounter()
+//	trace.Install(sink, "", trace.PrioInfo)
+//	trace.Register(v.Listener("otlp"), "", trace.PrioInfo)
+type VolumeCounter struct {
+	mutex sync.Mutex // protects the following fields
+	stats map[volumeKey]*VolumeStat
+	first time.Time
+	last  time.Time
+}
+
+type volumeKey struct {
+	path, sink string
+}
+
+// NewVolumeCounter allocates a new, empty VolumeCounter.
+func NewVolumeCounter() *VolumeCounter {
+	return &VolumeCounter{
+		stats: map[volumeKey]*VolumeStat{},
+	}
+}
+
+// Listener returns a listener which records messages for the named
+// sink.
+func (v *VolumeCounter) Listener(sink string) Listener {
+	return func(m *Message) {
+		v.Add(sink, m)
+	}
+}
+
+// Add records a single message for the named sink.
+func (v *VolumeCounter) Add(sink string, m *Message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	key := volumeKey{path: m.Path, sink: sink}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	stat := v.stats[key]
+	if stat == nil {
+		stat = &VolumeStat{Path: key.path, Sink: key.sink}
+		v.stats[key] = stat
+	}
+	stat.Messages++
+	stat.Bytes += int64(len(data) + 1)
+	if v.first.IsZero() || m.Time.Before(v.first) {
+		v.first = m.Time
+	}
+	if m.Time.After(v.last) {
+		v.last = m.Time
+	}
+}
+
+// Report returns the volume statistics, in order of decreasing size.
+// The volume per day is extrapolated from the time between the
+// earliest and the latest message seen, or one second if this is
+// shorter.  The map 'prices' gives the price per gigabyte (10^9 bytes)
+// for each sink; the entry "*" applies to sinks not listed in the map.
+func (v *VolumeCounter) Report(prices map[string]float64) []VolumeStat {
+	v.mutex.Lock()
+	res := make([]VolumeStat, 0, len(v.stats))
+	for _, stat := range v.stats {
+		res = append(res, *stat)
+	}
+	span := max(v.last.Sub(v.first), time.Second)
+	v.mutex.Unlock()
+
+	perDay := float64(24*time.Hour) / float64(span)
+	for i := range res {
+		stat := &res[i]
+		price, ok := prices[stat.Sink]
+		if !ok {
+			price = prices["*"]
+		}
+		stat.BytesPerDay = float64(stat.Bytes) * perDay
+		stat.CostPerDay = stat.BytesPerDay / 1e9 * price
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := &res[i], &res[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Sink < b.Sink
+	})
+	return res
+}
@@ -0,0 +1,63 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package trace
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestVolumeCounter(t *testing.T) {
+	start := time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC)
+	m1 := &Message{Time: start, Path: "http", Msg: "a long message text"}
+	m2 := &Message{Time: start.Add(time.Hour), Path: "db", Msg: "short"}
+	v := NewVolumeCounter()
+	otlp := v.Listener("otlp")
+	otlp(m1)
+	otlp(m1)
+	otlp(m2)
+	v.Add("file", m2)
+
+	data1, _ := json.Marshal(m1)
+	size1 := int64(len(data1) + 1)
+	report := v.Report(map[string]float64{"otlp": 0.5, "*": 0.1})
+	if len(report) != 3 {
+		t.Fatalf("expected 3 entries, got %v", report)
+	}
+	top := report[0]
+	if top.Path != "http" || top.Sink != "otlp" || top.Messages != 2 ||
+		top.Bytes != 2*size1 {
+		t.Errorf("wrong top entry %v", top)
+	}
+	if top.BytesPerDay != float64(24*top.Bytes) {
+		t.Errorf("wrong projection %g", top.BytesPerDay)
+	}
+	if math.Abs(top.CostPerDay-top.BytesPerDay/1e9*0.5) > 1e-12 {
+		t.Errorf("wrong cost %g", top.CostPerDay)
+	}
+	for _, stat := range report[1:] {
+		price := 0.5
+		if stat.Sink == "file" {
+			price = 0.1
+		}
+		if math.Abs(stat.CostPerDay-stat.BytesPerDay/1e9*price) > 1e-12 {
+			t.Errorf("wrong cost for %v", stat)
+		}
+	}
+}
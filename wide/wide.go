@@ -0,0 +1,210 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package wide collects the trace messages and fields belonging to one
+// request into a single "wide event", which is emitted as one trace
+// message when the request is complete.
+//
+// An Event is attached to a context.Context at the start of a request.
+// Code handling the request then uses the T() function of this package,
+// instead of trace.T(), to emit messages which are both delivered as
+// usual and recorded in the event.  Example:
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		ctx, ev := wide.Start(r.Context(), "http/request")
+//		defer ev.Finish()
+//		ev.Set("url", r.URL.Path)
+//		// ...
+//		wide.T(ctx, "http/db", trace.PrioDebug, "query took %s", d)
+//	}
+//
+// The message emitted by Finish() has the path given to Start(), the
+// highest priority of the recorded messages (but at least
+// trace.PrioInfo), and a JSON object as the message text.
+package wide
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/seehuhn/trace"
+)
+
+// Event accumulates the fields and messages of one request.
+type Event struct {
+	path  string
+	start time.Time
+
+	mutex    sync.Mutex // protects the following fields
+	prio     trace.Priority
+	fields   map[string]interface{}
+	messages []record
+	finished bool
+	end      time.Time
+}
+
+// record is a message recorded in an event.
+type record struct {
+	Offset float64        `json:"t_ms"`
+	Path   string         `json:"path"`
+	Prio   trace.Priority `json:"prio"`
+	Msg    string         `json:"msg"`
+}
+
+type contextKey struct{}
+
+// Start creates a new event for the given path and returns a context
+// which carries the event.
+func Start(ctx context.Context, path string) (context.Context, *Event) {
+	ev := &Event{
+		path:   path,
+		start:  time.Now(),
+		prio:   trace.PrioInfo,
+		fields: map[string]interface{}{},
+	}
+	return context.WithValue(ctx, contextKey{}, ev), ev
+}
+
+// FromContext returns the event attached to 'ctx', or nil if there is
+// none.
+func FromContext(ctx context.Context) *Event {
+	ev, _ := ctx.Value(contextKey{}).(*Event)
+	return ev
+}
+
+// Set stores a field in the event.  The value must be suitable for
+// encoding with encoding/json.  Setting a field again replaces the
+// previous value.
+func (ev *Event) Set(key string, value interface{}) {
+	if ev == nil {
+		return
+	}
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	ev.fields[key] = value
+}
+
+// T emits a trace message, like trace.T(), and records the message in
+// the event attached to 'ctx', if any.  Messages emitted after the
+// event has finished are not recorded.
+func T(ctx context.Context, path string, prio trace.Priority, format string, args ...interface{}) {
+	// Messages are attributed to the caller of T, not to this package.
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+
+	ev := FromContext(ctx)
+	if ev == nil {
+		trace.TAt(pcs[0], path, prio, format, args...)
+		return
+	}
+
+	// The message is needed for the event in any case, so lazy
+	// arguments are evaluated here, once.
+	args = evaluate(args)
+	trace.TAt(pcs[0], path, prio, format, args...)
+
+	now := time.Now()
+	msg := fmt.Sprintf(format, withoutRoutes(args)...)
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	if ev.finished {
+		return
+	}
+	if prio > ev.prio {
+		ev.prio = prio
+	}
+	ev.messages = append(ev.messages, record{
+		Offset: float64(now.Sub(ev.start)) / float64(time.Millisecond),
+		Path:   path,
+		Prio:   prio,
+		Msg:    msg,
+	})
+}
+
+// evaluate returns a copy of 'args' with lazily evaluated arguments, as
+// described for trace.T(), replaced by their values.
+func evaluate(args []interface{}) []interface{} {
+	res := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch f := arg.(type) {
+		case trace.Valuer:
+			res[i] = f()
+		case func() interface{}:
+			res[i] = f()
+		default:
+			res[i] = arg
+		}
+	}
+	return res
+}
+
+// withoutRoutes returns 'args' without the trace.Route arguments, which
+// do not take part in formatting the message.
+func withoutRoutes(args []interface{}) []interface{} {
+	res := args[:0:0]
+	for _, arg := range args {
+		if _, ok := arg.(trace.Route); !ok {
+			res = append(res, arg)
+		}
+	}
+	return res
+}
+
+// Finish emits the wide event as a single trace message.  The message
+// text is a JSON object with the duration of the request in
+// milliseconds ("duration_ms"), the fields set using Set() ("fields"),
+// and the recorded messages ("messages").  Calls to Finish after the
+// first one have no effect.
+func (ev *Event) Finish() {
+	ev.mutex.Lock()
+	if ev.finished {
+		ev.mutex.Unlock()
+		return
+	}
+	ev.finished = true
+	ev.end = time.Now()
+	prio := ev.prio
+	ev.mutex.Unlock()
+
+	trace.T(ev.path, prio, "%s", (*encoder)(ev))
+}
+
+// encoder formats an event as JSON when the trace message is composed,
+// so that no work is done unless a listener receives the event.
+type encoder Event
+
+func (e *encoder) String() string {
+	ev := (*Event)(e)
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+	data, err := json.Marshal(struct {
+		Duration float64                `json:"duration_ms"`
+		Fields   map[string]interface{} `json:"fields"`
+		Messages []record               `json:"messages"`
+	}{
+		Duration: float64(ev.end.Sub(ev.start)) / float64(time.Millisecond),
+		Fields:   ev.fields,
+		Messages: ev.messages,
+	})
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,104 @@
+// A simple tracing framework for the Go programming language.
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package wide
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/seehuhn/trace"
+	"github.com/seehuhn/trace/tracetest"
+)
+
+func TestEvent(t *testing.T) {
+	rec := tracetest.Install(t, "wide", trace.PrioAll)
+
+	ctx, ev := Start(context.Background(), "wide/request")
+	if FromContext(ctx) != ev {
+		t.Fatal("event not found in context")
+	}
+	ev.Set("user", "alice")
+	ev.Set("status", 200)
+	T(ctx, "wide/db", trace.PrioDebug, "query %v", trace.Valuer(func() interface{} { return 1 }))
+	T(ctx, "wide/db", trace.PrioError, "query %d failed", 2)
+	T(context.Background(), "wide/db", trace.PrioInfo, "unrelated")
+	ev.Finish()
+	ev.Finish()
+	T(ctx, "wide/db", trace.PrioCritical, "too late")
+
+	events := rec.Messages().ByPath("wide/request")
+	if len(events) != 1 {
+		t.Fatalf("expected 1 wide event, got %d", len(events))
+	}
+	if events[0].Prio != trace.PrioError {
+		t.Errorf("wrong priority %d", events[0].Prio)
+	}
+	var data struct {
+		Duration float64                `json:"duration_ms"`
+		Fields   map[string]interface{} `json:"fields"`
+		Messages []record               `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(events[0].Msg), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Fields["user"] != "alice" || data.Fields["status"] != 200.0 {
+		t.Errorf("wrong fields %v", data.Fields)
+	}
+	if len(data.Messages) != 2 || data.Messages[1].Msg != "query 2 failed" ||
+		data.Messages[1].Prio != trace.PrioError {
+		t.Errorf("wrong messages %v", data.Messages)
+	}
+	if data.Duration < 0 {
+		t.Errorf("negative duration %g", data.Duration)
+	}
+
+	if n := len(rec.Messages().ByPath("wide/db")); n != 4 {
+		t.Errorf("expected 4 individual messages, got %d", n)
+	}
+}
+
+func TestNoEvent(t *testing.T) {
+	var ev *Event
+	ev.Set("ignored", 1)
+	if FromContext(context.Background()) != nil {
+		t.Error("unexpected event")
+	}
+}
+
+func TestCallerAndRoute(t *testing.T) {
+	var msgs []*trace.Message
+	handle := trace.Register(func(m *trace.Message) {
+		msgs = append(msgs, m)
+	}, "widecaller", trace.PrioAll, trace.CaptureCaller())
+	defer handle.Unregister()
+
+	ctx, ev := Start(context.Background(), "widecaller/request")
+	T(ctx, "widecaller/db", trace.PrioInfo, "query %d", 1, trace.Route("nowhere"))
+	ev.Finish()
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Msg != "query 1" || !strings.HasSuffix(msgs[0].File, "wide_test.go") {
+		t.Errorf("wrong message %q from %s:%d", msgs[0].Msg, msgs[0].File, msgs[0].Line)
+	}
+	if strings.Contains(msgs[1].Msg, "EXTRA") || !strings.Contains(msgs[1].Msg, `"query 1"`) {
+		t.Errorf("wrong wide event %s", msgs[1].Msg)
+	}
+}